@@ -2,23 +2,28 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"online-song-library/internal/controller"
-	"online-song-library/internal/model"
-	"online-song-library/internal/repository"
+	"online-song-library/internal/enrichment"
 	"online-song-library/internal/router"
 	"online-song-library/internal/service"
+	"online-song-library/internal/worker"
 	"online-song-library/pkg/logger"
-	"online-song-library/pkg/storage/postgresql"
+	"online-song-library/pkg/musicinfo"
 	test_api "online-song-library/test/external_api"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 const (
@@ -37,6 +42,15 @@ func main() {
 
 	log := logger.SetupLogger()
 
+	// go run main.go migrate up|down|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(log, os.Args[2:]); err != nil {
+			log.Error("migrate command failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
+
 	// mock external ip
 	if os.Getenv("EXTERNAL_API_HTTPTEST_SERVER") == "true" {
 		externalAPI := test_api.CreateMockExternalAPIServer(log)
@@ -45,27 +59,54 @@ func main() {
 		os.Setenv("PATH_EXTERNAL_API_HTTPTEST_SERVER", externalAPI.URL)
 	}
 
-	// db
-	db, err := postgresql.Connect(log)
+	// storage
+	repo, db, err := setupRepository(log)
 	if err != nil {
-		log.Error("unable to connect db", slog.String("err", err.Error()))
+		log.Error("unable to setup storage", slog.String("err", err.Error()))
 		return
 	}
-	log.Info("db connection successfully", slog.String("port", os.Getenv("DB_PORT")), slog.String("db_name", os.Getenv("DB_NAME")))
 
-	err = postgresql.Migrate(db, model.Song{})
+	// external api
+	enricher, err := setupEnricher(log, db)
 	if err != nil {
-		log.Error("unable to migrate entity", slog.String("err", err.Error()))
+		log.Error("unable to setup enrichment providers", slog.String("err", err.Error()))
 		return
 	}
-	log.Info("db migration successfully", slog.String("port", os.Getenv("DB_PORT")), slog.String("db_name", os.Getenv("DB_NAME")))
 
 	// logic
-	repo := repository.NewSongRepository(db)
-	serv := service.NewSongService(repo)
+	serv := service.NewSongService(repo, enricher)
 	cntrler := controller.NewSongController(serv, log)
 	ginRouter := router.SetupRouter(cntrler, log)
 
+	// background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	purger, err := setupTrashPurger(serv, log)
+	if err != nil {
+		log.Error("unable to setup trash purger", slog.String("err", err.Error()))
+		return
+	}
+	go purger.Run(jobsCtx)
+
+	enrichmentWorker, err := setupEnrichmentWorker(serv, log)
+	if err != nil {
+		log.Error("unable to setup enrichment worker", slog.String("err", err.Error()))
+		return
+	}
+	refresher, err := setupEnrichmentRefresher(serv, log)
+	if err != nil {
+		log.Error("unable to setup enrichment refresher", slog.String("err", err.Error()))
+		return
+	}
+	go refresher.Run(jobsCtx)
+
+	enricherDone := make(chan struct{})
+	go func() {
+		defer close(enricherDone)
+		enrichmentWorker.Run(jobsCtx)
+	}()
+
 	server := http.Server{
 		Addr:    os.Getenv("API_PORT"),
 		Handler: ginRouter,
@@ -85,7 +126,6 @@ func main() {
 	<-quit
 
 	log.Info("Server is shutting down...")
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -93,5 +133,239 @@ func main() {
 		log.Error("Server forced to shutdown: %v", slog.String("err", err.Error()))
 	}
 
+	// после запросов останавливаются фоновые задачи: новые песни воркерам больше не раздаются,
+	// а начатые попытки обогащения успевают записать результат за ENRICHMENT_DRAIN_TIMEOUT
+	stopJobs()
+	<-enricherDone
+
 	log.Info("Server exited gracefully")
 }
+
+// setupTrashPurger читает TRASH_RETENTION и TRASH_PURGE_INTERVAL в формате time.ParseDuration
+func setupTrashPurger(serv service.Service, log *slog.Logger) (*worker.TrashPurger, error) {
+	retention, err := durationEnv("TRASH_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := durationEnv("TRASH_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return worker.NewTrashPurger(serv, log, interval, retention), nil
+}
+
+// setupEnrichmentWorker читает ENRICHMENT_WORKERS, ENRICHMENT_INTERVAL, ENRICHMENT_MAX_ATTEMPTS,
+// ENRICHMENT_BACKOFF, ENRICHMENT_BACKOFF_MAX и ENRICHMENT_DRAIN_TIMEOUT
+func setupEnrichmentWorker(serv service.Service, log *slog.Logger) (*worker.EnrichmentWorker, error) {
+	cfg := worker.EnrichmentConfig{}
+
+	var err error
+	if cfg.Workers, err = intEnv("ENRICHMENT_WORKERS", 4); err != nil {
+		return nil, err
+	}
+	if cfg.Interval, err = durationEnv("ENRICHMENT_INTERVAL", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.MaxAttempts, err = intEnv("ENRICHMENT_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.BackoffBase, err = durationEnv("ENRICHMENT_BACKOFF", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.BackoffMax, err = durationEnv("ENRICHMENT_BACKOFF_MAX", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.DrainTimeout, err = durationEnv("ENRICHMENT_DRAIN_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	return worker.NewEnrichmentWorker(serv, log, cfg), nil
+}
+
+// setupEnrichmentRefresher читает ENRICHMENT_REFRESH_AGE и ENRICHMENT_REFRESH_INTERVAL в формате time.ParseDuration
+func setupEnrichmentRefresher(serv service.Service, log *slog.Logger) (*worker.EnrichmentRefresher, error) {
+	maxAge, err := durationEnv("ENRICHMENT_REFRESH_AGE", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := durationEnv("ENRICHMENT_REFRESH_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return worker.NewEnrichmentRefresher(serv, log, interval, maxAge), nil
+}
+
+// setupEnricher собирает цепочку провайдеров из ENRICHMENT_PROVIDERS через запятую в порядке приоритета:
+// music_info - апи /info, catalog - файл CATALOG_PATH (.json или .csv), tracks_api - апи TRACKS_API_URL
+// с таймаутом TRACKS_API_TIMEOUT. Http провайдеры ходят через кэш из setupEnrichmentCache
+func setupEnricher(log *slog.Logger, db *gorm.DB) (enrichment.Enricher, error) {
+	names := os.Getenv("ENRICHMENT_PROVIDERS")
+	if names == "" {
+		names = "music_info"
+	}
+	// провайдер, указанный дважды, опросился бы дважды и сломал бы регистрацию его статистики
+	list := strings.Split(names, ",")
+	seen := make(map[string]bool, len(list))
+	for i, name := range list {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, fmt.Errorf("enrichment provider %q is listed twice", name)
+		}
+		seen[name] = true
+		list[i] = name
+	}
+	withCache, err := setupEnrichmentCache(log, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []enrichment.Provider
+	for _, name := range list {
+		switch name {
+		case "music_info":
+			info, err := setupMusicInfo(log)
+			if err != nil {
+				return nil, err
+			}
+			expvar.Publish("music_info", expvar.Func(func() any { return info.Stats() }))
+			providers = append(providers, withCache(enrichment.NewMusicInfoProvider(info)))
+		case "catalog":
+			catalog, err := enrichment.NewCatalogProvider(os.Getenv("CATALOG_PATH"))
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, catalog)
+		case "tracks_api":
+			timeout, err := durationEnv("TRACKS_API_TIMEOUT", 5*time.Second)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, withCache(enrichment.NewTracksProvider(os.Getenv("TRACKS_API_URL"), timeout)))
+		default:
+			return nil, fmt.Errorf("unknown enrichment provider %q", name)
+		}
+	}
+
+	log.Info("enrichment providers configured", slog.String("providers", names))
+	return enrichment.NewChain(providers...), nil
+}
+
+// setupEnrichmentCache читает ENRICHMENT_CACHE: off, memory (по умолчанию) - LRU на ENRICHMENT_CACHE_SIZE записей
+// или db - тот же LRU перед таблицей enrichment_cache. Найденное хранится ENRICHMENT_CACHE_TTL,
+// «не найдено» - ENRICHMENT_CACHE_NEGATIVE_TTL. Возвращает обертку, которая ставит провайдера за кэш
+func setupEnrichmentCache(log *slog.Logger, db *gorm.DB) (func(enrichment.Provider) enrichment.Provider, error) {
+	mode := os.Getenv("ENRICHMENT_CACHE")
+	if mode == "off" {
+		return func(provider enrichment.Provider) enrichment.Provider { return provider }, nil
+	}
+
+	size, err := intEnv("ENRICHMENT_CACHE_SIZE", 10000)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := durationEnv("ENRICHMENT_CACHE_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	negativeTTL, err := durationEnv("ENRICHMENT_CACHE_NEGATIVE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache enrichment.Cache
+	switch mode {
+	case "memory", "":
+		cache = enrichment.NewLRUCache(size)
+	case "db":
+		if db == nil {
+			return nil, errors.New("ENRICHMENT_CACHE=db needs a database storage")
+		}
+		cache = enrichment.NewTieredCache(enrichment.NewLRUCache(size), enrichment.NewDBCache(db))
+	default:
+		return nil, fmt.Errorf("unknown enrichment cache %q", mode)
+	}
+	log.Info("enrichment cache configured", slog.String("cache", mode), slog.Int("size", size),
+		slog.Duration("ttl", ttl), slog.Duration("negative_ttl", negativeTTL))
+
+	// провайдеры добавляются только при старте, до первого чтения метрик
+	cached := map[string]*enrichment.CachedProvider{}
+	expvar.Publish("enrichment_cache", expvar.Func(func() any {
+		stats := map[string]enrichment.CacheStats{}
+		for name, provider := range cached {
+			stats[name] = provider.Stats()
+		}
+		return stats
+	}))
+	return func(provider enrichment.Provider) enrichment.Provider {
+		cachedProvider := enrichment.NewCachedProvider(provider, cache, ttl, negativeTTL)
+		cached[provider.Name()] = cachedProvider
+		return cachedProvider
+	}, nil
+}
+
+// setupMusicInfo читает адрес апи из PATH_EXTERNAL_API_HTTPTEST_SERVER, а таймаут, повторы и breaker -
+// из EXTERNAL_API_TIMEOUT, EXTERNAL_API_RETRIES, EXTERNAL_API_BREAKER_THRESHOLD и EXTERNAL_API_BREAKER_COOLDOWN
+func setupMusicInfo(log *slog.Logger) (*musicinfo.Client, error) {
+	cfg := musicinfo.DefaultConfig(os.Getenv("PATH_EXTERNAL_API_HTTPTEST_SERVER"))
+
+	var err error
+	if cfg.Timeout, err = durationEnv("EXTERNAL_API_TIMEOUT", cfg.Timeout); err != nil {
+		return nil, err
+	}
+	if cfg.Retries, err = intEnv("EXTERNAL_API_RETRIES", cfg.Retries); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerThreshold, err = intEnv("EXTERNAL_API_BREAKER_THRESHOLD", cfg.BreakerThreshold); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerCooldown, err = durationEnv("EXTERNAL_API_BREAKER_COOLDOWN", cfg.BreakerCooldown); err != nil {
+		return nil, err
+	}
+
+	log.Info("external api client configured", slog.String("url", cfg.BaseURL), slog.Duration("timeout", cfg.Timeout),
+		slog.Int("retries", cfg.Retries), slog.Int("breaker_threshold", cfg.BreakerThreshold))
+	return musicinfo.New(cfg), nil
+}
+
+// intEnv возвращает def, если переменная не задана. Отрицательные значения не допускаются
+func intEnv(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", key)
+	}
+	return n, nil
+}
+
+// durationEnv возвращает def, если переменная не задана
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	d, err := timeoutEnv(key, def)
+	if err != nil {
+		return 0, err
+	}
+	if d == 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", key)
+	}
+	return d, nil
+}
+
+// timeoutEnv как durationEnv, но допускает 0 - без ограничения
+func timeoutEnv(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", key)
+	}
+	return d, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"online-song-library/internal/migrations"
+	"online-song-library/internal/repository"
+	"online-song-library/pkg/storage/migrator"
+	"online-song-library/pkg/storage/postgresql"
+	"online-song-library/pkg/storage/sqlite"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+	storageSQLite   = "sqlite"
+)
+
+// setupRepository выбирает хранилище по переменной STORAGE: postgres (по умолчанию), sqlite или memory.
+// Для бд перед стартом применяются все непримененные миграции. Вторым значением отдается сама бд, у memory - nil
+func setupRepository(log *slog.Logger) (repository.Repository, *gorm.DB, error) {
+	if os.Getenv("STORAGE") == storageMemory {
+		log.Info("in-memory storage enabled, data will be lost on shutdown")
+		return repository.NewMemorySongRepository(), nil, nil
+	}
+
+	db, err := openDB(log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := newMigrator(db, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to migrate db: %w", err)
+	}
+	log.Info("db migration successfully", slog.Int("applied", applied))
+
+	// DB_STATEMENT_TIMEOUT ограничивает время каждого обращения к бд в рамках запроса, 0 - без ограничения
+	statementTimeout, err := timeoutEnv("DB_STATEMENT_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repository.NewSongRepository(db, statementTimeout), db, nil
+}
+
+func openDB(log *slog.Logger) (*gorm.DB, error) {
+	switch os.Getenv("STORAGE") {
+	case storageSQLite:
+		db, err := sqlite.Connect(log)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open sqlite db: %w", err)
+		}
+		log.Info("sqlite db opened successfully", slog.String("path", os.Getenv("SQLITE_PATH")))
+		return db, nil
+	case storagePostgres, "":
+		db, err := postgresql.Connect(log)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect db: %w", err)
+		}
+		log.Info("db connection successfully", slog.String("port", os.Getenv("DB_PORT")), slog.String("db_name", os.Getenv("DB_NAME")))
+		return db, nil
+	case storageMemory:
+		return nil, errors.New("in-memory storage has no database")
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", os.Getenv("STORAGE"))
+	}
+}
+
+func newMigrator(db *gorm.DB, log *slog.Logger) (*migrator.Migrator, error) {
+	fsys, err := migrations.For(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return migrator.New(db, log, fsys)
+}
@@ -0,0 +1,128 @@
+// Package apperr - доменные ошибки приложения. Вид ошибки определяет http статус,
+// Message отдается клиенту как есть, причина в Err только логируется
+package apperr
+
+import (
+	"errors"
+	"slices"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindPreconditionFailed
+	KindUpstream
+	KindUnsupportedMediaType
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation"
+	case KindPreconditionFailed:
+		return "precondition_failed"
+	case KindUpstream:
+		return "upstream"
+	case KindUnsupportedMediaType:
+		return "unsupported_media_type"
+	}
+	return "internal"
+}
+
+// FieldError - ошибка конкретного поля запроса, Field - имя из json или query
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Kind    Kind
+	Message string
+	// Fields заполняется у ошибок валидации, если известно, какие поля неверны
+	Fields []FieldError
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is сравнивает по виду и сообщению, поэтому sentinel с причиной (Wrap) остается равен самому sentinel
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Message == e.Message
+}
+
+// Wrap - копия ошибки с причиной err
+func (e *Error) Wrap(err error) *Error {
+	return &Error{Kind: e.Kind, Message: e.Message, Fields: e.Fields, Err: err}
+}
+
+// WithField - копия ошибки с еще одним неверным полем
+func (e *Error) WithField(field, message string) *Error {
+	fields := append(slices.Clip(e.Fields), FieldError{Field: field, Message: message})
+	return &Error{Kind: e.Kind, Message: e.Message, Fields: fields, Err: e.Err}
+}
+
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func Validation(message string) *Error {
+	return &Error{Kind: KindValidation, Message: message}
+}
+
+// InvalidField - ошибка валидации одного поля, сообщение поля совпадает с общим
+func InvalidField(field, message string) *Error {
+	return Validation(message).WithField(field, message)
+}
+
+func PreconditionFailed(message string) *Error {
+	return &Error{Kind: KindPreconditionFailed, Message: message}
+}
+
+func Upstream(message string) *Error {
+	return &Error{Kind: KindUpstream, Message: message}
+}
+
+func UnsupportedMediaType(message string) *Error {
+	return &Error{Kind: KindUnsupportedMediaType, Message: message}
+}
+
+func Internal(message string) *Error {
+	return &Error{Kind: KindInternal, Message: message}
+}
+
+// As возвращает первую доменную ошибку в цепочке err
+func As(err error) (*Error, bool) {
+	var domainErr *Error
+	ok := errors.As(err, &domainErr)
+	return domainErr, ok
+}
+
+// OrInternal оставляет доменную ошибку как есть, остальные оборачивает во внутреннюю с message
+func OrInternal(err error, message string) error {
+	if _, ok := As(err); ok {
+		return err
+	}
+	return Internal(message).Wrap(err)
+}
@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"online-song-library/internal/validation"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateAlbum creates a new album
+// @Summary Create a new album
+// @Tags albums
+// @Accept  json
+// @Produce  json
+// @Param album body model.AlbumDTO true "Album details"
+// @Success 200 {object} model.Album
+// @Failure 400 {object} model.Problem "Invalid input or unknown artist"
+// @Failure 500 {object} model.Problem "Failed to create album"
+// @Router /albums [post]
+func (r *SongController) CreateAlbum(c *gin.Context) {
+	var albumDTO model.AlbumDTO
+	if err := c.ShouldBindJSON(&albumDTO); err != nil {
+		r.log.Error("Failed to bind albumDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	if problem := checkRequired(map[string]bool{
+		"title":     strings.TrimSpace(albumDTO.Title) == "",
+		"artist_id": albumDTO.ArtistId == uuid.Nil,
+	}); problem != nil {
+		c.Error(problem)
+		return
+	}
+
+	album, err := r.serv.CreateAlbum(c.Request.Context(), r.log, model.Album{
+		Id:          uuid.New(),
+		Title:       strings.TrimSpace(albumDTO.Title),
+		ArtistId:    albumDTO.ArtistId,
+		ReleaseDate: albumDTO.ReleaseDate,
+		CoverLink:   albumDTO.CoverLink,
+	})
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to create album"))
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// UpdateAlbum updates an existing album
+// @Summary Update an album
+// @Description Updates non-empty fields of an album
+// @Tags albums
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Album ID"
+// @Param album body model.AlbumDTO true "Updated album details"
+// @Success 200 {object} model.Album
+// @Failure 400 {object} model.Problem "Invalid input, ID or unknown artist"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to update album"
+// @Router /albums/{id} [put]
+func (r *SongController) UpdateAlbum(c *gin.Context) {
+	var albumDTO model.AlbumDTO
+	if err := c.ShouldBindJSON(&albumDTO); err != nil {
+		r.log.Error("Failed to bind albumDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	albumId, ok := r.parseAlbumId(c)
+	if !ok {
+		return
+	}
+
+	album, err := r.serv.UpdateAlbum(c.Request.Context(), r.log, model.Album{
+		Id:          albumId,
+		Title:       strings.TrimSpace(albumDTO.Title),
+		ArtistId:    albumDTO.ArtistId,
+		ReleaseDate: albumDTO.ReleaseDate,
+		CoverLink:   albumDTO.CoverLink,
+	})
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to update album"))
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// DeleteAlbum deletes an album by ID
+// @Summary Delete an album
+// @Description Deletes an album, its songs stay in the library
+// @Tags albums
+// @Param id path string true "Album ID"
+// @Success 200 {object} model.ErrorResponse "Album deleted successfully"
+// @Failure 400 {object} model.Problem "Invalid album ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to delete album"
+// @Router /albums/{id} [delete]
+func (r *SongController) DeleteAlbum(c *gin.Context) {
+	albumId, ok := r.parseAlbumId(c)
+	if !ok {
+		return
+	}
+
+	err := r.serv.DeleteAlbum(c.Request.Context(), r.log, albumId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to delete album"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Album deleted successfully"})
+}
+
+// GetAlbum returns an album with its tracks
+// @Summary Get an album
+// @Description Returns an album with its tracks in order, songs in the trash are skipped
+// @Tags albums
+// @Produce  json
+// @Param id path string true "Album ID"
+// @Success 200 {object} model.AlbumDetails
+// @Failure 400 {object} model.Problem "Invalid album ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get album"
+// @Router /albums/{id} [get]
+func (r *SongController) GetAlbum(c *gin.Context) {
+	albumId, ok := r.parseAlbumId(c)
+	if !ok {
+		return
+	}
+
+	album, err := r.serv.GetAlbum(c.Request.Context(), r.log, albumId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get album"))
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// GetAlbums returns a list of albums
+// @Summary Get all albums
+// @Description Returns albums ordered by release date
+// @Tags albums
+// @Produce  json
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} model.Album
+// @Failure 400 {object} model.Problem "Invalid query parameters"
+// @Failure 500 {object} model.Problem "Failed to get albums"
+// @Router /albums [get]
+func (r *SongController) GetAlbums(c *gin.Context) {
+	limit, offset, ok := r.parseLimitOffset(c)
+	if !ok {
+		return
+	}
+
+	albums, err := r.serv.GetAlbums(c.Request.Context(), r.log, limit, offset)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get albums"))
+		return
+	}
+
+	c.JSON(http.StatusOK, albums)
+}
+
+// AddAlbumTrack adds a song to an album
+// @Summary Add a track to an album
+// @Description Inserts the song before the track with the given position (from 1), position 0 appends it
+// @Tags albums
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Album ID"
+// @Param track body model.AlbumTrackDTO true "Song and position"
+// @Success 200 {object} model.AlbumDetails
+// @Failure 400 {object} model.Problem "Invalid input, unknown song or position"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 409 {object} model.Problem "Song is already in the album"
+// @Failure 500 {object} model.Problem "Failed to add track"
+// @Router /albums/{id}/tracks [post]
+func (r *SongController) AddAlbumTrack(c *gin.Context) {
+	var trackDTO model.AlbumTrackDTO
+	if err := c.ShouldBindJSON(&trackDTO); err != nil {
+		r.log.Error("Failed to bind albumTrackDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	if problem := checkRequired(map[string]bool{"song_id": trackDTO.SongId == uuid.Nil}); problem != nil {
+		c.Error(problem)
+		return
+	}
+	albumId, ok := r.parseAlbumId(c)
+	if !ok {
+		return
+	}
+
+	album, err := r.serv.AddAlbumTrack(c.Request.Context(), r.log, albumId, trackDTO.SongId, trackDTO.Position)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to add track"))
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// RemoveAlbumTrack removes a song from an album
+// @Summary Remove a track from an album
+// @Description Removes the song from the album, the song stays in the library
+// @Tags albums
+// @Produce  json
+// @Param id path string true "Album ID"
+// @Param song_id path string true "Song ID"
+// @Success 200 {object} model.AlbumDetails
+// @Failure 400 {object} model.Problem "Invalid album or song ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to remove track"
+// @Router /albums/{id}/tracks/{song_id} [delete]
+func (r *SongController) RemoveAlbumTrack(c *gin.Context) {
+	albumId, ok := r.parseAlbumId(c)
+	if !ok {
+		return
+	}
+	songId, err := uuid.Parse(c.Param("song_id"))
+	if err != nil {
+		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("song_id", "Invalid song ID"))
+		return
+	}
+
+	album, err := r.serv.RemoveAlbumTrack(c.Request.Context(), r.log, albumId, songId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to remove track"))
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// ReorderAlbumTracks sets a new track order
+// @Summary Reorder album tracks
+// @Description Sets the order of album tracks. song_ids must list every track of the album exactly once.
+// @Tags albums
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Album ID"
+// @Param order body model.AlbumTracksOrder true "Song IDs in the new order"
+// @Success 200 {object} model.AlbumDetails
+// @Failure 400 {object} model.Problem "Invalid input or track order"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to reorder tracks"
+// @Router /albums/{id}/tracks [put]
+func (r *SongController) ReorderAlbumTracks(c *gin.Context) {
+	var order model.AlbumTracksOrder
+	if err := c.ShouldBindJSON(&order); err != nil {
+		r.log.Error("Failed to bind album tracks order", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	albumId, ok := r.parseAlbumId(c)
+	if !ok {
+		return
+	}
+
+	album, err := r.serv.ReorderAlbumTracks(c.Request.Context(), r.log, albumId, order.SongIds)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to reorder tracks"))
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+func (r *SongController) parseAlbumId(c *gin.Context) (uuid.UUID, bool) {
+	albumId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid album ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid album ID"))
+		return uuid.Nil, false
+	}
+	return albumId, true
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"online-song-library/internal/validation"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateArtist creates a new artist
+// @Summary Create a new artist
+// @Description Creates an artist. Names are unique case-insensitively.
+// @Tags artists
+// @Accept  json
+// @Produce  json
+// @Param artist body model.ArtistDTO true "Artist details"
+// @Success 200 {object} model.Artist
+// @Failure 400 {object} model.Problem "Invalid input"
+// @Failure 409 {object} model.Problem "Artist already exists"
+// @Failure 500 {object} model.Problem "Failed to create artist"
+// @Router /artists [post]
+func (r *SongController) CreateArtist(c *gin.Context) {
+	var artistDTO model.ArtistDTO
+	if err := c.ShouldBindJSON(&artistDTO); err != nil {
+		r.log.Error("Failed to bind artistDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	if problem := checkRequired(map[string]bool{"name": strings.TrimSpace(artistDTO.Name) == ""}); problem != nil {
+		c.Error(problem)
+		return
+	}
+
+	artist, err := r.serv.CreateArtist(c.Request.Context(), r.log, model.Artist{
+		Id:          uuid.New(),
+		Name:        strings.TrimSpace(artistDTO.Name),
+		Description: artistDTO.Description,
+	})
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to create artist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, artist)
+}
+
+// UpdateArtist updates an existing artist
+// @Summary Update an artist
+// @Description Updates non-empty fields of an artist. Renaming also renames the group of all artist songs.
+// @Tags artists
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Artist ID"
+// @Param artist body model.ArtistDTO true "Updated artist details"
+// @Success 200 {object} model.Artist
+// @Failure 400 {object} model.Problem "Invalid input or ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 409 {object} model.Problem "Artist already exists"
+// @Failure 500 {object} model.Problem "Failed to update artist"
+// @Router /artists/{id} [put]
+func (r *SongController) UpdateArtist(c *gin.Context) {
+	var artistDTO model.ArtistDTO
+	if err := c.ShouldBindJSON(&artistDTO); err != nil {
+		r.log.Error("Failed to bind artistDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	artistId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid artist ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid artist ID"))
+		return
+	}
+
+	artist, err := r.serv.UpdateArtist(c.Request.Context(), r.log, model.Artist{
+		Id:          artistId,
+		Name:        strings.TrimSpace(artistDTO.Name),
+		Description: artistDTO.Description,
+	})
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to update artist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, artist)
+}
+
+// DeleteArtist deletes an artist by ID
+// @Summary Delete an artist
+// @Description Deletes an artist that has no albums and no songs, including songs in the trash
+// @Tags artists
+// @Param id path string true "Artist ID"
+// @Success 200 {object} model.ErrorResponse "Artist deleted successfully"
+// @Failure 400 {object} model.Problem "Invalid artist ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 409 {object} model.Problem "Artist has songs or albums"
+// @Failure 500 {object} model.Problem "Failed to delete artist"
+// @Router /artists/{id} [delete]
+func (r *SongController) DeleteArtist(c *gin.Context) {
+	artistId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid artist ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid artist ID"))
+		return
+	}
+
+	err = r.serv.DeleteArtist(c.Request.Context(), r.log, artistId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to delete artist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Artist deleted successfully"})
+}
+
+// GetArtist returns an artist by ID
+// @Summary Get an artist
+// @Tags artists
+// @Produce  json
+// @Param id path string true "Artist ID"
+// @Success 200 {object} model.Artist
+// @Failure 400 {object} model.Problem "Invalid artist ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get artist"
+// @Router /artists/{id} [get]
+func (r *SongController) GetArtist(c *gin.Context) {
+	artistId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid artist ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid artist ID"))
+		return
+	}
+
+	artist, err := r.serv.GetArtist(c.Request.Context(), r.log, artistId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get artist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, artist)
+}
+
+// GetArtists returns a list of artists
+// @Summary Get all artists
+// @Description Returns artists ordered by name
+// @Tags artists
+// @Produce  json
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} model.Artist
+// @Failure 400 {object} model.Problem "Invalid query parameters"
+// @Failure 500 {object} model.Problem "Failed to get artists"
+// @Router /artists [get]
+func (r *SongController) GetArtists(c *gin.Context) {
+	limit, offset, ok := r.parseLimitOffset(c)
+	if !ok {
+		return
+	}
+
+	artists, err := r.serv.GetArtists(c.Request.Context(), r.log, limit, offset)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get artists"))
+		return
+	}
+
+	c.JSON(http.StatusOK, artists)
+}
+
+// GetArtistSongs returns songs of an artist
+// @Summary Get artist songs
+// @Description Returns songs of the artist in the order they were added, songs in the trash are skipped
+// @Tags artists
+// @Produce  json
+// @Param id path string true "Artist ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} model.Song
+// @Failure 400 {object} model.Problem "Invalid artist ID or query parameters"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get artist songs"
+// @Router /artists/{id}/songs [get]
+func (r *SongController) GetArtistSongs(c *gin.Context) {
+	artistId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid artist ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid artist ID"))
+		return
+	}
+	limit, offset, ok := r.parseLimitOffset(c)
+	if !ok {
+		return
+	}
+
+	songs, err := r.serv.GetArtistSongs(c.Request.Context(), r.log, artistId, limit, offset)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get artist songs"))
+		return
+	}
+
+	c.JSON(http.StatusOK, songs)
+}
+
+func (r *SongController) parseLimitOffset(c *gin.Context) (int, int, bool) {
+	limit := c.DefaultQuery("limit", "10")
+	offset := c.DefaultQuery("offset", "0")
+
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil || limitInt < 0 {
+		r.log.Error("Invalid pagination parameters", slog.String("limit", limit))
+		c.Error(apperr.InvalidField("limit", "Invalid limit"))
+		return 0, 0, false
+	}
+	offsetInt, err := strconv.Atoi(offset)
+	if err != nil || offsetInt < 0 {
+		r.log.Error("Invalid pagination parameters", slog.String("offset", offset))
+		c.Error(apperr.InvalidField("offset", "Invalid offset"))
+		return 0, 0, false
+	}
+	return limitInt, offsetInt, true
+}
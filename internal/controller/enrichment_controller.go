@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DebugVars отдает только перечисленные expvar переменные: полный expvar.Handler раскрыл бы cmdline и memstats
+func DebugVars(names ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		vars := make(map[string]json.RawMessage, len(names))
+		for _, name := range names {
+			if v := expvar.Get(name); v != nil {
+				vars[name] = json.RawMessage(v.String())
+			}
+		}
+		c.JSON(http.StatusOK, vars)
+	}
+}
+
+// EnrichSong refetches song details from the external API
+// @Summary Enrich a song again
+// @Description Fetches release date, text and link from the enrichment providers right away and stores the non-empty ones.
+// @Description Fields changed by hand (manual_fields) are kept unless force is true.
+// @Tags enrichment
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param force query bool false "Overwrite fields changed by hand"
+// @Success 200 {object} model.Song
+// @Header 200 {string} ETag "New song version"
+// @Failure 400 {object} model.Problem "Invalid song ID or force"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 502 {object} model.Problem "Failed to fetch song details"
+// @Failure 500 {object} model.Problem "Failed to enrich song"
+// @Router /songs/{id}/enrich [post]
+func (r *SongController) EnrichSong(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Failed to parse song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+	force, ok := parseForce(c)
+	if !ok {
+		return
+	}
+
+	song, err := r.serv.ReenrichSong(c.Request.Context(), r.log, id, force)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to enrich song"))
+		return
+	}
+
+	c.Header("ETag", songETag(song.Version))
+	c.JSON(http.StatusOK, song)
+}
+
+// EnrichSongs queues songs matching a filter for enrichment
+// @Summary Enrich songs again
+// @Description Marks songs matching the filter as pending, the background workers enrich them again.
+// @Description Songs that are already pending are skipped. Fields changed by hand are kept unless force is true.
+// @Tags enrichment
+// @Produce  json
+// @Param force query bool false "Overwrite fields changed by hand"
+// @Param id query string false "Song ID"
+// @Param album_id query string false "Album ID"
+// @Param group query string false "Group name"
+// @Param group_match query string false "Group match mode" Enums(exact, prefix, contains)
+// @Param title query string false "Song title"
+// @Param title_match query string false "Title match mode" Enums(exact, prefix, contains)
+// @Param tag query []string false "Tag names, repeat the parameter for several tags" collectionFormat(multi)
+// @Param tag_mode query string false "Require all tags (and) or any of them (or)" Enums(and, or)
+// @Param genre query []string false "Genre names, repeat the parameter for several genres" collectionFormat(multi)
+// @Param genre_mode query string false "Require all genres (and) or any of them (or)" Enums(and, or)
+// @Param enrichment_status query string false "Enrichment status" Enums(done, failed)
+// @Param enriched_before query string false "Songs enriched (or, if never enriched, created) before this date, YYYY-MM-DD"
+// @Success 202 {object} model.EnrichmentQueued
+// @Failure 400 {object} model.Problem "Invalid query parameters"
+// @Failure 500 {object} model.Problem "Failed to queue songs for enrichment"
+// @Router /songs/enrich [post]
+func (r *SongController) EnrichSongs(c *gin.Context) {
+	filter, ok := r.parseSongFilter(c)
+	if !ok {
+		return
+	}
+	// fuzzy без pg_trgm считается в памяти, а обновление идет одним запросом
+	if problem := invalidFields("Fuzzy match is not supported for enrichment", "fuzzy is not supported for enrichment", map[string]bool{
+		"group_match": filter.GroupMatch == model.MatchFuzzy,
+		"title_match": filter.TitleMatch == model.MatchFuzzy,
+	}); problem != nil {
+		c.Error(problem)
+		return
+	}
+	force, ok := parseForce(c)
+	if !ok {
+		return
+	}
+
+	queued, err := r.serv.QueueEnrichment(c.Request.Context(), r.log, model.EnrichmentRequest{Filter: filter, Force: force})
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to queue songs for enrichment"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, model.EnrichmentQueued{Queued: queued})
+}
+
+func parseForce(c *gin.Context) (bool, bool) {
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		c.Error(apperr.InvalidField("force", "Invalid force"))
+		return false, false
+	}
+	return force, true
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// StatusClientClosedRequest - нестандартный код nginx: клиент закрыл соединение, не дождавшись ответа
+	StatusClientClosedRequest = 499
+	// RequestIdHeader - id запроса, клиент может передать свой, иначе он создается. Всегда есть в ответе
+	RequestIdHeader    = "X-Request-ID"
+	ProblemContentType = "application/problem+json"
+
+	requestIdKey = "request_id"
+	// maxRequestIdLength - более длинный id клиента заменяется своим, чтобы не раздувать логи
+	maxRequestIdLength = 128
+)
+
+// RequestIdMiddleware кладет id запроса в контекст gin и в заголовок ответа
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(RequestIdHeader)
+		if requestId == "" || len(requestId) > maxRequestIdLength {
+			requestId = uuid.NewString()
+		}
+		c.Set(requestIdKey, requestId)
+		c.Header(RequestIdHeader, requestId)
+		c.Next()
+	}
+}
+
+// RequestId - id текущего запроса, пустой без RequestIdMiddleware
+func RequestId(c *gin.Context) string {
+	return c.GetString(requestIdKey)
+}
+
+// ErrorMiddleware отвечает на ошибку, которую обработчик передал через c.Error.
+// Обработчики сами ошибки не рендерят, поэтому формат ответа везде одинаковый
+func ErrorMiddleware(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		writeError(c, log, c.Errors.Last().Err)
+	}
+}
+
+// RecoveryHandler отдает панику обработчика как 500 в общем формате
+func RecoveryHandler(log *slog.Logger) gin.RecoveryFunc {
+	return func(c *gin.Context, recovered any) {
+		writeError(c, log, fmt.Errorf("panic: %v", recovered))
+		c.Abort()
+	}
+}
+
+// NoRoute - ответ на неизвестный путь
+func NoRoute(c *gin.Context) {
+	c.Error(apperr.NotFound("Route not found"))
+}
+
+func writeError(c *gin.Context, log *slog.Logger, err error) {
+	problem := problemOf(err)
+	problem.Instance = c.Request.URL.Path
+	problem.RequestId = RequestId(c)
+
+	attrs := []any{
+		slog.Int("status", problem.Status),
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.String("request_id", problem.RequestId),
+		slog.String("err", err.Error()),
+	}
+	if problem.Status >= http.StatusInternalServerError {
+		log.Error(problem.Detail, attrs...)
+	} else {
+		log.Debug(problem.Detail, attrs...)
+	}
+
+	if legacyErrorFormat(c) {
+		c.JSON(problem.Status, model.ErrorResponse{Error: problem.Detail})
+		return
+	}
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(problem.Status, problem)
+}
+
+// legacyErrorFormat - старые клиенты просят application/json и не знают про problem+json,
+// им ошибка отдается прежним {"error": ...}
+func legacyErrorFormat(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, ProblemContentType)
+}
+
+// problemOf - ответ без instance и request_id. Прерванные запросы отдаются отдельно:
+// 499 - клиент ушел и контекст отменен, 504 - истек таймаут. Ошибки без доменного типа - 500
+func problemOf(err error) model.Problem {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return newProblem(StatusClientClosedRequest, "canceled", "Request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return newProblem(http.StatusGatewayTimeout, "timeout", "Request timed out")
+	}
+
+	domainErr, ok := apperr.As(err)
+	if !ok {
+		return newProblem(http.StatusInternalServerError, apperr.KindInternal.String(), "Internal server error")
+	}
+	problem := newProblem(statusOf(domainErr.Kind), domainErr.Kind.String(), domainErr.Message)
+	problem.Errors = domainErr.Fields
+	return problem
+}
+
+func newProblem(status int, code, detail string) model.Problem {
+	title := http.StatusText(status)
+	if status == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+	return model.Problem{
+		Type:   "/problems/" + code,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func statusOf(kind apperr.Kind) int {
+	switch kind {
+	case apperr.KindNotFound:
+		return http.StatusNotFound
+	case apperr.KindConflict:
+		return http.StatusConflict
+	case apperr.KindValidation:
+		return http.StatusBadRequest
+	case apperr.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case apperr.KindUpstream:
+		return http.StatusBadGateway
+	case apperr.KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// checkRequired - ошибка "Invalid input" со всеми пустыми обязательными полями или nil.
+// empty сопоставляет имени поля признак того, что оно не заполнено
+func checkRequired(empty map[string]bool) *apperr.Error {
+	return invalidFields("Invalid input", "is required", empty)
+}
+
+// invalidFields - ошибка валидации message с полями, для которых bad истинно, или nil
+func invalidFields(message, fieldMessage string, bad map[string]bool) *apperr.Error {
+	fields := make([]string, 0, len(bad))
+	for field := range bad {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	var problem *apperr.Error
+	for _, field := range fields {
+		if !bad[field] {
+			continue
+		}
+		if problem == nil {
+			problem = apperr.Validation(message)
+		}
+		problem = problem.WithField(field, fieldMessage)
+	}
+	return problem
+}
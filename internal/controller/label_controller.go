@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"online-song-library/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AddSongTags adds tags to a song
+// @Summary Add tags to a song
+// @Description Adds tags to a song. Unknown tags are created, names are compared case-insensitively.
+// @Tags labels
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param tags body model.SongLabelsDTO true "Tag names"
+// @Success 200 {object} model.Song
+// @Failure 400 {object} model.Problem "Invalid input or ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to add labels"
+// @Router /songs/{id}/tags [post]
+func (r *SongController) AddSongTags(c *gin.Context) {
+	r.addSongLabels(c, model.LabelTag)
+}
+
+// AddSongGenres adds genres to a song
+// @Summary Add genres to a song
+// @Description Adds genres to a song. Unknown genres are created, names are compared case-insensitively.
+// @Tags labels
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param genres body model.SongLabelsDTO true "Genre names"
+// @Success 200 {object} model.Song
+// @Failure 400 {object} model.Problem "Invalid input or ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to add labels"
+// @Router /songs/{id}/genres [post]
+func (r *SongController) AddSongGenres(c *gin.Context) {
+	r.addSongLabels(c, model.LabelGenre)
+}
+
+// RemoveSongTag removes a tag from a song
+// @Summary Remove a tag from a song
+// @Tags labels
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param name path string true "Tag name"
+// @Success 200 {object} model.Song
+// @Failure 400 {object} model.Problem "Invalid song ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to remove label"
+// @Router /songs/{id}/tags/{name} [delete]
+func (r *SongController) RemoveSongTag(c *gin.Context) {
+	r.removeSongLabel(c, model.LabelTag)
+}
+
+// RemoveSongGenre removes a genre from a song
+// @Summary Remove a genre from a song
+// @Tags labels
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param name path string true "Genre name"
+// @Success 200 {object} model.Song
+// @Failure 400 {object} model.Problem "Invalid song ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to remove label"
+// @Router /songs/{id}/genres/{name} [delete]
+func (r *SongController) RemoveSongGenre(c *gin.Context) {
+	r.removeSongLabel(c, model.LabelGenre)
+}
+
+// GetTags returns all known tags
+// @Summary Get all tags
+// @Description Returns all tag names ordered alphabetically
+// @Tags labels
+// @Produce  json
+// @Success 200 {array} string
+// @Failure 500 {object} model.Problem "Failed to get labels"
+// @Router /tags [get]
+func (r *SongController) GetTags(c *gin.Context) {
+	r.getLabels(c, model.LabelTag)
+}
+
+// GetGenres returns all known genres
+// @Summary Get all genres
+// @Description Returns all genre names ordered alphabetically
+// @Tags labels
+// @Produce  json
+// @Success 200 {array} string
+// @Failure 500 {object} model.Problem "Failed to get labels"
+// @Router /genres [get]
+func (r *SongController) GetGenres(c *gin.Context) {
+	r.getLabels(c, model.LabelGenre)
+}
+
+func (r *SongController) addSongLabels(c *gin.Context, kind model.LabelKind) {
+	var labelsDTO model.SongLabelsDTO
+	if err := c.ShouldBindJSON(&labelsDTO); err != nil {
+		r.log.Error("Failed to bind labelsDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	if problem := checkRequired(map[string]bool{"names": len(labelsDTO.Names) == 0}); problem != nil {
+		c.Error(problem)
+		return
+	}
+	songId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+
+	song, err := r.serv.AddSongLabels(c.Request.Context(), r.log, songId, kind, labelsDTO.Names)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to add labels"))
+		return
+	}
+
+	c.JSON(http.StatusOK, song)
+}
+
+func (r *SongController) removeSongLabel(c *gin.Context, kind model.LabelKind) {
+	songId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+
+	song, err := r.serv.RemoveSongLabel(c.Request.Context(), r.log, songId, kind, c.Param("name"))
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to remove label"))
+		return
+	}
+
+	c.JSON(http.StatusOK, song)
+}
+
+func (r *SongController) getLabels(c *gin.Context, kind model.LabelKind) {
+	names, err := r.serv.GetLabels(c.Request.Context(), r.log, kind)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get labels"))
+		return
+	}
+
+	c.JSON(http.StatusOK, names)
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"online-song-library/internal/validation"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreatePlaylist creates a new playlist
+// @Summary Create a new playlist
+// @Tags playlists
+// @Accept  json
+// @Produce  json
+// @Param playlist body model.PlaylistDTO true "Playlist name"
+// @Success 200 {object} model.Playlist
+// @Failure 400 {object} model.Problem "Invalid input"
+// @Failure 500 {object} model.Problem "Failed to create playlist"
+// @Router /playlists [post]
+func (r *SongController) CreatePlaylist(c *gin.Context) {
+	var playlistDTO model.PlaylistDTO
+	if err := c.ShouldBindJSON(&playlistDTO); err != nil {
+		r.log.Error("Failed to bind playlistDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	if problem := checkRequired(map[string]bool{"name": strings.TrimSpace(playlistDTO.Name) == ""}); problem != nil {
+		c.Error(problem)
+		return
+	}
+
+	playlist, err := r.serv.CreatePlaylist(c.Request.Context(), r.log, model.Playlist{
+		Id:   uuid.New(),
+		Name: strings.TrimSpace(playlistDTO.Name),
+	})
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to create playlist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// RenamePlaylist renames a playlist
+// @Summary Rename a playlist
+// @Tags playlists
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Playlist ID"
+// @Param playlist body model.PlaylistDTO true "New playlist name"
+// @Success 200 {object} model.Playlist
+// @Failure 400 {object} model.Problem "Invalid input or ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to rename playlist"
+// @Router /playlists/{id} [put]
+func (r *SongController) RenamePlaylist(c *gin.Context) {
+	var playlistDTO model.PlaylistDTO
+	if err := c.ShouldBindJSON(&playlistDTO); err != nil {
+		r.log.Error("Failed to bind playlistDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	if problem := checkRequired(map[string]bool{"name": strings.TrimSpace(playlistDTO.Name) == ""}); problem != nil {
+		c.Error(problem)
+		return
+	}
+	playlistId, ok := r.parsePlaylistId(c)
+	if !ok {
+		return
+	}
+
+	playlist, err := r.serv.RenamePlaylist(c.Request.Context(), r.log, playlistId, strings.TrimSpace(playlistDTO.Name))
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to rename playlist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// DeletePlaylist deletes a playlist by ID
+// @Summary Delete a playlist
+// @Description Deletes a playlist, its songs stay in the library
+// @Tags playlists
+// @Param id path string true "Playlist ID"
+// @Success 200 {object} model.ErrorResponse "Playlist deleted successfully"
+// @Failure 400 {object} model.Problem "Invalid playlist ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to delete playlist"
+// @Router /playlists/{id} [delete]
+func (r *SongController) DeletePlaylist(c *gin.Context) {
+	playlistId, ok := r.parsePlaylistId(c)
+	if !ok {
+		return
+	}
+
+	err := r.serv.DeletePlaylist(c.Request.Context(), r.log, playlistId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to delete playlist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Playlist deleted successfully"})
+}
+
+// GetPlaylist returns a playlist with its songs
+// @Summary Get a playlist
+// @Description Returns a playlist with full song objects in order
+// @Tags playlists
+// @Produce  json
+// @Param id path string true "Playlist ID"
+// @Success 200 {object} model.PlaylistDetails
+// @Failure 400 {object} model.Problem "Invalid playlist ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get playlist"
+// @Router /playlists/{id} [get]
+func (r *SongController) GetPlaylist(c *gin.Context) {
+	playlistId, ok := r.parsePlaylistId(c)
+	if !ok {
+		return
+	}
+
+	playlist, err := r.serv.GetPlaylist(c.Request.Context(), r.log, playlistId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get playlist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// GetPlaylists returns a list of playlists
+// @Summary Get all playlists
+// @Description Returns playlists in creation order
+// @Tags playlists
+// @Produce  json
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} model.Playlist
+// @Failure 400 {object} model.Problem "Invalid query parameters"
+// @Failure 500 {object} model.Problem "Failed to get playlists"
+// @Router /playlists [get]
+func (r *SongController) GetPlaylists(c *gin.Context) {
+	limit, offset, ok := r.parseLimitOffset(c)
+	if !ok {
+		return
+	}
+
+	playlists, err := r.serv.GetPlaylists(c.Request.Context(), r.log, limit, offset)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get playlists"))
+		return
+	}
+
+	c.JSON(http.StatusOK, playlists)
+}
+
+// AddPlaylistSong adds a song to a playlist
+// @Summary Add a song to a playlist
+// @Description Inserts the song before the song with the given position (from 1), position 0 appends it. A song may appear in a playlist several times
+// @Tags playlists
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Playlist ID"
+// @Param song body model.PlaylistSongDTO true "Song and position"
+// @Success 200 {object} model.PlaylistDetails
+// @Failure 400 {object} model.Problem "Invalid input, unknown song or position"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to add song"
+// @Router /playlists/{id}/songs [post]
+func (r *SongController) AddPlaylistSong(c *gin.Context) {
+	var songDTO model.PlaylistSongDTO
+	if err := c.ShouldBindJSON(&songDTO); err != nil {
+		r.log.Error("Failed to bind playlistSongDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	if problem := checkRequired(map[string]bool{"song_id": songDTO.SongId == uuid.Nil}); problem != nil {
+		c.Error(problem)
+		return
+	}
+	playlistId, ok := r.parsePlaylistId(c)
+	if !ok {
+		return
+	}
+
+	playlist, err := r.serv.AddPlaylistSong(c.Request.Context(), r.log, playlistId, songDTO.SongId, songDTO.Position)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to add song"))
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// RemovePlaylistSong removes a song from a playlist by position
+// @Summary Remove a song from a playlist
+// @Description Removes the song at the given position (from 1), the following songs move up
+// @Tags playlists
+// @Produce  json
+// @Param id path string true "Playlist ID"
+// @Param position path int true "Song position"
+// @Success 200 {object} model.PlaylistDetails
+// @Failure 400 {object} model.Problem "Invalid playlist ID or position"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to remove song"
+// @Router /playlists/{id}/songs/{position} [delete]
+func (r *SongController) RemovePlaylistSong(c *gin.Context) {
+	playlistId, ok := r.parsePlaylistId(c)
+	if !ok {
+		return
+	}
+	position, ok := r.parsePlaylistPosition(c)
+	if !ok {
+		return
+	}
+
+	playlist, err := r.serv.RemovePlaylistSong(c.Request.Context(), r.log, playlistId, position)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to remove song"))
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// MovePlaylistSong moves a song inside a playlist
+// @Summary Move a song inside a playlist
+// @Description Moves the song at the given position (from 1) to a new position
+// @Tags playlists
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Playlist ID"
+// @Param position path int true "Current song position"
+// @Param move body model.PlaylistMoveDTO true "New position"
+// @Success 200 {object} model.PlaylistDetails
+// @Failure 400 {object} model.Problem "Invalid input, ID or position"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to move song"
+// @Router /playlists/{id}/songs/{position}/move [post]
+func (r *SongController) MovePlaylistSong(c *gin.Context) {
+	var moveDTO model.PlaylistMoveDTO
+	if err := c.ShouldBindJSON(&moveDTO); err != nil {
+		r.log.Error("Failed to bind playlistMoveDTO", slog.String("err", err.Error()))
+		c.Error(validation.Error(err))
+		return
+	}
+	playlistId, ok := r.parsePlaylistId(c)
+	if !ok {
+		return
+	}
+	position, ok := r.parsePlaylistPosition(c)
+	if !ok {
+		return
+	}
+
+	playlist, err := r.serv.MovePlaylistSong(c.Request.Context(), r.log, playlistId, position, moveDTO.Position)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to move song"))
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+func (r *SongController) parsePlaylistId(c *gin.Context) (uuid.UUID, bool) {
+	playlistId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid playlist ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid playlist ID"))
+		return uuid.Nil, false
+	}
+	return playlistId, true
+}
+
+func (r *SongController) parsePlaylistPosition(c *gin.Context) (int, bool) {
+	position, err := strconv.Atoi(c.Param("position"))
+	if err != nil {
+		r.log.Error("Invalid position", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("position", "Invalid position"))
+		return 0, false
+	}
+	return position, true
+}
@@ -1,11 +1,18 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"online-song-library/internal/apperr"
 	"online-song-library/internal/model"
+	"online-song-library/internal/repository"
 	"online-song-library/internal/service"
+	"online-song-library/internal/validation"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -25,118 +32,258 @@ func NewSongController(service service.Service, logger *slog.Logger) *SongContro
 
 // CreateSong creates a new song
 // @Summary Create a new song
-// @Description Creates a new song with the given details
+// @Description Stores the song right away with enrichment_status pending and returns 202.
+// @Description Release date, text and link are fetched from the external API in the background, failed attempts are retried with backoff.
+// @Description With album_id the song is appended to the album, and the album release date is used when the external API has none.
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param song body model.SongDTO true "Song details"
-// @Success 200 {object} model.ErrorResponse "song_id"
-// @Failure 400 {object} model.ErrorResponse "Invalid input"
-// @Failure 500 {object} model.ErrorResponse "Failed to create song"
+// @Success 202 {object} model.SongAccepted
+// @Header 202 {string} Location "URL of the created song"
+// @Failure 400 {object} model.Problem "Invalid input or unknown album"
+// @Failure 500 {object} model.Problem "Failed to create song"
 // @Router /songs [post]
 func (r *SongController) CreateSong(c *gin.Context) {
 	var songDTO model.SongDTO
 	if err := c.ShouldBindJSON(&songDTO); err != nil {
 		r.log.Error("Failed to bind songDTO", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		c.Error(validation.Error(err))
 		return
 	}
 
-	enrichedData, err := r.serv.FetchSongDetailsFromAPI(c.Request.Context(), r.log, songDTO.Group, songDTO.Title)
+	var album *model.AlbumDetails
+	if songDTO.AlbumId != nil {
+		found, err := r.serv.GetAlbum(c.Request.Context(), r.log, *songDTO.AlbumId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				c.Error(apperr.InvalidField("album_id", "Unknown album").Wrap(err))
+				return
+			}
+			c.Error(apperr.OrInternal(err, "Failed to create song"))
+			return
+		}
+		album = &found
+	}
+
+	// детали из внешнего апи подтянет воркер обогащения, его дата перекроет дату альбома
+	newSong := model.Song{
+		Id:               uuid.New(),
+		Group:            songDTO.Group,
+		Title:            songDTO.Title,
+		Tags:             songDTO.Tags,
+		Genres:           songDTO.Genres,
+		EnrichmentStatus: model.EnrichmentPending,
+	}
+	if album != nil {
+		newSong.ReleaseDate = album.ReleaseDate
+	}
+
+	songID, err := r.serv.CreateSong(c.Request.Context(), r.log, newSong, songDTO.AlbumId)
 	if err != nil {
-		r.log.Error("Failed to fetch song details from external API", slog.String("err", err.Error()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch song details"})
+		c.Error(apperr.OrInternal(err, "Failed to create song"))
 		return
 	}
 
-	newSong := model.Song{
-		Id:          uuid.New(),
-		Group:       songDTO.Group,
-		Title:       songDTO.Title,
-		ReleaseDate: enrichedData.ReleaseDate,
-		Text:        enrichedData.Text,
-		Link:        enrichedData.Link,
+	c.Header("Location", "/songs/"+songID.String())
+	c.JSON(http.StatusAccepted, model.SongAccepted{SongId: songID, EnrichmentStatus: model.EnrichmentPending})
+}
+
+// GetSong returns a song by ID
+// @Summary Get a song
+// @Description Returns a song with the given ID. The ETag header holds the song version for If-Match on update.
+// @Tags songs
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param fields query string false "Comma separated song fields to return, e.g. group,song,link. id is always returned"
+// @Success 200 {object} model.Song
+// @Header 200 {string} ETag "Song version"
+// @Failure 400 {object} model.Problem "Invalid song ID or fields"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get song"
+// @Router /songs/{id} [get]
+func (r *SongController) GetSong(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Failed to parse song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+
+	fields, ok := r.parseFields(c)
+	if !ok {
+		return
 	}
 
-	songID, err := r.serv.CreateSong(c.Request.Context(), r.log, newSong)
+	song, err := r.serv.GetSong(c.Request.Context(), r.log, id, fields)
 	if err != nil {
-		r.log.Error("Failed to create song", slog.String("err", err.Error()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create song"})
+		c.Error(apperr.OrInternal(err, "Failed to get song"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"song_id": songID.String()})
+	c.Header("ETag", songETag(song.Version))
+	c.JSON(http.StatusOK, sparseSong(song, fields))
 }
 
 // UpdateSong updates an existing song
 // @Summary Update an existing song
-// @Description Updates a song with the given ID
+// @Description Updates a song with the given ID. The artist is picked by artist_id or, if it is empty, by group (created if missing).
+// @Description Send the ETag from GET /songs/{id} in If-Match to reject the update when someone changed the song in between.
+// @Description The version field of the body is ignored.
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Song ID"
+// @Param If-Match header string false "ETag of the song version being edited"
 // @Param song body model.Song true "Updated song details"
 // @Success 200 {object} model.Song
-// @Failure 400 {object} model.ErrorResponse "Invalid input or ID"
-// @Failure 404 {object} model.ErrorResponse "Record not found"
-// @Failure 500 {object} model.ErrorResponse "Failed to update song"
+// @Header 200 {string} ETag "New song version"
+// @Failure 400 {object} model.Problem "Invalid input, ID or unknown artist"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 412 {object} model.Problem "Song version does not match If-Match"
+// @Failure 500 {object} model.Problem "Failed to update song"
 // @Router /songs/{id} [put]
 func (r *SongController) UpdateSong(c *gin.Context) {
 	var song model.Song
 	if err := c.ShouldBindJSON(&song); err != nil {
 		r.log.Error("Failed to bind song data", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		c.Error(validation.Error(err))
 		return
 	}
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		r.log.Error("Failed to parse song ID", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+	version, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		c.Error(apperr.PreconditionFailed("Song version does not match"))
 		return
 	}
 
 	song.Id = id
+	song.Version = version
 	updatedSong, err := r.serv.UpdateSong(c.Request.Context(), r.log, song)
 	if err != nil {
-		if err.Error() == "record not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
-			return
-		}
-		r.log.Error("Failed to update song", slog.String("err", err.Error()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update song"})
+		c.Error(apperr.OrInternal(err, "Failed to update song"))
 		return
 	}
 
+	c.Header("ETag", songETag(updatedSong.Version))
 	c.JSON(http.StatusOK, updatedSong)
 }
 
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// PatchSong applies a JSON Merge Patch or JSON Patch to a song
+// @Summary Patch a song
+// @Description Applies application/merge-patch+json (RFC 7396) or application/json-patch+json (RFC 6902) to the song fields group, song, release_date, text and link.
+// @Description Unlike PUT, empty values are stored as is, so text can be cleared. Only changed fields are validated.
+// @Description The response lists the fields that actually changed with their old and new values.
+// @Tags songs
+// @Accept  application/merge-patch+json,application/json-patch+json
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param If-Match header string false "ETag from GET /songs/{id}"
+// @Param patch body object true "Merge patch object or array of JSON Patch operations"
+// @Success 200 {object} model.SongPatchResult
+// @Header 200 {string} ETag "New song version"
+// @Failure 400 {object} model.Problem "Invalid song ID, patch or field values"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 409 {object} model.Problem "Patch test failed or link is already used by another song"
+// @Failure 412 {object} model.Problem "Song version does not match"
+// @Failure 415 {object} model.Problem "Unsupported patch format"
+// @Failure 500 {object} model.Problem "Failed to patch song"
+// @Router /songs/{id} [patch]
+func (r *SongController) PatchSong(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Failed to parse song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+
+	var format model.PatchFormat
+	switch c.ContentType() {
+	case MergePatchContentType:
+		format = model.PatchMerge
+	case JSONPatchContentType:
+		format = model.PatchJSON
+	default:
+		c.Header("Accept-Patch", MergePatchContentType+", "+JSONPatchContentType)
+		c.Error(apperr.UnsupportedMediaType("Unsupported patch format"))
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(validation.Error(err))
+		return
+	}
+	version, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		c.Error(apperr.PreconditionFailed("Song version does not match"))
+		return
+	}
+
+	result, err := r.serv.PatchSong(c.Request.Context(), r.log, id, version, model.SongPatch{Format: format, Body: body})
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to patch song"))
+		return
+	}
+
+	c.Header("ETag", songETag(result.Song.Version))
+	c.JSON(http.StatusOK, result)
+}
+
+func songETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch возвращает ожидаемую версию песни, 0 - без проверки (заголовка нет или *).
+// Поддерживается один строгий ETag: слабый или чужой никогда не совпадет с версией
+func parseIfMatch(header string) (int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	unquoted, found := strings.CutPrefix(header, `"`)
+	unquoted, closed := strings.CutSuffix(unquoted, `"`)
+	if !found || !closed {
+		return 0, false
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
 // DeleteSong deletes a song by ID
 // @Summary Delete a song
 // @Description Deletes a song with the given ID
 // @Tags songs
 // @Param id path string true "Song ID"
 // @Success 200 {object} model.ErrorResponse "Song deleted successfully"
-// @Failure 400 {object} model.ErrorResponse "Invalid song ID"
-// @Failure 404 {object} model.ErrorResponse "Record not found"
-// @Failure 500 {object} model.ErrorResponse "Failed to delete song"
+// @Failure 400 {object} model.Problem "Invalid song ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to delete song"
 // @Router /songs/{id} [delete]
 func (r *SongController) DeleteSong(c *gin.Context) {
 	songIdStr := c.Param("id")
 	songId, err := uuid.Parse(songIdStr)
 	if err != nil {
 		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
 		return
 	}
 
 	err = r.serv.DeleteSong(c.Request.Context(), r.log, songId)
 	if err != nil {
-		if err.Error() == "record not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
-			return
-		}
-		r.log.Error("Failed to delete song", slog.String("err", err.Error()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete song"})
+		c.Error(apperr.OrInternal(err, "Failed to delete song"))
 		return
 	}
 
@@ -145,26 +292,42 @@ func (r *SongController) DeleteSong(c *gin.Context) {
 
 // GetLibrary returns a list of songs
 // @Summary Get all songs in the library
-// @Description Returns a list of all songs with optional filtering and pagination
+// @Description Returns a list of all songs with optional filtering and pagination.
+// @Description Offset mode (default) returns an array of songs.
+// @Description Passing cursor (empty for the first page) switches to keyset mode and returns model.SongPage with next_cursor/prev_cursor.
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param limit query int false "Limit"
 // @Param offset query int false "Offset"
+// @Param cursor query string false "Opaque cursor from next_cursor/prev_cursor, empty for the first page"
 // @Param id query string false "Song ID"
+// @Param album_id query string false "Album ID"
 // @Param group query string false "Group name"
+// @Param group_match query string false "Group match mode" Enums(exact, prefix, contains, fuzzy)
 // @Param title query string false "Song title"
+// @Param title_match query string false "Title match mode" Enums(exact, prefix, contains, fuzzy)
+// @Param tag query []string false "Tag names, repeat the parameter for several tags" collectionFormat(multi)
+// @Param tag_mode query string false "Require all tags (and) or any of them (or)" Enums(and, or)
+// @Param genre query []string false "Genre names, repeat the parameter for several genres" collectionFormat(multi)
+// @Param genre_mode query string false "Require all genres (and) or any of them (or)" Enums(and, or)
+// @Param enrichment_status query string false "Enrichment status" Enums(pending, done, failed)
+// @Param enriched_before query string false "Songs enriched (or, if never enriched, created) before this date, YYYY-MM-DD"
+// @Param fields query string false "Comma separated song fields to return, e.g. group,song,link. id is always returned"
 // @Success 200 {array} model.Song
-// @Failure 400 {object} model.ErrorResponse "Invalid query parameters"
-// @Failure 500 {object} model.ErrorResponse "Failed to get library"
+// @Failure 400 {object} model.Problem "Invalid query parameters"
+// @Failure 500 {object} model.Problem "Failed to get library"
 // @Router /songs [get]
 func (r *SongController) GetLibrary(c *gin.Context) {
-	var filter model.SongFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		r.log.Error("Failed to bind query parameters", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+	filter, ok := r.parseSongFilter(c)
+	if !ok {
+		return
+	}
+	fields, ok := r.parseFields(c)
+	if !ok {
 		return
 	}
+	filter.Fields = fields
 
 	limit := c.DefaultQuery("limit", "10")
 	offset := c.DefaultQuery("offset", "0")
@@ -172,24 +335,145 @@ func (r *SongController) GetLibrary(c *gin.Context) {
 	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
 		r.log.Error("Invalid pagination parameters", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		c.Error(apperr.InvalidField("limit", "Invalid limit"))
 		return
 	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		r.getLibraryPage(c, filter, limitInt, cursor)
+		return
+	}
+
 	offsetInt, err := strconv.Atoi(offset)
 	if err != nil {
 		r.log.Error("Invalid pagination parameters", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		c.Error(apperr.InvalidField("offset", "Invalid offset"))
 		return
 	}
 
 	songs, err := r.serv.GetLibrary(c.Request.Context(), r.log, filter, limitInt, offsetInt)
 	if err != nil {
-		r.log.Error("Failed to get library", slog.String("err", err.Error()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get library"})
+		c.Error(apperr.OrInternal(err, "Failed to get library"))
 		return
 	}
 
-	c.JSON(http.StatusOK, songs)
+	c.JSON(http.StatusOK, sparseSongs(songs, filter.Fields))
+}
+
+// parseSongFilter разбирает фильтр песен из query. При ошибке она уже записана в c
+func (r *SongController) parseSongFilter(c *gin.Context) (model.SongFilter, bool) {
+	var filter model.SongFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		r.log.Error("Failed to bind query parameters", slog.String("err", err.Error()))
+		c.Error(apperr.Validation("Invalid query parameters").Wrap(err).WithField("query", err.Error()))
+		return filter, false
+	}
+	if idStr := c.Query("id"); idStr != "" {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			r.log.Error("Failed to parse song ID", slog.String("err", err.Error()))
+			c.Error(apperr.InvalidField("id", "Invalid song ID"))
+			return filter, false
+		}
+		filter.Id = &id
+	}
+	if albumIdStr := c.Query("album_id"); albumIdStr != "" {
+		albumId, err := uuid.Parse(albumIdStr)
+		if err != nil {
+			r.log.Error("Failed to parse album ID", slog.String("err", err.Error()))
+			c.Error(apperr.InvalidField("album_id", "Invalid album ID"))
+			return filter, false
+		}
+		filter.AlbumId = &albumId
+	}
+	if problem := invalidFields("Invalid match mode", "unknown match mode", map[string]bool{
+		"group_match": !filter.GroupMatch.Valid(),
+		"title_match": !filter.TitleMatch.Valid(),
+	}); problem != nil {
+		c.Error(problem)
+		return filter, false
+	}
+	if problem := invalidFields("Invalid label mode", "unknown label mode", map[string]bool{
+		"tag_mode":   !filter.TagMode.Valid(),
+		"genre_mode": !filter.GenreMode.Valid(),
+	}); problem != nil {
+		c.Error(problem)
+		return filter, false
+	}
+	if filter.Enrichment != nil && !filter.Enrichment.Valid() {
+		c.Error(apperr.Validation("Invalid enrichment status").WithField("enrichment_status", "unknown enrichment status"))
+		return filter, false
+	}
+	return filter, true
+}
+
+// getLibraryPage - keyset режим GetLibrary
+func (r *SongController) getLibraryPage(c *gin.Context, filter model.SongFilter, limit int, cursor string) {
+	if limit < 1 {
+		c.Error(apperr.InvalidField("limit", "Invalid limit"))
+		return
+	}
+	// fuzzy выдача отсортирована по похожести, а не по ключу курсора
+	if problem := invalidFields("Fuzzy match is not supported with cursor pagination", "fuzzy is not supported with cursor", map[string]bool{
+		"group_match": filter.GroupMatch == model.MatchFuzzy,
+		"title_match": filter.TitleMatch == model.MatchFuzzy,
+	}); problem != nil {
+		c.Error(problem)
+		return
+	}
+
+	page, err := r.serv.GetLibraryPage(c.Request.Context(), r.log, filter, limit, cursor)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get library"))
+		return
+	}
+
+	if len(filter.Fields) == 0 {
+		c.JSON(http.StatusOK, page)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"songs":       sparseSongs(page.Songs, filter.Fields),
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+	})
+}
+
+func (r *SongController) parseFields(c *gin.Context) (model.FieldSet, bool) {
+	fields, err := model.ParseFieldSet(c.Query("fields"))
+	if err != nil {
+		r.log.Error("Invalid fields", slog.String("err", err.Error()))
+		c.Error(apperr.Validation("Invalid fields").WithField("fields", err.Error()))
+		return nil, false
+	}
+	return fields, true
+}
+
+// sparseSong оставляет в ответе только запрошенные поля и id, без fields песня отдается целиком
+func sparseSong(song model.Song, fields model.FieldSet) any {
+	if len(fields) == 0 {
+		return song
+	}
+	raw, _ := json.Marshal(song)
+	var full map[string]json.RawMessage
+	_ = json.Unmarshal(raw, &full)
+
+	sparse := map[string]json.RawMessage{"id": full["id"]}
+	for _, field := range fields {
+		sparse[field] = full[field]
+	}
+	return sparse
+}
+
+func sparseSongs(songs []model.Song, fields model.FieldSet) any {
+	if len(fields) == 0 {
+		return songs
+	}
+	sparse := make([]any, 0, len(songs))
+	for _, song := range songs {
+		sparse = append(sparse, sparseSong(song, fields))
+	}
+	return sparse
 }
 
 // GetSongVerses returns paginated verses for a song
@@ -202,15 +486,16 @@ func (r *SongController) GetLibrary(c *gin.Context) {
 // @Param page query int false "Page number"
 // @Param page_size query int false "Number of verses per page"
 // @Success 200 {array} string
-// @Failure 400 {object} model.ErrorResponse "Invalid song ID or pagination parameters"
-// @Failure 500 {object} model.ErrorResponse "Failed to get song verses"
+// @Failure 400 {object} model.Problem "Invalid song ID, pagination parameters or page number"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get song verses"
 // @Router /songs/{id}/verses [get]
 func (r *SongController) GetSongVerses(c *gin.Context) {
 	songIdStr := c.Param("id")
 	songId, err := uuid.Parse(songIdStr)
 	if err != nil {
 		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
 		return
 	}
 
@@ -218,23 +503,237 @@ func (r *SongController) GetSongVerses(c *gin.Context) {
 	pageSize := c.DefaultQuery("page_size", "5")
 
 	pageInt, err := strconv.Atoi(page)
+	if err != nil || pageInt < 1 {
+		r.log.Error("Invalid pagination parameters", slog.String("page", page))
+		c.Error(apperr.InvalidField("page", "Invalid page"))
+		return
+	}
+	pageSizeInt, err := strconv.Atoi(pageSize)
+	if err != nil || pageSizeInt < 1 {
+		r.log.Error("Invalid pagination parameters", slog.String("page_size", pageSize))
+		c.Error(apperr.InvalidField("page_size", "Invalid page size"))
+		return
+	}
+	verses, err := r.serv.GetSongVerses(c.Request.Context(), r.log, songId, pageInt, pageSizeInt)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get song verses"))
+		return
+	}
+
+	c.JSON(http.StatusOK, verses)
+}
+
+// SearchSongs searches songs by lyrics
+// @Summary Full-text search by lyrics
+// @Description Returns songs whose text matches the query, ordered by rank, with the best matching verse highlighted
+// @Tags songs
+// @Accept  json
+// @Produce  json
+// @Param q query string true "Search query"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} model.SongSearchResult
+// @Failure 400 {object} model.Problem "Invalid query parameters"
+// @Failure 500 {object} model.Problem "Failed to search songs"
+// @Router /songs/search [get]
+func (r *SongController) SearchSongs(c *gin.Context) {
+	query := c.Query("q")
+	if strings.TrimSpace(query) == "" {
+		c.Error(apperr.InvalidField("q", "Empty search query"))
+		return
+	}
+
+	limit := c.DefaultQuery("limit", "10")
+	offset := c.DefaultQuery("offset", "0")
+
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil || limitInt < 0 {
+		r.log.Error("Invalid pagination parameters", slog.String("limit", limit))
+		c.Error(apperr.InvalidField("limit", "Invalid limit"))
+		return
+	}
+	offsetInt, err := strconv.Atoi(offset)
+	if err != nil || offsetInt < 0 {
+		r.log.Error("Invalid pagination parameters", slog.String("offset", offset))
+		c.Error(apperr.InvalidField("offset", "Invalid offset"))
+		return
+	}
+
+	results, err := r.serv.SearchSongs(c.Request.Context(), r.log, query, limitInt, offsetInt)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to search songs"))
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// GetTrash returns deleted songs
+// @Summary Get songs in the trash
+// @Description Returns soft-deleted songs, most recently deleted first
+// @Tags trash
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} model.Song
+// @Failure 400 {object} model.Problem "Invalid query parameters"
+// @Failure 500 {object} model.Problem "Failed to get trash"
+// @Router /songs/trash [get]
+func (r *SongController) GetTrash(c *gin.Context) {
+	limit := c.DefaultQuery("limit", "10")
+	offset := c.DefaultQuery("offset", "0")
+
+	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
 		r.log.Error("Invalid pagination parameters", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		c.Error(apperr.InvalidField("limit", "Invalid limit"))
 		return
 	}
-	pageSizeInt, err := strconv.Atoi(pageSize)
+	offsetInt, err := strconv.Atoi(offset)
 	if err != nil {
 		r.log.Error("Invalid pagination parameters", slog.String("err", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page size"})
+		c.Error(apperr.InvalidField("offset", "Invalid offset"))
 		return
 	}
-	verses, err := r.serv.GetSongVerses(c.Request.Context(), r.log, songId, pageInt, pageSizeInt)
+
+	songs, err := r.serv.GetTrash(c.Request.Context(), r.log, limitInt, offsetInt)
 	if err != nil {
-		r.log.Error("Failed to get song verses", slog.String("err", err.Error()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get song verses"})
+		c.Error(apperr.OrInternal(err, "Failed to get trash"))
 		return
 	}
 
-	c.JSON(http.StatusOK, verses)
+	c.JSON(http.StatusOK, songs)
+}
+
+// RestoreSong restores a song from the trash
+// @Summary Restore a deleted song
+// @Description Moves a song with the given ID out of the trash
+// @Tags trash
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Success 200 {object} model.Song
+// @Failure 400 {object} model.Problem "Invalid song ID"
+// @Failure 404 {object} model.Problem "Song is not in the trash"
+// @Failure 409 {object} model.Problem "Link is already used by another song"
+// @Failure 500 {object} model.Problem "Failed to restore song"
+// @Router /songs/{id}/restore [post]
+func (r *SongController) RestoreSong(c *gin.Context) {
+	songId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+
+	song, err := r.serv.RestoreSong(c.Request.Context(), r.log, songId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.Error(apperr.NotFound("Song is not in the trash").Wrap(err))
+			return
+		}
+		c.Error(apperr.OrInternal(err, "Failed to restore song"))
+		return
+	}
+
+	c.JSON(http.StatusOK, song)
+}
+
+// GetSongRevisions returns the revision history of a song
+// @Summary Get song revisions
+// @Description Returns all revisions of a song in ascending order, including songs in the trash
+// @Tags revisions
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Success 200 {array} model.SongRevision
+// @Failure 400 {object} model.Problem "Invalid song ID"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get song revisions"
+// @Router /songs/{id}/revisions [get]
+func (r *SongController) GetSongRevisions(c *gin.Context) {
+	songId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return
+	}
+
+	revisions, err := r.serv.GetSongRevisions(c.Request.Context(), r.log, songId)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get song revisions"))
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetSongRevision returns a single revision of a song
+// @Summary Get song revision
+// @Description Returns the song state saved in the given revision
+// @Tags revisions
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param revision path int true "Revision number"
+// @Success 200 {object} model.SongRevision
+// @Failure 400 {object} model.Problem "Invalid song ID or revision"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 500 {object} model.Problem "Failed to get song revision"
+// @Router /songs/{id}/revisions/{revision} [get]
+func (r *SongController) GetSongRevision(c *gin.Context) {
+	songId, revision, ok := r.parseRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	rev, err := r.serv.GetSongRevision(c.Request.Context(), r.log, songId, revision)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to get song revision"))
+		return
+	}
+
+	c.JSON(http.StatusOK, rev)
+}
+
+// RevertSong reverts a song to an earlier revision
+// @Summary Revert song to revision
+// @Description Restores song fields from the given revision and records the change as a new revision
+// @Tags revisions
+// @Produce  json
+// @Param id path string true "Song ID"
+// @Param revision path int true "Revision number"
+// @Success 200 {object} model.Song
+// @Failure 400 {object} model.Problem "Invalid song ID or revision"
+// @Failure 404 {object} model.Problem "Record not found"
+// @Failure 409 {object} model.Problem "Link is already used by another song"
+// @Failure 500 {object} model.Problem "Failed to revert song"
+// @Router /songs/{id}/revisions/{revision}/revert [post]
+func (r *SongController) RevertSong(c *gin.Context) {
+	songId, revision, ok := r.parseRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	song, err := r.serv.RevertSong(c.Request.Context(), r.log, songId, revision)
+	if err != nil {
+		c.Error(apperr.OrInternal(err, "Failed to revert song"))
+		return
+	}
+
+	c.JSON(http.StatusOK, song)
+}
+
+func (r *SongController) parseRevisionParams(c *gin.Context) (uuid.UUID, int, bool) {
+	songId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		r.log.Error("Invalid song ID", slog.String("err", err.Error()))
+		c.Error(apperr.InvalidField("id", "Invalid song ID"))
+		return uuid.Nil, 0, false
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision < 1 {
+		r.log.Error("Invalid revision", slog.String("revision", c.Param("revision")))
+		c.Error(apperr.InvalidField("revision", "Invalid revision"))
+		return uuid.Nil, 0, false
+	}
+	return songId, revision, true
 }
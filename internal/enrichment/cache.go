@@ -0,0 +1,222 @@
+package enrichment
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// sharedLookupTimeout ограничивает общий запрос к провайдеру: он не зависит от контекста
+// отдельного вызывающего, иначе отмена одного запроса отменила бы его для всех ждущих
+const sharedLookupTimeout = time.Minute
+
+// CacheEntry - закэшированный ответ провайдера. NotFound - провайдер не знает песню
+type CacheEntry struct {
+	Details
+	NotFound  bool
+	ExpiresAt time.Time
+}
+
+// Cache хранит ответы провайдеров по ключу. Просроченная запись - промах
+type Cache interface {
+	Get(ctx context.Context, log *slog.Logger, key string) (CacheEntry, bool, error)
+	Set(ctx context.Context, log *slog.Logger, key string, entry CacheEntry) error
+}
+
+// CacheStats - счетчики кэша провайдера для метрик
+type CacheStats struct {
+	Hits         int64 `json:"hits"`
+	NegativeHits int64 `json:"negative_hits"`
+	Misses       int64 `json:"misses"`
+	Errors       int64 `json:"errors"`
+}
+
+// CachedProvider - провайдер за кэшем. Ключ - имя провайдера и песня без учета регистра и лишних пробелов.
+// Ответ «не найдено» кэшируется на negativeTTL, ошибки провайдера не кэшируются.
+// Одновременные промахи по одной песне идут к провайдеру одним запросом
+type CachedProvider struct {
+	provider    Provider
+	cache       Cache
+	ttl         time.Duration
+	negativeTTL time.Duration
+	group       singleflight.Group
+
+	hits         atomic.Int64
+	negativeHits atomic.Int64
+	misses       atomic.Int64
+	errors       atomic.Int64
+}
+
+func NewCachedProvider(provider Provider, cache Cache, ttl, negativeTTL time.Duration) *CachedProvider {
+	return &CachedProvider{provider: provider, cache: cache, ttl: ttl, negativeTTL: negativeTTL}
+}
+
+func (p *CachedProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Lookup отдает ответ из кэша, а при промахе спрашивает провайдера. Недоступный кэш - тоже промах
+func (p *CachedProvider) Lookup(ctx context.Context, log *slog.Logger, group, title string) (Details, error) {
+	key := p.provider.Name() + ":" + normalize(group, title)
+
+	entry, ok, err := p.cache.Get(ctx, log, key)
+	if err != nil {
+		p.errors.Add(1)
+		log.Warn("enrichment cache read failed", slog.String("provider", p.Name()), slog.String("err", err.Error()))
+	}
+	if ok {
+		if entry.NotFound {
+			p.negativeHits.Add(1)
+			return Details{}, ErrNotFound
+		}
+		p.hits.Add(1)
+		return entry.Details, nil
+	}
+	p.misses.Add(1)
+
+	shared := p.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedLookupTimeout)
+		defer cancel()
+
+		details, err := p.provider.Lookup(ctx, log, group, title)
+		entry := CacheEntry{Details: details, ExpiresAt: time.Now().UTC().Add(p.ttl)}
+		if errors.Is(err, ErrNotFound) {
+			entry = CacheEntry{NotFound: true, ExpiresAt: time.Now().UTC().Add(p.negativeTTL)}
+		} else if err != nil {
+			return Details{}, err
+		}
+		if err := p.cache.Set(ctx, log, key, entry); err != nil {
+			p.errors.Add(1)
+			log.Warn("enrichment cache write failed", slog.String("provider", p.Name()), slog.String("err", err.Error()))
+		}
+		return details, err
+	})
+	// каждый вызывающий ждет общий ответ не дольше своего контекста
+	select {
+	case <-ctx.Done():
+		return Details{}, ctx.Err()
+	case result := <-shared:
+		if result.Err != nil {
+			return Details{}, result.Err
+		}
+		return result.Val.(Details), nil
+	}
+}
+
+func (p *CachedProvider) Stats() CacheStats {
+	return CacheStats{
+		Hits:         p.hits.Load(),
+		NegativeHits: p.negativeHits.Load(),
+		Misses:       p.misses.Load(),
+		Errors:       p.errors.Load(),
+	}
+}
+
+// LRUCache - кэш в памяти процесса, при переполнении вытесняется самая давно прочитанная запись
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    max(size, 1),
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *LRUCache) Get(ctx context.Context, log *slog.Logger, key string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+	item := elem.Value.(*lruItem)
+	if !time.Now().Before(item.entry.ExpiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return CacheEntry{}, false, nil
+	}
+	c.order.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+func (c *LRUCache) Set(ctx context.Context, log *slog.Logger, key string, entry CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(elem)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(&lruItem{key: key, entry: entry})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruItem).key)
+	}
+	return nil
+}
+
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// TieredCache читает уровни по порядку и копирует найденное в уровни выше, пишет во все
+type TieredCache struct {
+	levels []Cache
+}
+
+func NewTieredCache(levels ...Cache) *TieredCache {
+	return &TieredCache{levels: levels}
+}
+
+func (c *TieredCache) Get(ctx context.Context, log *slog.Logger, key string) (CacheEntry, bool, error) {
+	var errs []error
+	for i, level := range c.levels {
+		entry, ok, err := level.Get(ctx, log, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, upper := range c.levels[:i] {
+			if err := upper.Set(ctx, log, key, entry); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return entry, true, errors.Join(errs...)
+	}
+	return CacheEntry{}, false, errors.Join(errs...)
+}
+
+func (c *TieredCache) Set(ctx context.Context, log *slog.Logger, key string, entry CacheEntry) error {
+	var errs []error
+	for _, level := range c.levels {
+		if err := level.Set(ctx, log, key, entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CatalogProvider - локальный каталог песен из json или csv файла, читается один раз при старте
+type CatalogProvider struct {
+	songs map[string]Details
+}
+
+// catalogEntry - запись каталога. В csv те же имена колонок в заголовке, порядок любой
+type catalogEntry struct {
+	Group       string `json:"group"`
+	Title       string `json:"song"`
+	ReleaseDate string `json:"release_date"`
+	Text        string `json:"text"`
+	Link        string `json:"link"`
+}
+
+// NewCatalogProvider читает каталог, формат выбирается по расширению .json или .csv.
+// Дата выхода - в формате 2006-01-02
+func NewCatalogProvider(path string) (*CatalogProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []catalogEntry
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.NewDecoder(file).Decode(&entries)
+	case ".csv":
+		entries, err = readCatalogCSV(file)
+	default:
+		return nil, fmt.Errorf("unsupported catalog format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read catalog %s: %w", path, err)
+	}
+
+	songs := make(map[string]Details, len(entries))
+	for i, entry := range entries {
+		details := Details{Text: entry.Text, Link: entry.Link}
+		if entry.ReleaseDate != "" {
+			if details.ReleaseDate, err = time.Parse(time.DateOnly, entry.ReleaseDate); err != nil {
+				return nil, fmt.Errorf("catalog %s entry %d: %w", path, i+1, err)
+			}
+		}
+		songs[normalize(entry.Group, entry.Title)] = details
+	}
+	return &CatalogProvider{songs: songs}, nil
+}
+
+func readCatalogCSV(r io.Reader) ([]catalogEntry, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["group"]; !ok {
+		return nil, errors.New("no group column")
+	}
+	if _, ok := columns["song"]; !ok {
+		return nil, errors.New("no song column")
+	}
+
+	var entries []catalogEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		entries = append(entries, catalogEntry{
+			Group:       value("group"),
+			Title:       value("song"),
+			ReleaseDate: value("release_date"),
+			Text:        value("text"),
+			Link:        value("link"),
+		})
+	}
+}
+
+func (p *CatalogProvider) Name() string {
+	return "catalog"
+}
+
+func (p *CatalogProvider) Lookup(ctx context.Context, log *slog.Logger, group, title string) (Details, error) {
+	details, ok := p.songs[normalize(group, title)]
+	if !ok || details.empty() {
+		return Details{}, ErrNotFound
+	}
+	return details, nil
+}
@@ -0,0 +1,94 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cacheRow - запись таблицы enrichment_cache. Пустая дата выхода хранится как NULL
+type cacheRow struct {
+	CacheKey    string     `gorm:"type:varchar(2100);primaryKey"`
+	ReleaseDate *time.Time `gorm:"type:timestamp"`
+	Text        string     `gorm:"type:text;not null"`
+	Link        string     `gorm:"type:varchar(500);not null"`
+	NotFound    bool       `gorm:"not null"`
+	ExpiresAt   time.Time  `gorm:"type:timestamp;not null"`
+}
+
+func (cacheRow) TableName() string {
+	return "enrichment_cache"
+}
+
+// DBCache - кэш в таблице enrichment_cache, переживает перезапуск и общий для всех экземпляров сервиса.
+// Просроченная запись удаляется при чтении
+type DBCache struct {
+	db *gorm.DB
+}
+
+func NewDBCache(db *gorm.DB) *DBCache {
+	return &DBCache{db: db}
+}
+
+func (c *DBCache) Get(ctx context.Context, log *slog.Logger, key string) (CacheEntry, bool, error) {
+	select {
+	case <-ctx.Done():
+		return CacheEntry{}, false, ctx.Err()
+	default:
+	}
+
+	log.Debug("enrichment cache Get sql query:", slog.String("key", key))
+
+	var row cacheRow
+	err := c.db.WithContext(ctx).First(&row, "cache_key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(row.ExpiresAt) {
+		// запись могли обновить между чтением и удалением, поэтому удаляем только просроченную
+		err := c.db.WithContext(ctx).Where("cache_key = ? AND expires_at <= ?", key, now).Delete(&cacheRow{}).Error
+		return CacheEntry{}, false, err
+	}
+
+	entry := CacheEntry{
+		Details:   Details{Text: row.Text, Link: row.Link},
+		NotFound:  row.NotFound,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if row.ReleaseDate != nil {
+		entry.ReleaseDate = *row.ReleaseDate
+	}
+	return entry, true, nil
+}
+
+func (c *DBCache) Set(ctx context.Context, log *slog.Logger, key string, entry CacheEntry) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	log.Debug("enrichment cache Set sql query:", slog.String("key", key), slog.Bool("not_found", entry.NotFound))
+
+	row := cacheRow{
+		CacheKey:  key,
+		Text:      entry.Text,
+		Link:      entry.Link,
+		NotFound:  entry.NotFound,
+		ExpiresAt: entry.ExpiresAt.UTC(),
+	}
+	if !entry.ReleaseDate.IsZero() {
+		releaseDate := entry.ReleaseDate
+		row.ReleaseDate = &releaseDate
+	}
+	return c.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+}
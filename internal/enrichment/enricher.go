@@ -0,0 +1,129 @@
+// Package enrichment - детали песни из нескольких провайдеров. Chain опрашивает провайдеров по порядку
+// и сливает их ответы по полям: каждое поле берется у первого провайдера, который его знает
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ErrNotFound - ни один провайдер не знает песню
+var ErrNotFound = errors.New("song not found")
+
+// поля песни, которые заполняет обогащение, имена как в json песни
+const (
+	FieldReleaseDate = "release_date"
+	FieldText        = "text"
+	FieldLink        = "link"
+)
+
+// Details - детали песни, пустое поле значит, что провайдер его не знает
+type Details struct {
+	ReleaseDate time.Time
+	Text        string
+	Link        string
+}
+
+func (d Details) empty() bool {
+	return d.ReleaseDate.IsZero() && d.Text == "" && d.Link == ""
+}
+
+// Result - слитые детали и имя провайдера каждого заполненного поля
+type Result struct {
+	Details
+	Sources map[string]string
+}
+
+// Enricher находит детали песни по исполнителю и названию
+type Enricher interface {
+	Enrich(ctx context.Context, log *slog.Logger, group, title string) (Result, error)
+}
+
+// Provider - один источник деталей. Неизвестная песня - ErrNotFound
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, log *slog.Logger, group, title string) (Details, error)
+}
+
+// Chain - Enricher поверх провайдеров в порядке приоритета
+type Chain struct {
+	providers []Provider
+}
+
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{providers: providers}
+}
+
+// Enrich опрашивает провайдеров, пока не заполнены все поля. Ошибка провайдера не мешает остальным:
+// если хоть одно поле нашлось, возвращаются частичные детали, иначе - ошибки провайдеров или ErrNotFound
+func (c *Chain) Enrich(ctx context.Context, log *slog.Logger, group, title string) (Result, error) {
+	result := Result{Sources: map[string]string{}}
+	var errs []error
+	for _, provider := range c.providers {
+		if len(result.Sources) == 3 {
+			break
+		}
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+
+		details, err := provider.Lookup(ctx, log, group, title)
+		if errors.Is(err, ErrNotFound) {
+			log.Debug("provider does not know the song", slog.String("provider", provider.Name()))
+			continue
+		}
+		if err != nil {
+			log.Warn("enrichment provider failed", slog.String("provider", provider.Name()), slog.String("err", err.Error()))
+			errs = append(errs, &ProviderError{Provider: provider.Name(), Err: err})
+			continue
+		}
+		result.merge(provider.Name(), details)
+	}
+
+	switch {
+	case len(result.Sources) > 0:
+		return result, nil
+	case len(errs) > 0:
+		return Result{}, errors.Join(errs...)
+	}
+	return Result{}, ErrNotFound
+}
+
+// merge заполняет еще пустые поля из details
+func (r *Result) merge(provider string, details Details) {
+	if _, ok := r.Sources[FieldReleaseDate]; !ok && !details.ReleaseDate.IsZero() {
+		r.ReleaseDate = details.ReleaseDate
+		r.Sources[FieldReleaseDate] = provider
+	}
+	if _, ok := r.Sources[FieldText]; !ok && details.Text != "" {
+		r.Text = details.Text
+		r.Sources[FieldText] = provider
+	}
+	if _, ok := r.Sources[FieldLink]; !ok && details.Link != "" {
+		r.Link = details.Link
+		r.Sources[FieldLink] = provider
+	}
+}
+
+// ProviderError - ошибка одного провайдера цепочки
+type ProviderError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Provider + ": " + e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// normalize - ключ песни для поиска без учета регистра и лишних пробелов.
+// Переводов строк внутри частей после strings.Fields нет, поэтому они разделяют группу и название
+func normalize(group, title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(group), " ")) + "\n" + strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
@@ -0,0 +1,33 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"online-song-library/pkg/musicinfo"
+)
+
+// MusicInfoProvider - внешний апи /info
+type MusicInfoProvider struct {
+	client *musicinfo.Client
+}
+
+func NewMusicInfoProvider(client *musicinfo.Client) *MusicInfoProvider {
+	return &MusicInfoProvider{client: client}
+}
+
+func (p *MusicInfoProvider) Name() string {
+	return "music_info"
+}
+
+func (p *MusicInfoProvider) Lookup(ctx context.Context, log *slog.Logger, group, title string) (Details, error) {
+	details, err := p.client.Info(ctx, log, group, title)
+	if errors.Is(err, musicinfo.ErrNotFound) {
+		return Details{}, fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return Details{}, err
+	}
+	return Details{ReleaseDate: details.ReleaseDate, Text: details.Text, Link: details.Link}, nil
+}
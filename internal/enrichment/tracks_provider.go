@@ -0,0 +1,82 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TracksProvider - второй http апи со своей схемой:
+// GET /tracks?artist=&title= -> {"track": {"released": "2006-07-16", "lyrics": "...", "links": [{"url": "..."}]}}.
+// Неизвестная песня - 404
+type TracksProvider struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewTracksProvider - timeout ограничивает весь запрос, повторов нет: цепочка спросит остальных провайдеров
+func NewTracksProvider(baseURL string, timeout time.Duration) *TracksProvider {
+	return &TracksProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *TracksProvider) Name() string {
+	return "tracks_api"
+}
+
+func (p *TracksProvider) Lookup(ctx context.Context, log *slog.Logger, group, title string) (Details, error) {
+	params := url.Values{}
+	params.Add("artist", group)
+	params.Add("title", title)
+	apiURL := fmt.Sprintf("%s/tracks?%s", p.baseURL, params.Encode())
+	log.Debug("Request URL", slog.String("url", apiURL))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return Details{}, err
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return Details{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Details{}, ErrNotFound
+	default:
+		return Details{}, fmt.Errorf("tracks API status: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Track struct {
+			Released string `json:"released"`
+			Lyrics   string `json:"lyrics"`
+			Links    []struct {
+				URL string `json:"url"`
+			} `json:"links"`
+		} `json:"track"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Details{}, fmt.Errorf("invalid tracks API response: %w", err)
+	}
+
+	details := Details{Text: body.Track.Lyrics}
+	if len(body.Track.Links) > 0 {
+		details.Link = body.Track.Links[0].URL
+	}
+	if body.Track.Released != "" {
+		if details.ReleaseDate, err = time.Parse(time.DateOnly, body.Track.Released); err != nil {
+			return Details{}, fmt.Errorf("invalid tracks API response: %w", err)
+		}
+	}
+	return details, nil
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// sql миграции схемы лежат отдельно для каждого диалекта бд
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// For возвращает миграции для диалекта gorm (db.Dialector.Name())
+func For(dialect string) (fs.FS, error) {
+	switch dialect {
+	case "postgres", "sqlite":
+		return fs.Sub(files, dialect)
+	default:
+		return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+}
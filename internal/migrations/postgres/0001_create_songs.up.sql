@@ -0,0 +1,9 @@
+-- IF NOT EXISTS подхватывает таблицу, созданную раньше через gorm AutoMigrate
+CREATE TABLE IF NOT EXISTS songs (
+    id UUID PRIMARY KEY,
+    "group" VARCHAR(1000) NOT NULL,
+    title VARCHAR(1000) NOT NULL,
+    release_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    text TEXT,
+    link VARCHAR(500) NOT NULL UNIQUE
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS songs_text_search_idx;
+
+ALTER TABLE songs DROP COLUMN IF EXISTS text_search;
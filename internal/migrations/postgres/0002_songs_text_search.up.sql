@@ -0,0 +1,6 @@
+-- конфигурация 'simple' без стемминга: тексты песен на разных языках
+ALTER TABLE songs
+    ADD COLUMN text_search TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(text, ''))) STORED;
+
+CREATE INDEX songs_text_search_idx ON songs USING GIN (text_search);
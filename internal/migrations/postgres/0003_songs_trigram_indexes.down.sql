@@ -0,0 +1,3 @@
+-- расширение pg_trgm не удаляется: им могут пользоваться другие объекты бд
+DROP INDEX IF EXISTS songs_title_trgm_idx;
+DROP INDEX IF EXISTS songs_group_trgm_idx;
@@ -0,0 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- trigram индексы обслуживают и ILIKE (prefix/contains), и оператор % (fuzzy)
+CREATE INDEX songs_group_trgm_idx ON songs USING GIN ("group" gin_trgm_ops);
+CREATE INDEX songs_title_trgm_idx ON songs USING GIN (title gin_trgm_ops);
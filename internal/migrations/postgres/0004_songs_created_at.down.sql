@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS songs_created_at_id_idx;
+
+ALTER TABLE songs DROP COLUMN IF EXISTS created_at;
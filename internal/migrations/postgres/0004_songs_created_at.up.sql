@@ -0,0 +1,4 @@
+-- ключ сортировки для курсорной пагинации, у существующих строк порядок задает id
+ALTER TABLE songs ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX songs_created_at_id_idx ON songs (created_at, id);
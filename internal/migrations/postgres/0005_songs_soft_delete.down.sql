@@ -0,0 +1,10 @@
+-- песни из корзины удаляются окончательно: иначе ссылки могут нарушить уникальность
+DELETE FROM songs WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS songs_link_active_idx;
+
+ALTER TABLE songs ADD CONSTRAINT songs_link_key UNIQUE (link);
+
+DROP INDEX IF EXISTS songs_deleted_at_idx;
+
+ALTER TABLE songs DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,11 @@
+ALTER TABLE songs ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX songs_deleted_at_idx ON songs (deleted_at);
+
+-- удаленная в корзину песня не должна мешать создать ее заново,
+-- поэтому уникальность ссылки проверяется только среди неудаленных.
+-- имя ограничения зависит от того, кто создал таблицу: миграция 0001 или gorm AutoMigrate
+ALTER TABLE songs DROP CONSTRAINT IF EXISTS songs_link_key;
+ALTER TABLE songs DROP CONSTRAINT IF EXISTS uni_songs_link;
+
+CREATE UNIQUE INDEX songs_link_active_idx ON songs (link) WHERE deleted_at IS NULL;
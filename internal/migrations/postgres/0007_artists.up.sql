@@ -0,0 +1,30 @@
+CREATE TABLE artists (
+    id UUID PRIMARY KEY,
+    name VARCHAR(1000) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- "Muse", "muse" и "MUSE" - один исполнитель
+CREATE UNIQUE INDEX artists_name_key ON artists (lower(name));
+
+-- из разных написаний одного исполнителя имя берется у самой ранней песни
+INSERT INTO artists (id, name, created_at)
+SELECT gen_random_uuid(), name, created_at
+FROM (
+    SELECT DISTINCT ON (lower(trim("group"))) trim("group") AS name, created_at
+    FROM songs
+    ORDER BY lower(trim("group")), created_at, id
+) AS distinct_groups;
+
+ALTER TABLE songs ADD COLUMN artist_id UUID REFERENCES artists (id);
+
+-- group остается копией имени исполнителя: на нем работают фильтры, trigram индекс и ревизии
+UPDATE songs
+SET artist_id = artists.id, "group" = artists.name
+FROM artists
+WHERE lower(trim(songs."group")) = lower(artists.name);
+
+ALTER TABLE songs ALTER COLUMN artist_id SET NOT NULL;
+
+CREATE INDEX songs_artist_id_idx ON songs (artist_id);
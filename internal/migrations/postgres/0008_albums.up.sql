@@ -0,0 +1,21 @@
+CREATE TABLE albums (
+    id UUID PRIMARY KEY,
+    title VARCHAR(1000) NOT NULL,
+    artist_id UUID NOT NULL REFERENCES artists (id),
+    release_date TIMESTAMP,
+    cover_link VARCHAR(500),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX albums_artist_id_idx ON albums (artist_id);
+
+-- песня может входить в несколько альбомов. position задает только порядок,
+-- после окончательного удаления песни в нумерации остаются дыры
+CREATE TABLE album_tracks (
+    album_id UUID NOT NULL REFERENCES albums (id) ON DELETE CASCADE,
+    song_id UUID NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (album_id, song_id)
+);
+
+CREATE INDEX album_tracks_song_id_idx ON album_tracks (song_id);
@@ -0,0 +1,31 @@
+-- жанры и свободные теги устроены одинаково: справочник имен и связь многие ко многим с песнями.
+-- имена уникальны без учета регистра, как у исполнителей
+CREATE TABLE genres (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE UNIQUE INDEX genres_name_key ON genres (lower(name));
+
+CREATE TABLE song_genres (
+    song_id UUID NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    genre_id UUID NOT NULL REFERENCES genres (id) ON DELETE CASCADE,
+    PRIMARY KEY (song_id, genre_id)
+);
+
+CREATE INDEX song_genres_genre_id_idx ON song_genres (genre_id);
+
+CREATE TABLE tags (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE UNIQUE INDEX tags_name_key ON tags (lower(name));
+
+CREATE TABLE song_tags (
+    song_id UUID NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (song_id, tag_id)
+);
+
+CREATE INDEX song_tags_tag_id_idx ON song_tags (tag_id);
@@ -0,0 +1,2 @@
+-- версия для оптимистичной блокировки: растет на каждом изменении песни и отдается клиенту как ETag
+ALTER TABLE songs ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+ALTER TABLE songs DROP COLUMN IF EXISTS manual_fields;
+ALTER TABLE songs DROP COLUMN IF EXISTS enrichment_force;
+ALTER TABLE songs DROP COLUMN IF EXISTS enriched_at;
@@ -0,0 +1,7 @@
+-- песни периодически обогащаются заново, поля, исправленные руками, при этом не перезаписываются.
+-- время обогащения старых песен неизвестно, считается временем создания
+ALTER TABLE songs ADD COLUMN enriched_at TIMESTAMP;
+ALTER TABLE songs ADD COLUMN enrichment_force BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE songs ADD COLUMN manual_fields TEXT NOT NULL DEFAULT '';
+
+UPDATE songs SET enriched_at = created_at WHERE enrichment_status = 'done';
@@ -0,0 +1,2 @@
+-- провайдер обогащения каждого поля, json объект. Источник уже обогащенных песен неизвестен
+ALTER TABLE songs ADD COLUMN enrichment_sources TEXT NOT NULL DEFAULT '';
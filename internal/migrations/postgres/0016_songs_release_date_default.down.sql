@@ -0,0 +1 @@
+ALTER TABLE songs ALTER COLUMN release_date SET DEFAULT CURRENT_TIMESTAMP;
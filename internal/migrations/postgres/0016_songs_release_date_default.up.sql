@@ -0,0 +1,2 @@
+-- у песни без даты из апи и альбома дата остается пустой, а не временем создания
+ALTER TABLE songs ALTER COLUMN release_date DROP DEFAULT;
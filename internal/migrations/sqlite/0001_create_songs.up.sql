@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS songs (
+    id TEXT PRIMARY KEY,
+    "group" VARCHAR(1000) NOT NULL,
+    title VARCHAR(1000) NOT NULL,
+    release_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    text TEXT,
+    link VARCHAR(500) NOT NULL UNIQUE
+);
@@ -0,0 +1,2 @@
+-- на sqlite поиск по тексту выполняется в памяти, версия оставлена для совпадения нумерации с postgres
+SELECT 1;
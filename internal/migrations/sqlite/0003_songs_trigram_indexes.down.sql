@@ -0,0 +1,2 @@
+-- на sqlite fuzzy поиск считается в памяти, версия оставлена для совпадения нумерации с postgres
+SELECT 1;
@@ -0,0 +1,7 @@
+-- sqlite не разрешает добавлять колонку с DEFAULT CURRENT_TIMESTAMP,
+-- поэтому существующие строки заполняются отдельно в формате, который пишет драйвер
+ALTER TABLE songs ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+
+UPDATE songs SET created_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now');
+
+CREATE INDEX songs_created_at_id_idx ON songs (created_at, id);
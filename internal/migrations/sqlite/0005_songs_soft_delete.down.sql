@@ -0,0 +1,19 @@
+-- песни из корзины удаляются окончательно: иначе ссылки могут нарушить уникальность
+CREATE TABLE songs_old (
+    id TEXT PRIMARY KEY,
+    "group" VARCHAR(1000) NOT NULL,
+    title VARCHAR(1000) NOT NULL,
+    release_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    text TEXT,
+    link VARCHAR(500) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00'
+);
+
+INSERT INTO songs_old (id, "group", title, release_date, text, link, created_at)
+SELECT id, "group", title, release_date, text, link, created_at FROM songs WHERE deleted_at IS NULL;
+
+DROP TABLE songs;
+
+ALTER TABLE songs_old RENAME TO songs;
+
+CREATE INDEX songs_created_at_id_idx ON songs (created_at, id);
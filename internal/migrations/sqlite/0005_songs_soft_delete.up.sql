@@ -0,0 +1,23 @@
+-- sqlite не умеет удалять UNIQUE ограничение колонки, поэтому таблица пересоздается.
+-- уникальность ссылки проверяется только среди неудаленных песен
+CREATE TABLE songs_new (
+    id TEXT PRIMARY KEY,
+    "group" VARCHAR(1000) NOT NULL,
+    title VARCHAR(1000) NOT NULL,
+    release_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    text TEXT,
+    link VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00',
+    deleted_at TIMESTAMP
+);
+
+INSERT INTO songs_new (id, "group", title, release_date, text, link, created_at)
+SELECT id, "group", title, release_date, text, link, created_at FROM songs;
+
+DROP TABLE songs;
+
+ALTER TABLE songs_new RENAME TO songs;
+
+CREATE INDEX songs_created_at_id_idx ON songs (created_at, id);
+CREATE INDEX songs_deleted_at_idx ON songs (deleted_at);
+CREATE UNIQUE INDEX songs_link_active_idx ON songs (link) WHERE deleted_at IS NULL;
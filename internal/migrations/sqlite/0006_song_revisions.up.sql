@@ -0,0 +1,16 @@
+CREATE TABLE song_revisions (
+    song_id TEXT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    operation VARCHAR(16) NOT NULL,
+    "group" VARCHAR(1000) NOT NULL,
+    title VARCHAR(1000) NOT NULL,
+    release_date TIMESTAMP,
+    text TEXT,
+    link VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (song_id, revision)
+);
+
+-- существующие песни получают начальную ревизию с текущим состоянием
+INSERT INTO song_revisions (song_id, revision, operation, "group", title, release_date, text, link, created_at)
+SELECT id, 1, 'create', "group", title, release_date, text, link, created_at FROM songs;
@@ -0,0 +1,31 @@
+-- sqlite не удаляет колонку с внешним ключом, поэтому songs пересоздается.
+-- DROP TABLE songs каскадно удалит ревизии, их нужно сохранить и вернуть.
+-- исходные написания group, схлопнутые в одно имя, не восстанавливаются
+CREATE TEMP TABLE song_revisions_backup AS SELECT * FROM song_revisions;
+
+CREATE TABLE songs_new (
+    id TEXT PRIMARY KEY,
+    "group" VARCHAR(1000) NOT NULL,
+    title VARCHAR(1000) NOT NULL,
+    release_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    text TEXT,
+    link VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00',
+    deleted_at TIMESTAMP
+);
+
+INSERT INTO songs_new (id, "group", title, release_date, text, link, created_at, deleted_at)
+SELECT id, "group", title, release_date, text, link, created_at, deleted_at FROM songs;
+
+DROP TABLE songs;
+
+ALTER TABLE songs_new RENAME TO songs;
+
+CREATE INDEX songs_created_at_id_idx ON songs (created_at, id);
+CREATE INDEX songs_deleted_at_idx ON songs (deleted_at);
+CREATE UNIQUE INDEX songs_link_active_idx ON songs (link) WHERE deleted_at IS NULL;
+
+INSERT INTO song_revisions SELECT * FROM song_revisions_backup;
+DROP TABLE song_revisions_backup;
+
+DROP TABLE artists;
@@ -0,0 +1,32 @@
+CREATE TABLE artists (
+    id TEXT PRIMARY KEY,
+    name VARCHAR(1000) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00'
+);
+
+-- "Muse", "muse" и "MUSE" - один исполнитель
+CREATE UNIQUE INDEX artists_name_key ON artists (lower(name));
+
+-- uuid v4 собирается из randomblob. при единственном MIN() sqlite берет
+-- остальные колонки из той же строки, поэтому имя берется у самой ранней песни
+INSERT INTO artists (id, name, created_at)
+SELECT lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+        substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    ), name, created_at
+FROM (
+    SELECT trim("group") AS name, MIN(created_at) AS created_at
+    FROM songs
+    GROUP BY lower(trim("group"))
+);
+
+-- sqlite не умеет добавлять NOT NULL колонку с внешним ключом, обязательность проверяет репозиторий
+ALTER TABLE songs ADD COLUMN artist_id TEXT REFERENCES artists (id);
+
+UPDATE songs SET artist_id = (SELECT id FROM artists WHERE lower(artists.name) = lower(trim(songs."group")));
+
+-- group остается копией имени исполнителя: на нем работают фильтры и ревизии
+UPDATE songs SET "group" = (SELECT name FROM artists WHERE artists.id = songs.artist_id);
+
+CREATE INDEX songs_artist_id_idx ON songs (artist_id);
@@ -0,0 +1,17 @@
+CREATE TABLE playlists (
+    id TEXT PRIMARY KEY,
+    name VARCHAR(1000) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- одна песня может стоять в плейлисте несколько раз, поэтому у вхождения свой id.
+-- position идет с 1 без дыр: при удалении песни ее вхождения убираются, а остальные перенумеровываются
+CREATE TABLE playlist_entries (
+    id TEXT PRIMARY KEY,
+    playlist_id TEXT NOT NULL REFERENCES playlists (id) ON DELETE CASCADE,
+    song_id TEXT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL
+);
+
+CREATE INDEX playlist_entries_playlist_id_position_idx ON playlist_entries (playlist_id, position);
+CREATE INDEX playlist_entries_song_id_idx ON playlist_entries (song_id);
@@ -0,0 +1,11 @@
+-- пустые ссылки снова должны быть уникальны, песням без ссылки достается заглушка из id
+UPDATE songs SET link = 'urn:uuid:' || id WHERE link = '';
+
+DROP INDEX IF EXISTS songs_link_active_idx;
+CREATE UNIQUE INDEX songs_link_active_idx ON songs (link) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS songs_enrichment_pending_idx;
+
+ALTER TABLE songs DROP COLUMN enrichment_next_at;
+ALTER TABLE songs DROP COLUMN enrichment_attempts;
+ALTER TABLE songs DROP COLUMN enrichment_status;
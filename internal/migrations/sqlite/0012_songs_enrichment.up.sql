@@ -0,0 +1,11 @@
+-- песня сохраняется сразу, а детали из внешнего апи подтягиваются в фоне.
+-- уже сохраненные песни обогащались при создании, поэтому по умолчанию done
+ALTER TABLE songs ADD COLUMN enrichment_status VARCHAR(16) NOT NULL DEFAULT 'done';
+ALTER TABLE songs ADD COLUMN enrichment_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE songs ADD COLUMN enrichment_next_at TIMESTAMP;
+
+CREATE INDEX songs_enrichment_pending_idx ON songs (enrichment_next_at) WHERE enrichment_status = 'pending';
+
+-- у необогащенной песни ссылки еще нет, пустые ссылки не должны конфликтовать
+DROP INDEX IF EXISTS songs_link_active_idx;
+CREATE UNIQUE INDEX songs_link_active_idx ON songs (link) WHERE deleted_at IS NULL AND link <> '';
@@ -0,0 +1,3 @@
+ALTER TABLE songs DROP COLUMN manual_fields;
+ALTER TABLE songs DROP COLUMN enrichment_force;
+ALTER TABLE songs DROP COLUMN enriched_at;
@@ -0,0 +1,10 @@
+-- ответы провайдеров обогащения, cache_key - провайдер и песня без учета регистра.
+-- not_found - провайдер не знает песню, такие ответы тоже кэшируются
+CREATE TABLE enrichment_cache (
+    cache_key VARCHAR(2100) PRIMARY KEY,
+    release_date TIMESTAMP,
+    text TEXT NOT NULL DEFAULT '',
+    link VARCHAR(500) NOT NULL DEFAULT '',
+    not_found BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+-- default release_date на sqlite не менялся
+SELECT 1;
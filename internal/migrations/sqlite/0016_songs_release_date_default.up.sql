@@ -0,0 +1,3 @@
+-- sqlite не меняет DEFAULT колонки без пересоздания таблицы. Репозиторий всегда пишет release_date сам,
+-- поэтому default не применяется, версия оставлена для совпадения нумерации с postgres
+SELECT 1;
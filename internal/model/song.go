@@ -1,34 +1,562 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"online-song-library/internal/apperr"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Song struct {
-	Id          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Group       string    `gorm:"type:varchar(1000);not null" json:"group"`
-	Title       string    `gorm:"type:varchar(1000);not null" json:"song"`
-	ReleaseDate time.Time `gorm:"type:timestamp;default:current_timestamp" json:"release_date"`
+	Id uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	// Group - копия имени исполнителя ArtistId, репозиторий держит их согласованными
+	Group       string    `gorm:"type:varchar(1000);not null" json:"group" binding:"omitempty,notblank,max=1000"`
+	ArtistId    uuid.UUID `gorm:"type:uuid;index" json:"artist_id"`
+	Title       string    `gorm:"type:varchar(1000);not null" json:"song" binding:"omitempty,notblank,max=1000"`
+	ReleaseDate time.Time `gorm:"type:timestamp" json:"release_date" binding:"release_date"`
 	Text        string    `gorm:"type:text" json:"text"`
-	Link        string    `gorm:"type:varchar(500);unique;not null" json:"link"`
+	Link        string    `gorm:"type:varchar(500);not null;uniqueIndex:songs_link_active_idx,where:deleted_at IS NULL AND link <> ''" json:"link" binding:"omitempty,max=500,http_url"`
+	CreatedAt   time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
+	// Version растет на каждом изменении песни, в http отдается как ETag.
+	// В Update ненулевая версия означает ожидаемую текущую версию
+	Version int `gorm:"not null;default:1" json:"version"`
+	// EnrichmentStatus - состояние фонового обогащения данными внешнего апи, клиент его не меняет.
+	// EnrichmentNextAt - когда повторить неудачную попытку, пусто - как можно скорее
+	EnrichmentStatus   EnrichmentStatus `gorm:"type:varchar(16);not null;default:done" json:"enrichment_status"`
+	EnrichmentAttempts int              `gorm:"not null;default:0" json:"enrichment_attempts"`
+	EnrichmentNextAt   *time.Time       `gorm:"type:timestamp" json:"-"`
+	// EnrichmentForce - следующее обогащение перезапишет и поля из ManualFields
+	EnrichmentForce bool `gorm:"not null;default:false" json:"-"`
+	// EnrichedAt - когда песня последний раз успешно обогащалась
+	EnrichedAt *time.Time `gorm:"type:timestamp" json:"enriched_at"`
+	// ManualFields - обогащаемые поля, которые исправили руками. Обогащение их не трогает без force
+	ManualFields FieldList `gorm:"type:text;not null;default:''" json:"manual_fields,omitempty"`
+	// EnrichmentSources - какой провайдер обогащения записал каждое поле, у ручных правок источника нет
+	EnrichmentSources FieldSources `gorm:"type:text;not null;default:''" json:"enrichment_sources,omitempty"`
+	// Tags и Genres хранятся в отдельных таблицах, репозиторий заполняет их при чтении
+	Tags   []string `gorm:"-" json:"tags"`
+	Genres []string `gorm:"-" json:"genres"`
+	// мягкое удаление: gorm сам добавляет deleted_at IS NULL во все запросы через Model
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamp;index" json:"deleted_at" swaggertype:"string"`
+}
+
+// Artist - исполнитель, имя уникально без учета регистра
+type Artist struct {
+	Id          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(1000);not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
+}
+
+type ArtistDTO struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Album - альбом исполнителя, порядок треков хранится в AlbumTrack
+type Album struct {
+	Id          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Title       string    `gorm:"type:varchar(1000);not null" json:"title"`
+	ArtistId    uuid.UUID `gorm:"type:uuid;not null;index" json:"artist_id"`
+	ReleaseDate time.Time `gorm:"type:timestamp" json:"release_date"`
+	CoverLink   string    `gorm:"type:varchar(500)" json:"cover_link"`
+	CreatedAt   time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
+}
+
+type AlbumDTO struct {
+	Title       string    `json:"title"`
+	ArtistId    uuid.UUID `json:"artist_id"`
+	ReleaseDate time.Time `json:"release_date"`
+	CoverLink   string    `json:"cover_link"`
+}
+
+// AlbumTrack - вхождение песни в альбом. Одна песня может входить в несколько альбомов
+type AlbumTrack struct {
+	AlbumId  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	SongId   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Position int       `gorm:"not null"`
+}
+
+// Track - песня альбома, Position считается с 1 среди песен не из корзины
+type Track struct {
+	Position int `json:"position"`
+	Song
+}
+
+// AlbumDetails - альбом вместе с треками по порядку
+type AlbumDetails struct {
+	Album
+	Tracks []Track `json:"tracks"`
+}
+
+// AlbumTrackDTO - добавление песни в альбом, Position 0 - в конец
+type AlbumTrackDTO struct {
+	SongId   uuid.UUID `json:"song_id"`
+	Position int       `json:"position"`
+}
+
+// AlbumTracksOrder - новый порядок всех треков альбома
+type AlbumTracksOrder struct {
+	SongIds []uuid.UUID `json:"song_ids"`
+}
+
+// Playlist - пользовательская подборка песен
+type Playlist struct {
+	Id        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(1000);not null" json:"name"`
+	CreatedAt time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
+}
+
+type PlaylistDTO struct {
+	Name string `json:"name"`
+}
+
+// PlaylistEntry - вхождение песни в плейлист, одна песня может встречаться несколько раз
+type PlaylistEntry struct {
+	Id         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	PlaylistId uuid.UUID `gorm:"type:uuid;not null"`
+	SongId     uuid.UUID `gorm:"type:uuid;not null"`
+	Position   int       `gorm:"not null"`
+}
+
+// PlaylistDetails - плейлист вместе с песнями по порядку, Position считается с 1
+type PlaylistDetails struct {
+	Playlist
+	Songs []Track `json:"songs"`
+}
+
+// PlaylistSongDTO - добавление песни в плейлист, Position 0 - в конец
+type PlaylistSongDTO struct {
+	SongId   uuid.UUID `json:"song_id"`
+	Position int       `json:"position"`
+}
+
+// PlaylistMoveDTO - новая позиция песни в плейлисте
+type PlaylistMoveDTO struct {
+	Position int `json:"position"`
+}
+
+// SongFields - поля песни, которые меняет PATCH. В отличие от Update пустые значения тоже записываются
+type SongFields struct {
+	Group       string    `json:"group" binding:"required,notblank,max=1000"`
+	Title       string    `json:"song" binding:"required,notblank,max=1000"`
+	ReleaseDate time.Time `json:"release_date" binding:"release_date"`
+	Text        string    `json:"text"`
+	Link        string    `json:"link" binding:"required,max=500,http_url"`
+}
+
+func (s Song) Fields() SongFields {
+	return SongFields{Group: s.Group, Title: s.Title, ReleaseDate: s.ReleaseDate, Text: s.Text, Link: s.Link}
+}
+
+// SetFields переносит поля в песню. Исполнитель ищется заново по Group
+func (s *Song) SetFields(fields SongFields) {
+	if s.Group != fields.Group {
+		s.ArtistId = uuid.Nil
+	}
+	s.Group, s.Title, s.ReleaseDate, s.Text, s.Link = fields.Group, fields.Title, fields.ReleaseDate, fields.Text, fields.Link
+}
+
+type PatchFormat string
+
+const (
+	// PatchMerge - application/merge-patch+json (RFC 7396)
+	PatchMerge PatchFormat = "merge"
+	// PatchJSON - application/json-patch+json (RFC 6902)
+	PatchJSON PatchFormat = "json"
+)
+
+// SongPatch - тело PATCH /songs/:id, применяется к json из SongFields
+type SongPatch struct {
+	Format PatchFormat
+	Body   []byte
+}
+
+// FieldChange - изменение одного поля, Field - имя из json
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// SongPatchResult - песня после PATCH и поля, которые действительно изменились
+type SongPatchResult struct {
+	Song    Song          `json:"song"`
+	Changes []FieldChange `json:"changes"`
 }
 
+// SongDTO проверяется тегами binding до запроса во внешний апи
 type SongDTO struct {
-	Group string `json:"group"`
-	Title string `json:"song"`
+	Group string `json:"group" binding:"required,notblank,max=1000"`
+	Title string `json:"song" binding:"required,notblank,max=1000"`
+	// AlbumId - необязательный альбом, в конец которого добавляется песня.
+	// Дата выхода альбома используется, если внешний апи ее не вернул
+	AlbumId *uuid.UUID `json:"album_id,omitempty"`
+	// Tags и Genres сохраняются в одной транзакции с песней
+	Tags   []string `json:"tags,omitempty" binding:"dive,notblank,max=255"`
+	Genres []string `json:"genres,omitempty" binding:"dive,notblank,max=255"`
+}
+
+// MatchMode - способ сравнения строкового фильтра
+type MatchMode string
+
+const (
+	MatchExact    MatchMode = "exact"
+	MatchPrefix   MatchMode = "prefix"
+	MatchContains MatchMode = "contains"
+	MatchFuzzy    MatchMode = "fuzzy"
+)
+
+// Valid - пустой режим считается exact
+func (m MatchMode) Valid() bool {
+	switch m {
+	case "", MatchExact, MatchPrefix, MatchContains, MatchFuzzy:
+		return true
+	}
+	return false
+}
+
+// EnrichmentStatus - песня создается pending и обогащается в фоне.
+// failed - апи не знает песню или все попытки исчерпаны
+type EnrichmentStatus string
+
+const (
+	EnrichmentPending EnrichmentStatus = "pending"
+	EnrichmentDone    EnrichmentStatus = "done"
+	EnrichmentFailed  EnrichmentStatus = "failed"
+)
+
+func (s EnrichmentStatus) Valid() bool {
+	switch s {
+	case EnrichmentPending, EnrichmentDone, EnrichmentFailed:
+		return true
+	}
+	return false
+}
+
+// EnrichmentUpdate - итог попытки обогащения. Details задан только у успешной:
+// его непустые поля перезаписывают сохраненные, а версия песни растет.
+// Force перезаписывает и поля, исправленные руками
+type EnrichmentUpdate struct {
+	SongId   uuid.UUID
+	Status   EnrichmentStatus
+	Attempts int
+	NextAt   *time.Time
+	Details  *Song
+	Force    bool
 }
 
+// enrichedFields - поля json, которые заполняет обогащение
+var enrichedFields = []string{"release_date", "text", "link"}
+
+// FieldList - имена полей json, в базе хранятся строкой через запятую
+type FieldList []string
+
+func (l FieldList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *FieldList) Scan(src any) error {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("unsupported field list type %T", src)
+	}
+	*l = nil
+	if raw != "" {
+		*l = strings.Split(raw, ",")
+	}
+	return nil
+}
+
+// FieldSources - имя поля json -> провайдер обогащения, в базе хранится json объектом
+type FieldSources map[string]string
+
+func (f FieldSources) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(map[string]string(f))
+	return string(raw), err
+}
+
+func (f *FieldSources) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported field sources type %T", src)
+	}
+	*f = nil
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, f)
+}
+
+// Without - источники без полей fields. Всегда не nil, чтобы Updates записал и пустой набор
+func (f FieldSources) Without(fields FieldList) FieldSources {
+	sources := FieldSources{}
+	for field, provider := range f {
+		if !slices.Contains(fields, field) {
+			sources[field] = provider
+		}
+	}
+	return sources
+}
+
+// EditedFields - ManualFields вместе с обогащаемыми полями, которые edit меняет.
+// partial - правка как в PUT, где пустое значение оставляет поле как есть
+func (s Song) EditedFields(edit Song, partial bool) FieldList {
+	changed := map[string]bool{
+		"release_date": !edit.ReleaseDate.Equal(s.ReleaseDate) && !(partial && edit.ReleaseDate.IsZero()),
+		"text":         edit.Text != s.Text && !(partial && edit.Text == ""),
+		"link":         edit.Link != s.Link && !(partial && edit.Link == ""),
+	}
+	fields := slices.Clone(s.ManualFields)
+	for _, field := range enrichedFields {
+		if changed[field] && !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ApplyEnrichment переносит в песню непустые поля details и их источники из details.EnrichmentSources
+// и возвращает записанные поля. Поля из ManualFields пропускаются, если не force, а с force перестают считаться ручными
+func (s *Song) ApplyEnrichment(details Song, force bool) []string {
+	var applied []string
+	s.EnrichmentSources = s.EnrichmentSources.Without(nil)
+	apply := func(field string, empty bool, set func()) {
+		if empty || (!force && slices.Contains(s.ManualFields, field)) {
+			return
+		}
+		set()
+		applied = append(applied, field)
+		s.ManualFields = slices.DeleteFunc(s.ManualFields, func(f string) bool { return f == field })
+		if source := details.EnrichmentSources[field]; source != "" {
+			s.EnrichmentSources[field] = source
+		} else {
+			delete(s.EnrichmentSources, field)
+		}
+	}
+	apply("release_date", details.ReleaseDate.IsZero(), func() { s.ReleaseDate = details.ReleaseDate })
+	apply("text", details.Text == "", func() { s.Text = details.Text })
+	apply("link", details.Link == "", func() { s.Link = details.Link })
+	return applied
+}
+
+// EnrichmentRequest - повторное обогащение песен по фильтру. Force перезаписывает ручные правки,
+// SkipFailed оставляет в покое песни, детали которых не удалось найти
+type EnrichmentRequest struct {
+	Filter     SongFilter
+	Force      bool
+	SkipFailed bool
+}
+
+// SongAccepted - ответ POST /songs, детали песни подтянет воркер
+type SongAccepted struct {
+	SongId           uuid.UUID        `json:"song_id"`
+	EnrichmentStatus EnrichmentStatus `json:"enrichment_status"`
+}
+
+// EnrichmentQueued - ответ POST /songs/enrich
+type EnrichmentQueued struct {
+	Queued int64 `json:"queued"`
+}
+
+// LabelKind - вид метки песни. Жанры и теги устроены одинаково
+type LabelKind string
+
+const (
+	LabelTag   LabelKind = "tag"
+	LabelGenre LabelKind = "genre"
+)
+
+// LabelMode - как сочетаются несколько меток в фильтре: and - все сразу, or - хотя бы одна
+type LabelMode string
+
+const (
+	LabelModeAnd LabelMode = "and"
+	LabelModeOr  LabelMode = "or"
+)
+
+// Valid - пустой режим считается and
+func (m LabelMode) Valid() bool {
+	switch m {
+	case "", LabelModeAnd, LabelModeOr:
+		return true
+	}
+	return false
+}
+
+// SongLabelsDTO - имена тегов или жанров для песни
+type SongLabelsDTO struct {
+	Names []string `json:"names"`
+}
+
+// SongFilter биндится из query, id и album_id парсятся в контроллере отдельно:
+// gin не умеет биндить uuid.UUID из формы
 type SongFilter struct {
-	Id          *uuid.UUID  `json:"id,omitempty"`
-	Group       *string    `json:"group,omitempty"`
-	Title       *string    `json:"song,omitempty"`
-	ReleaseDate *time.Time `json:"release_date,omitempty"`
-	Text        *string    `json:"text,omitempty"`
-	Link        *string    `json:"link,omitempty"`
+	Id          *uuid.UUID        `json:"id,omitempty" form:"-"`
+	AlbumId     *uuid.UUID        `json:"album_id,omitempty" form:"-"`
+	Group       *string           `json:"group,omitempty" form:"group"`
+	Title       *string           `json:"song,omitempty" form:"title"`
+	ReleaseDate *time.Time        `json:"release_date,omitempty" form:"release_date" time_format:"2006-01-02"`
+	Text        *string           `json:"text,omitempty" form:"text"`
+	Link        *string           `json:"link,omitempty" form:"link"`
+	GroupMatch  MatchMode         `json:"group_match,omitempty" form:"group_match"`
+	TitleMatch  MatchMode         `json:"title_match,omitempty" form:"title_match"`
+	Tags        []string          `json:"tags,omitempty" form:"tag"`
+	TagMode     LabelMode         `json:"tag_mode,omitempty" form:"tag_mode"`
+	Genres      []string          `json:"genres,omitempty" form:"genre"`
+	GenreMode   LabelMode         `json:"genre_mode,omitempty" form:"genre_mode"`
+	Enrichment  *EnrichmentStatus `json:"enrichment_status,omitempty" form:"enrichment_status"`
+	// EnrichedBefore - песни, обогащенные раньше этого момента, а не обогащенные ни разу - созданные раньше
+	EnrichedBefore *time.Time `json:"enriched_before,omitempty" form:"enriched_before" time_format:"2006-01-02"`
+	// Fields - не фильтр, а набор колонок, которые читает репозиторий
+	Fields FieldSet `json:"fields,omitempty" form:"-"`
+}
+
+// songColumns - поля json песни, доступные в fields=, и их колонки.
+// tags и genres хранятся отдельно, у них колонок нет
+var songColumns = map[string]string{
+	"id":                  "id",
+	"group":               "group",
+	"artist_id":           "artist_id",
+	"song":                "title",
+	"release_date":        "release_date",
+	"text":                "text",
+	"link":                "link",
+	"created_at":          "created_at",
+	"version":             "version",
+	"deleted_at":          "deleted_at",
+	"enrichment_status":   "enrichment_status",
+	"enrichment_attempts": "enrichment_attempts",
+	"enriched_at":         "enriched_at",
+	"manual_fields":       "manual_fields",
+	"enrichment_sources":  "enrichment_sources",
+	"tags":                "",
+	"genres":              "",
+}
+
+// FieldSet - поля json песни из параметра fields=, пустой набор - все поля
+type FieldSet []string
+
+// ParseFieldSet разбирает список через запятую, повторы убираются
+func ParseFieldSet(raw string) (FieldSet, error) {
+	var fields FieldSet
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || slices.Contains(fields, field) {
+			continue
+		}
+		if _, ok := songColumns[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
 }
 
+func (f FieldSet) Has(field string) bool {
+	return len(f) == 0 || slices.Contains(f, field)
+}
+
+// Columns - колонки для select вместе с required, nil - все колонки
+func (f FieldSet) Columns(required ...string) []string {
+	if len(f) == 0 {
+		return nil
+	}
+	columns := slices.Clone(required)
+	for _, field := range f {
+		if column := songColumns[field]; column != "" && !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+type CursorDirection string
+
+const (
+	CursorNext CursorDirection = "next"
+	CursorPrev CursorDirection = "prev"
+)
+
+// SongCursor - позиция в выдаче по ключу сортировки (created_at, id).
+// Клиенту отдается в виде непрозрачной строки.
+type SongCursor struct {
+	CreatedAt time.Time       `json:"c"`
+	Id        uuid.UUID       `json:"i"`
+	Direction CursorDirection `json:"d"`
+}
+
+// SongPage - ответ GET /songs в режиме курсорной пагинации
+type SongPage struct {
+	Songs      []Song  `json:"songs"`
+	NextCursor *string `json:"next_cursor"`
+	PrevCursor *string `json:"prev_cursor"`
+}
+
+// ErrorResponse - прежний формат ошибки, отдается клиентам с Accept: application/json
 type ErrorResponse struct {
-    Error string `json:"error"`
-}
\ No newline at end of file
+	Error string `json:"error"`
+}
+
+// Problem - ошибка в формате RFC 7807 (application/problem+json).
+// Type - относительный uri вида /problems/<код>, по нему клиент различает ошибки
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail"`
+	Instance  string              `json:"instance"`
+	RequestId string              `json:"request_id"`
+	Errors    []apperr.FieldError `json:"errors,omitempty"`
+}
+
+// SongSearchResult - песня, найденная полнотекстовым поиском по тексту
+type SongSearchResult struct {
+	Song
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type RevisionOperation string
+
+const (
+	RevisionCreate  RevisionOperation = "create"
+	RevisionUpdate  RevisionOperation = "update"
+	RevisionDelete  RevisionOperation = "delete"
+	RevisionRestore RevisionOperation = "restore"
+	RevisionRevert  RevisionOperation = "revert"
+)
+
+// SongRevision - снимок песни после операции, номера ревизий идут с 1 для каждой песни
+type SongRevision struct {
+	SongId      uuid.UUID         `gorm:"type:uuid;primaryKey" json:"song_id"`
+	Revision    int               `gorm:"primaryKey;autoIncrement:false" json:"revision"`
+	Operation   RevisionOperation `gorm:"type:varchar(16);not null" json:"operation"`
+	Group       string            `gorm:"type:varchar(1000);not null" json:"group"`
+	Title       string            `gorm:"type:varchar(1000);not null" json:"song"`
+	ReleaseDate time.Time         `gorm:"type:timestamp" json:"release_date"`
+	Text        string            `gorm:"type:text" json:"text"`
+	Link        string            `gorm:"type:varchar(500);not null" json:"link"`
+	CreatedAt   time.Time         `gorm:"type:timestamp;not null" json:"created_at"`
+}
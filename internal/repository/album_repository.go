@@ -0,0 +1,413 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"slices"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrArtistHasAlbums - исполнителя нельзя удалить, пока у него есть альбомы
+	ErrArtistHasAlbums = apperr.Conflict("Artist has albums")
+	// ErrUnknownSong - в альбом добавляется несуществующая песня или песня из корзины
+	ErrUnknownSong = apperr.Validation("Unknown song")
+	// ErrDuplicateTrack - песня уже есть в альбоме
+	ErrDuplicateTrack = apperr.Conflict("Song is already in the album")
+	// ErrInvalidPosition - позиция за пределами альбома
+	ErrInvalidPosition = apperr.Validation("Invalid position")
+	// ErrInvalidTrackOrder - новый порядок должен содержать ровно все треки альбома
+	ErrInvalidTrackOrder = apperr.Validation("Track order must list every album track once")
+)
+
+type AlbumRepository interface {
+	CreateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error)
+	UpdateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error)
+	DeleteAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) error
+	GetAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) (model.AlbumDetails, error)
+	GetAlbums(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Album, error)
+	AddAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID, position int) (model.AlbumDetails, error)
+	RemoveAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID) (model.AlbumDetails, error)
+	ReorderAlbumTracks(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUIDs []uuid.UUID) (model.AlbumDetails, error)
+}
+
+func (r *SongRepository) CreateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	select {
+	case <-ctx.Done():
+		return model.Album{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("CreateAlbum sql query:",
+			slog.String("id", album.Id.String()),
+			slog.String("title", album.Title),
+			slog.String("artist_id", album.ArtistId.String()))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if err := checkArtistExists(tx, album.ArtistId); err != nil {
+				return err
+			}
+			return tx.Create(&album).Error
+		})
+	}); err != nil {
+		return model.Album{}, err
+	}
+	return album, nil
+}
+
+// UpdateAlbum как и Update для песен перезаписывает только ненулевые поля
+func (r *SongRepository) UpdateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	select {
+	case <-ctx.Done():
+		return model.Album{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var stored model.Album
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("UpdateAlbum sql query:",
+			slog.String("id", album.Id.String()),
+			slog.String("title", album.Title))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&stored, "id = ?", album.Id); result.Error != nil {
+				return result.Error
+			}
+			if album.ArtistId != uuid.Nil {
+				if err := checkArtistExists(tx, album.ArtistId); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&stored).Updates(&album).Error
+		})
+	}); err != nil {
+		return model.Album{}, err
+	}
+	return stored, nil
+}
+
+// DeleteAlbum удаляет альбом вместе с вхождениями треков, сами песни остаются
+func (r *SongRepository) DeleteAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("DeleteAlbum sql query:",
+			slog.String("id", albumUUID.String()))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.Delete(&model.AlbumTrack{}, "album_id = ?", albumUUID); result.Error != nil {
+				return result.Error
+			}
+			result := tx.Delete(&model.Album{}, "id = ?", albumUUID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return nil
+		})
+	})
+}
+
+func (r *SongRepository) GetAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.AlbumDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetAlbum sql query:",
+			slog.String("id", albumUUID.String()))
+
+		var err error
+		details, err = loadAlbumDetails(d, albumUUID)
+		return err
+	}); err != nil {
+		return model.AlbumDetails{}, err
+	}
+	return details, nil
+}
+
+// GetAlbums возвращает альбомы по дате выхода
+func (r *SongRepository) GetAlbums(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Album, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var albums []model.Album
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetAlbums sql query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+		return d.Order("release_date, id").Limit(limit).Offset(offset).Find(&albums).Error
+	}); err != nil {
+		return nil, err
+	}
+	return albums, nil
+}
+
+// AddAlbumTrack вставляет песню перед треком с номером position, 0 - в конец альбома
+func (r *SongRepository) AddAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID, position int) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.AlbumDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("AddAlbumTrack sql query:",
+			slog.String("album_id", albumUUID.String()),
+			slog.String("song_id", songUUID.String()), slog.Int("position", position))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			active, trashed, err := loadAlbumOrder(tx, albumUUID)
+			if err != nil {
+				return err
+			}
+			if slices.Contains(active, songUUID) || slices.Contains(trashed, songUUID) {
+				return ErrDuplicateTrack
+			}
+			if result := tx.First(&model.Song{}, "id = ?", songUUID); result.Error != nil {
+				if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+					return ErrUnknownSong
+				}
+				return result.Error
+			}
+
+			active, err = insertTrack(active, songUUID, position)
+			if err != nil {
+				return err
+			}
+			if result := tx.Create(&model.AlbumTrack{AlbumId: albumUUID, SongId: songUUID}); result.Error != nil {
+				return result.Error
+			}
+			if err := saveAlbumOrder(tx, albumUUID, append(active, trashed...)); err != nil {
+				return err
+			}
+			details, err = loadAlbumDetails(tx, albumUUID)
+			return err
+		})
+	}); err != nil {
+		return model.AlbumDetails{}, err
+	}
+	return details, nil
+}
+
+func (r *SongRepository) RemoveAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.AlbumDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("RemoveAlbumTrack sql query:",
+			slog.String("album_id", albumUUID.String()),
+			slog.String("song_id", songUUID.String()))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			result := tx.Delete(&model.AlbumTrack{}, "album_id = ? AND song_id = ?", albumUUID, songUUID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+
+			active, trashed, err := loadAlbumOrder(tx, albumUUID)
+			if err != nil {
+				return err
+			}
+			if err := saveAlbumOrder(tx, albumUUID, append(active, trashed...)); err != nil {
+				return err
+			}
+			details, err = loadAlbumDetails(tx, albumUUID)
+			return err
+		})
+	}); err != nil {
+		return model.AlbumDetails{}, err
+	}
+	return details, nil
+}
+
+// ReorderAlbumTracks задает новый порядок треков. Песни альбома из корзины уходят в конец
+func (r *SongRepository) ReorderAlbumTracks(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUIDs []uuid.UUID) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.AlbumDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("ReorderAlbumTracks sql query:",
+			slog.String("album_id", albumUUID.String()), slog.Int("tracks", len(songUUIDs)))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			active, trashed, err := loadAlbumOrder(tx, albumUUID)
+			if err != nil {
+				return err
+			}
+			if !sameTracks(active, songUUIDs) {
+				return ErrInvalidTrackOrder
+			}
+			if err := saveAlbumOrder(tx, albumUUID, append(slices.Clone(songUUIDs), trashed...)); err != nil {
+				return err
+			}
+			details, err = loadAlbumDetails(tx, albumUUID)
+			return err
+		})
+	}); err != nil {
+		return model.AlbumDetails{}, err
+	}
+	return details, nil
+}
+
+// loadAlbumDetails возвращает альбом и его песни не из корзины по порядку
+func loadAlbumDetails(tx *gorm.DB, albumUUID uuid.UUID) (model.AlbumDetails, error) {
+	var details model.AlbumDetails
+	if result := tx.First(&details.Album, "id = ?", albumUUID); result.Error != nil {
+		return model.AlbumDetails{}, result.Error
+	}
+
+	var songs []model.Song
+	if result := tx.Model(&model.Song{}).
+		Joins("JOIN album_tracks ON album_tracks.song_id = songs.id").
+		Where("album_tracks.album_id = ?", albumUUID).
+		Order("album_tracks.position").
+		Find(&songs); result.Error != nil {
+		return model.AlbumDetails{}, result.Error
+	}
+	if err := loadLabels(tx, songPtrs(songs)); err != nil {
+		return model.AlbumDetails{}, err
+	}
+	details.Tracks = numberTracks(songs)
+	return details, nil
+}
+
+// loadAlbumOrder возвращает песни альбома по порядку отдельно для активных и для песен из корзины.
+// Отсутствие альбома - gorm.ErrRecordNotFound
+func loadAlbumOrder(tx *gorm.DB, albumUUID uuid.UUID) ([]uuid.UUID, []uuid.UUID, error) {
+	if result := tx.First(&model.Album{}, "id = ?", albumUUID); result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	var rows []struct {
+		SongId  uuid.UUID
+		Trashed bool
+	}
+	if result := tx.Table("album_tracks").
+		Select("album_tracks.song_id, songs.deleted_at IS NOT NULL AS trashed").
+		Joins("JOIN songs ON songs.id = album_tracks.song_id").
+		Where("album_tracks.album_id = ?", albumUUID).
+		Order("album_tracks.position").
+		Scan(&rows); result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	active, trashed := []uuid.UUID{}, []uuid.UUID{}
+	for _, row := range rows {
+		if row.Trashed {
+			trashed = append(trashed, row.SongId)
+		} else {
+			active = append(active, row.SongId)
+		}
+	}
+	return active, trashed, nil
+}
+
+// saveAlbumOrder перенумеровывает треки альбома с 1 в порядке order
+func saveAlbumOrder(tx *gorm.DB, albumUUID uuid.UUID, order []uuid.UUID) error {
+	for i, songUUID := range order {
+		if result := tx.Model(&model.AlbumTrack{}).
+			Where("album_id = ? AND song_id = ?", albumUUID, songUUID).
+			Update("position", i+1); result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+func checkArtistExists(tx *gorm.DB, artistUUID uuid.UUID) error {
+	if result := tx.First(&model.Artist{}, "id = ?", artistUUID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrUnknownArtist
+		}
+		return result.Error
+	}
+	return nil
+}
+
+// insertTrack вставляет песню перед треком с номером position (с 1), 0 - в конец
+func insertTrack(order []uuid.UUID, songUUID uuid.UUID, position int) ([]uuid.UUID, error) {
+	if position == 0 {
+		return append(order, songUUID), nil
+	}
+	if position < 0 || position > len(order)+1 {
+		return nil, ErrInvalidPosition
+	}
+	return slices.Insert(order, position-1, songUUID), nil
+}
+
+// sameTracks - order содержит ровно те же песни, что и tracks, без повторов
+func sameTracks(tracks []uuid.UUID, order []uuid.UUID) bool {
+	if len(tracks) != len(order) {
+		return false
+	}
+	seen := make(map[uuid.UUID]bool, len(order))
+	for _, songUUID := range order {
+		if seen[songUUID] || !slices.Contains(tracks, songUUID) {
+			return false
+		}
+		seen[songUUID] = true
+	}
+	return true
+}
+
+func numberTracks(songs []model.Song) []model.Track {
+	tracks := make([]model.Track, 0, len(songs))
+	for i, song := range songs {
+		tracks = append(tracks, model.Track{Position: i + 1, Song: song})
+	}
+	return tracks
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/model"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDuplicateArtist - имя исполнителя уникально без учета регистра
+	ErrDuplicateArtist = apperr.Conflict("Artist already exists")
+	// ErrArtistHasSongs - исполнителя нельзя удалить, пока на него ссылаются песни, в том числе из корзины
+	ErrArtistHasSongs = apperr.Conflict("Artist has songs")
+	// ErrUnknownArtist - песня ссылается на несуществующего исполнителя
+	ErrUnknownArtist = apperr.Validation("Unknown artist")
+)
+
+type ArtistRepository interface {
+	CreateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error)
+	UpdateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error)
+	DeleteArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) error
+	GetArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) (model.Artist, error)
+	GetArtists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Artist, error)
+	GetArtistSongs(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID, limit int, offset int) ([]model.Song, error)
+}
+
+func (r *SongRepository) CreateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Artist{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("CreateArtist sql query:",
+			slog.String("id", artist.Id.String()),
+			slog.String("name", artist.Name))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if err := checkArtistNameFree(tx, artist.Name, artist.Id); err != nil {
+				return err
+			}
+			return tx.Create(&artist).Error
+		})
+	}); err != nil {
+		return model.Artist{}, err
+	}
+	return artist, nil
+}
+
+// UpdateArtist перезаписывает ненулевые поля. При переименовании group всех песен исполнителя,
+// включая песни из корзины, меняется вместе с именем. Ревизии песен при этом не пишутся.
+func (r *SongRepository) UpdateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Artist{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var stored model.Artist
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("UpdateArtist sql query:",
+			slog.String("id", artist.Id.String()),
+			slog.String("name", artist.Name))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&stored, "id = ?", artist.Id); result.Error != nil {
+				return result.Error
+			}
+			if artist.Name != "" {
+				if err := checkArtistNameFree(tx, artist.Name, artist.Id); err != nil {
+					return err
+				}
+			}
+
+			if result := tx.Model(&stored).Updates(&artist); result.Error != nil {
+				return result.Error
+			}
+			// смена group меняет песню, поэтому версия тоже растет
+			return tx.Unscoped().Model(&model.Song{}).
+				Where(`artist_id = ? AND "group" <> ?`, stored.Id, stored.Name).
+				Updates(map[string]any{"group": stored.Name, "version": gorm.Expr("version + 1")}).Error
+		})
+	}); err != nil {
+		return model.Artist{}, err
+	}
+	return stored, nil
+}
+
+func (r *SongRepository) DeleteArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("DeleteArtist sql query:",
+			slog.String("id", artistUUID.String()))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			var songs int64
+			if result := tx.Unscoped().Model(&model.Song{}).Where("artist_id = ?", artistUUID).Count(&songs); result.Error != nil {
+				return result.Error
+			}
+			if songs > 0 {
+				return ErrArtistHasSongs
+			}
+			var albums int64
+			if result := tx.Model(&model.Album{}).Where("artist_id = ?", artistUUID).Count(&albums); result.Error != nil {
+				return result.Error
+			}
+			if albums > 0 {
+				return ErrArtistHasAlbums
+			}
+
+			result := tx.Delete(&model.Artist{}, "id = ?", artistUUID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return nil
+		})
+	})
+}
+
+func (r *SongRepository) GetArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) (model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Artist{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var artist model.Artist
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetArtist sql query:",
+			slog.String("id", artistUUID.String()))
+
+		return d.First(&artist, "id = ?", artistUUID).Error
+	}); err != nil {
+		return model.Artist{}, err
+	}
+	return artist, nil
+}
+
+// GetArtists возвращает исполнителей по алфавиту
+func (r *SongRepository) GetArtists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var artists []model.Artist
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetArtists sql query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+		return d.Order("lower(name), id").Limit(limit).Offset(offset).Find(&artists).Error
+	}); err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+// GetArtistSongs возвращает песни исполнителя не из корзины в порядке добавления
+func (r *SongRepository) GetArtistSongs(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID, limit int, offset int) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var songs []model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetArtistSongs sql query:",
+			slog.String("id", artistUUID.String()), slog.Int("limit", limit), slog.Int("offset", offset))
+
+		if result := d.First(&model.Artist{}, "id = ?", artistUUID); result.Error != nil {
+			return result.Error
+		}
+		if result := d.Where("artist_id = ?", artistUUID).
+			Order("created_at, id").
+			Limit(limit).Offset(offset).
+			Find(&songs); result.Error != nil {
+			return result.Error
+		}
+		return loadLabels(d, songPtrs(songs))
+	}); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// resolveArtist привязывает песню к исполнителю: по artist_id, если он задан, иначе по group без учета регистра.
+// Исполнитель, которого еще нет, создается. group всегда переписывается именем исполнителя.
+func resolveArtist(tx *gorm.DB, song *model.Song) error {
+	var artist model.Artist
+	if song.ArtistId != uuid.Nil {
+		if result := tx.First(&artist, "id = ?", song.ArtistId); result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return ErrUnknownArtist
+			}
+			return result.Error
+		}
+	} else {
+		name := strings.TrimSpace(song.Group)
+		// параллельное создание того же исполнителя упирается в unique индекс, а не в ошибку
+		if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Artist{Id: uuid.New(), Name: name}); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.First(&artist, "lower(name) = lower(?)", name); result.Error != nil {
+			return result.Error
+		}
+	}
+	song.ArtistId, song.Group = artist.Id, artist.Name
+	return nil
+}
+
+func checkArtistNameFree(tx *gorm.DB, name string, owner uuid.UUID) error {
+	var taken int64
+	if result := tx.Model(&model.Artist{}).Where("lower(name) = lower(?) AND id <> ?", name, owner).Count(&taken); result.Error != nil {
+		return result.Error
+	}
+	if taken > 0 {
+		return ErrDuplicateArtist
+	}
+	return nil
+}
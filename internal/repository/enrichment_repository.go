@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EnrichmentRepository interface {
+	GetPendingEnrichment(ctx context.Context, log *slog.Logger, due time.Time, limit int) ([]model.Song, error)
+	UpdateEnrichment(ctx context.Context, log *slog.Logger, update model.EnrichmentUpdate) (model.Song, error)
+	QueueEnrichment(ctx context.Context, log *slog.Logger, req model.EnrichmentRequest) (int64, error)
+}
+
+// GetPendingEnrichment возвращает pending песни, чья очередная попытка наступила к due, старые первыми
+func (r *SongRepository) GetPendingEnrichment(ctx context.Context, log *slog.Logger, due time.Time, limit int) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var songs []model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetPendingEnrichment sql query:", slog.Time("due", due), slog.Int("limit", limit))
+
+		return d.Where("enrichment_status = ?", model.EnrichmentPending).
+			Where("enrichment_next_at IS NULL OR enrichment_next_at <= ?", due).
+			Order("created_at, id").
+			Limit(limit).
+			Find(&songs).Error
+	}); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// UpdateEnrichment записывает итог попытки обогащения. Детали из апи увеличивают версию песни и пишут ревизию,
+// ссылка, занятая другой песней, пропускается
+func (r *SongRepository) UpdateEnrichment(ctx context.Context, log *slog.Logger, update model.EnrichmentUpdate) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var song model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("UpdateEnrichment sql query:",
+			slog.String("id", update.SongId.String()), slog.String("status", string(update.Status)), slog.Int("attempts", update.Attempts))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&song, "id = ?", update.SongId); result.Error != nil {
+				return result.Error
+			}
+
+			values := map[string]any{
+				"enrichment_status":   update.Status,
+				"enrichment_attempts": update.Attempts,
+				"enrichment_next_at":  update.NextAt,
+			}
+			// force действует до конца текущего обогащения
+			if update.Status != model.EnrichmentPending {
+				values["enrichment_force"] = false
+			}
+			if update.Details != nil {
+				if err := applyEnrichmentDetails(tx, log, values, song, *update.Details, update.Force); err != nil {
+					return err
+				}
+				values["version"] = song.Version + 1
+			}
+
+			result := tx.Model(&song).Where("version = ?", song.Version).Updates(values)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrVersionConflict
+			}
+			if result := tx.First(&song, "id = ?", update.SongId); result.Error != nil {
+				return result.Error
+			}
+			if update.Details != nil {
+				if err := writeRevision(tx, song, model.RevisionUpdate); err != nil {
+					return err
+				}
+			}
+			return loadLabels(tx, []*model.Song{&song})
+		})
+	}); err != nil {
+		return model.Song{}, err
+	}
+	return song, nil
+}
+
+// QueueEnrichment снова делает pending песни под фильтром и возвращает их число.
+// Песни, которые уже ждут обогащения, не трогаются
+func (r *SongRepository) QueueEnrichment(ctx context.Context, log *slog.Logger, req model.EnrichmentRequest) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var queued int64
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("QueueEnrichment sql query:", slog.Bool("force", req.Force))
+
+		query := applyFilter(d.Model(&model.Song{}), log, req.Filter).
+			Where("enrichment_status <> ?", model.EnrichmentPending)
+		if req.SkipFailed {
+			query = query.Where("enrichment_status <> ?", model.EnrichmentFailed)
+		}
+		result := query.Updates(map[string]any{
+			"enrichment_status":   model.EnrichmentPending,
+			"enrichment_attempts": 0,
+			"enrichment_next_at":  nil,
+			"enrichment_force":    req.Force,
+		})
+		queued = result.RowsAffected
+		return result.Error
+	}); err != nil {
+		return 0, err
+	}
+	return queued, nil
+}
+
+// applyEnrichmentDetails добавляет в values детали из апи, которые можно записать в песню
+func applyEnrichmentDetails(tx *gorm.DB, log *slog.Logger, values map[string]any, song, details model.Song, force bool) error {
+	if details.Link != "" && details.Link != song.Link {
+		err := checkLinkFree(tx, details.Link, song.Id)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrDuplicateLink):
+			log.Warn("enrichment link is used by another song", slog.String("id", song.Id.String()), slog.String("link", details.Link))
+			details.Link = ""
+		default:
+			return err
+		}
+	}
+
+	applied := song.ApplyEnrichment(details, force)
+	for _, field := range applied {
+		switch field {
+		case "release_date":
+			values["release_date"] = song.ReleaseDate
+		case "text":
+			values["text"] = song.Text
+		case "link":
+			values["link"] = song.Link
+		}
+	}
+	if len(song.ManualFields) > 0 && !force {
+		log.Info("enrichment skipped manually edited fields", slog.String("id", song.Id.String()), slog.Any("fields", song.ManualFields))
+	}
+	values["manual_fields"] = song.ManualFields
+	values["enrichment_sources"] = song.EnrichmentSources
+	values["enriched_at"] = time.Now().UTC()
+	return nil
+}
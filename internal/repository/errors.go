@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"errors"
+	"online-song-library/internal/apperr"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound - запись не найдена. Причиной остается gorm.ErrRecordNotFound, errors.Is с ним тоже работает
+	ErrNotFound = apperr.NotFound("Record not found").Wrap(gorm.ErrRecordNotFound)
+	// ErrDuplicateLink - unique-ограничение на songs.link, для хранилищ без бд проверяется вручную
+	ErrDuplicateLink = apperr.Conflict("Link is already used by another song")
+)
+
+// wrapError переводит ошибки gorm и драйвера в доменные. Доменные ошибки и отмену контекста не трогает
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := apperr.As(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound.Wrap(err)
+	case isLinkViolation(err):
+		return ErrDuplicateLink.Wrap(err)
+	}
+	return err
+}
+
+// isLinkViolation узнает нарушение уникальности link по тексту ошибки:
+// postgres называет индекс, sqlite - таблицу и колонку
+func isLinkViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "songs_link_active_idx") || strings.Contains(msg, "UNIQUE constraint failed: songs.link")
+}
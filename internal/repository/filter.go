@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"online-song-library/internal/model"
+	"online-song-library/pkg/textsearch"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// applyTextFilter добавляет условие по строковой колонке в зависимости от режима.
+// На postgres prefix/contains используют ILIKE и trigram индекс, fuzzy - оператор % из pg_trgm.
+// На sqlite LIKE регистронезависим только для ASCII, а fuzzy досчитывается в памяти (см. filterFuzzy).
+func applyTextFilter(query *gorm.DB, column string, value *string, mode model.MatchMode) *gorm.DB {
+	if value == nil {
+		return query
+	}
+	isPostgres := query.Dialector.Name() == "postgres"
+	like := "LIKE"
+	if isPostgres {
+		like = "ILIKE"
+	}
+
+	switch mode {
+	case model.MatchPrefix:
+		return query.Where(column+" "+like+` ? ESCAPE '\'`, likeEscaper.Replace(*value)+"%")
+	case model.MatchContains:
+		return query.Where(column+" "+like+` ? ESCAPE '\'`, "%"+likeEscaper.Replace(*value)+"%")
+	case model.MatchFuzzy:
+		if isPostgres {
+			return query.Where(column+" % ?", *value)
+		}
+		return query
+	default:
+		return query.Where(column+" = ?", *value)
+	}
+}
+
+// orderBySimilarity сортирует fuzzy выдачу postgres по убыванию суммарной похожести
+func orderBySimilarity(query *gorm.DB, filter model.SongFilter) *gorm.DB {
+	var parts []string
+	var vars []any
+	if filter.Group != nil && filter.GroupMatch == model.MatchFuzzy {
+		parts = append(parts, `similarity("group", ?)`)
+		vars = append(vars, *filter.Group)
+	}
+	if filter.Title != nil && filter.TitleMatch == model.MatchFuzzy {
+		parts = append(parts, "similarity(title, ?)")
+		vars = append(vars, *filter.Title)
+	}
+	return query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                strings.Join(parts, " + ") + " DESC",
+		Vars:               vars,
+		WithoutParentheses: true,
+	}})
+}
+
+func hasFuzzy(filter model.SongFilter) bool {
+	return (filter.Group != nil && filter.GroupMatch == model.MatchFuzzy) ||
+		(filter.Title != nil && filter.TitleMatch == model.MatchFuzzy)
+}
+
+// matchText сравнивает строку с фильтром так же, как это делает applyTextFilter на postgres
+func matchText(value string, pattern *string, mode model.MatchMode) bool {
+	if pattern == nil {
+		return true
+	}
+	switch mode {
+	case model.MatchPrefix:
+		return textsearch.HasPrefixFold(value, *pattern)
+	case model.MatchContains:
+		return textsearch.ContainsFold(value, *pattern)
+	case model.MatchFuzzy:
+		return textsearch.Similarity(value, *pattern) >= textsearch.SimilarityThreshold
+	default:
+		return value == *pattern
+	}
+}
+
+func similarityScore(song model.Song, filter model.SongFilter) float64 {
+	score := 0.0
+	if filter.Group != nil && filter.GroupMatch == model.MatchFuzzy {
+		score += textsearch.Similarity(song.Group, *filter.Group)
+	}
+	if filter.Title != nil && filter.TitleMatch == model.MatchFuzzy {
+		score += textsearch.Similarity(song.Title, *filter.Title)
+	}
+	return score
+}
+
+// filterFuzzy отбрасывает непохожие песни и сортирует остальные по похожести
+func filterFuzzy(songs []model.Song, filter model.SongFilter) []model.Song {
+	matched := []model.Song{}
+	for _, song := range songs {
+		if matchText(song.Group, filter.Group, filter.GroupMatch) && matchText(song.Title, filter.Title, filter.TitleMatch) {
+			matched = append(matched, song)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return similarityScore(matched[i], filter) > similarityScore(matched[j], filter)
+	})
+	return matched
+}
+
+// paginate повторяет семантику gorm: limit < 0 - без ограничения, offset <= 0 - без смещения
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset > 0 {
+		if offset >= len(items) {
+			return []T{}
+		}
+		items = items[offset:]
+	}
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
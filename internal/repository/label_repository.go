@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LabelRepository interface {
+	AddSongLabels(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, names []string) (model.Song, error)
+	RemoveSongLabel(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, name string) (model.Song, error)
+	GetLabels(ctx context.Context, log *slog.Logger, kind model.LabelKind) ([]string, error)
+}
+
+// labelTables - справочник и таблица связи для вида метки
+type labelTables struct {
+	table  string
+	join   string
+	column string
+}
+
+var labelKinds = map[model.LabelKind]labelTables{
+	model.LabelTag:   {table: "tags", join: "song_tags", column: "tag_id"},
+	model.LabelGenre: {table: "genres", join: "song_genres", column: "genre_id"},
+}
+
+type label struct {
+	Id   uuid.UUID
+	Name string
+}
+
+// AddSongLabels добавляет песне метки, которых у нее еще нет. Новые имена попадают в справочник
+func (r *SongRepository) AddSongLabels(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, names []string) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	t := labelKinds[kind]
+	var song model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("AddSongLabels sql query:",
+			slog.String("id", songUUID.String()), slog.String("kind", string(kind)), slog.Any("names", names))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&song, "id = ?", songUUID); result.Error != nil {
+				return result.Error
+			}
+
+			for _, name := range normalizeLabels(names) {
+				if result := tx.Table(t.table).Clauses(clause.OnConflict{DoNothing: true}).
+					Create(&label{Id: uuid.New(), Name: name}); result.Error != nil {
+					return result.Error
+				}
+				var stored label
+				if result := tx.Table(t.table).Where("lower(name) = lower(?)", name).Take(&stored); result.Error != nil {
+					return result.Error
+				}
+				if result := tx.Table(t.join).Clauses(clause.OnConflict{DoNothing: true}).
+					Create(map[string]any{"song_id": songUUID, t.column: stored.Id}); result.Error != nil {
+					return result.Error
+				}
+			}
+			return loadLabels(tx, []*model.Song{&song})
+		})
+	}); err != nil {
+		return model.Song{}, err
+	}
+	return song, nil
+}
+
+// RemoveSongLabel снимает метку с песни, имя из справочника не удаляется
+func (r *SongRepository) RemoveSongLabel(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, name string) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	t := labelKinds[kind]
+	var song model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("RemoveSongLabel sql query:",
+			slog.String("id", songUUID.String()), slog.String("kind", string(kind)), slog.String("name", name))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&song, "id = ?", songUUID); result.Error != nil {
+				return result.Error
+			}
+
+			result := tx.Exec("DELETE FROM "+t.join+" WHERE song_id = ? AND "+t.column+" IN (SELECT id FROM "+t.table+" WHERE lower(name) = lower(?))",
+				songUUID, strings.TrimSpace(name))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return loadLabels(tx, []*model.Song{&song})
+		})
+	}); err != nil {
+		return model.Song{}, err
+	}
+	return song, nil
+}
+
+// GetLabels возвращает все имена справочника по алфавиту
+func (r *SongRepository) GetLabels(ctx context.Context, log *slog.Logger, kind model.LabelKind) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	names := []string{}
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetLabels sql query:", slog.String("kind", string(kind)))
+
+		return d.Table(labelKinds[kind].table).Order("lower(name)").Pluck("name", &names).Error
+	}); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// loadLabels заполняет Tags и Genres песен, по одному запросу на вид метки для всей выдачи
+func loadLabels(tx *gorm.DB, songs []*model.Song) error {
+	if len(songs) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(songs))
+	byId := make(map[uuid.UUID][]*model.Song, len(songs))
+	for _, song := range songs {
+		song.Tags, song.Genres = []string{}, []string{}
+		ids = append(ids, song.Id)
+		byId[song.Id] = append(byId[song.Id], song)
+	}
+
+	for kind, t := range labelKinds {
+		var rows []struct {
+			SongId uuid.UUID
+			Name   string
+		}
+		if result := tx.Table(t.join).
+			Select(t.join+".song_id, "+t.table+".name").
+			Joins("JOIN "+t.table+" ON "+t.table+".id = "+t.join+"."+t.column).
+			Where(t.join+".song_id IN ?", ids).
+			Order("lower(" + t.table + ".name)").
+			Scan(&rows); result.Error != nil {
+			return result.Error
+		}
+		for _, row := range rows {
+			for _, song := range byId[row.SongId] {
+				if kind == model.LabelTag {
+					song.Tags = append(song.Tags, row.Name)
+				} else {
+					song.Genres = append(song.Genres, row.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyLabelFilter: в режиме or песне достаточно одной метки из списка, в режиме and нужны все
+func applyLabelFilter(query *gorm.DB, kind model.LabelKind, names []string, mode model.LabelMode) *gorm.DB {
+	t := labelKinds[kind]
+	names = normalizeLabels(names)
+	if len(names) == 0 {
+		return query
+	}
+
+	// lower с обеих сторон: в sqlite lower работает только с ascii, как и при записи
+	args := make([]any, 0, len(names)+1)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("lower(?), ", len(names)), ", ")
+	sub := "SELECT " + t.join + ".song_id FROM " + t.join +
+		" JOIN " + t.table + " ON " + t.table + ".id = " + t.join + "." + t.column +
+		" WHERE lower(" + t.table + ".name) IN (" + placeholders + ")"
+	if mode == model.LabelModeOr {
+		return query.Where("id IN ("+sub+")", args...)
+	}
+	args = append(args, len(names))
+	return query.Where("id IN ("+sub+" GROUP BY "+t.join+".song_id HAVING COUNT(*) = ?)", args...)
+}
+
+// normalizeLabels обрезает пробелы и убирает пустые имена и повторы без учета регистра
+func normalizeLabels(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.ContainsFunc(normalized, func(n string) bool { return strings.EqualFold(n, name) }) {
+			continue
+		}
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+func songPtrs(songs []model.Song) []*model.Song {
+	ptrs := make([]*model.Song, len(songs))
+	for i := range songs {
+		ptrs[i] = &songs[i]
+	}
+	return ptrs
+}
+
+func searchResultPtrs(results []model.SongSearchResult) []*model.Song {
+	ptrs := make([]*model.Song, len(results))
+	for i := range results {
+		ptrs[i] = &results[i].Song
+	}
+	return ptrs
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (r *MemorySongRepository) CreateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	select {
+	case <-ctx.Done():
+		return model.Album{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("CreateAlbum in-memory query:",
+		slog.String("id", album.Id.String()),
+		slog.String("title", album.Title),
+		slog.String("artist_id", album.ArtistId.String()))
+
+	if r.artistIndexOf(album.ArtistId) == -1 {
+		return model.Album{}, ErrUnknownArtist
+	}
+	if album.CreatedAt.IsZero() {
+		album.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	}
+	r.albums = append(r.albums, album)
+	return album, nil
+}
+
+func (r *MemorySongRepository) UpdateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	select {
+	case <-ctx.Done():
+		return model.Album{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("UpdateAlbum in-memory query:",
+		slog.String("id", album.Id.String()),
+		slog.String("title", album.Title))
+
+	i := r.albumIndexOf(album.Id)
+	if i == -1 {
+		return model.Album{}, ErrNotFound
+	}
+	if album.ArtistId != uuid.Nil && r.artistIndexOf(album.ArtistId) == -1 {
+		return model.Album{}, ErrUnknownArtist
+	}
+
+	stored := &r.albums[i]
+	if album.Title != "" {
+		stored.Title = album.Title
+	}
+	if album.ArtistId != uuid.Nil {
+		stored.ArtistId = album.ArtistId
+	}
+	if !album.ReleaseDate.IsZero() {
+		stored.ReleaseDate = album.ReleaseDate
+	}
+	if album.CoverLink != "" {
+		stored.CoverLink = album.CoverLink
+	}
+	return *stored, nil
+}
+
+func (r *MemorySongRepository) DeleteAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("DeleteAlbum in-memory query:",
+		slog.String("id", albumUUID.String()))
+
+	i := r.albumIndexOf(albumUUID)
+	if i == -1 {
+		return ErrNotFound
+	}
+	r.albums = append(r.albums[:i], r.albums[i+1:]...)
+	delete(r.albumTracks, albumUUID)
+	return nil
+}
+
+func (r *MemorySongRepository) GetAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetAlbum in-memory query:",
+		slog.String("id", albumUUID.String()))
+
+	return r.albumDetails(albumUUID)
+}
+
+func (r *MemorySongRepository) GetAlbums(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Album, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetAlbums in-memory query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+	albums := append([]model.Album{}, r.albums...)
+	sort.Slice(albums, func(i, j int) bool {
+		return compareKeyset(albums[i].ReleaseDate, albums[i].Id, albums[j].ReleaseDate, albums[j].Id) < 0
+	})
+	return paginate(albums, limit, offset), nil
+}
+
+func (r *MemorySongRepository) AddAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID, position int) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("AddAlbumTrack in-memory query:",
+		slog.String("album_id", albumUUID.String()),
+		slog.String("song_id", songUUID.String()), slog.Int("position", position))
+
+	if r.albumIndexOf(albumUUID) == -1 {
+		return model.AlbumDetails{}, ErrNotFound
+	}
+	if slices.Contains(r.albumTracks[albumUUID], songUUID) {
+		return model.AlbumDetails{}, ErrDuplicateTrack
+	}
+	if r.activeIndexOf(songUUID) == -1 {
+		return model.AlbumDetails{}, ErrUnknownSong
+	}
+
+	active, trashed := r.albumOrder(albumUUID)
+	active, err := insertTrack(active, songUUID, position)
+	if err != nil {
+		return model.AlbumDetails{}, err
+	}
+	r.albumTracks[albumUUID] = append(active, trashed...)
+	return r.albumDetails(albumUUID)
+}
+
+func (r *MemorySongRepository) RemoveAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("RemoveAlbumTrack in-memory query:",
+		slog.String("album_id", albumUUID.String()),
+		slog.String("song_id", songUUID.String()))
+
+	tracks := r.albumTracks[albumUUID]
+	i := slices.Index(tracks, songUUID)
+	if r.albumIndexOf(albumUUID) == -1 || i == -1 {
+		return model.AlbumDetails{}, ErrNotFound
+	}
+	r.albumTracks[albumUUID] = slices.Delete(tracks, i, i+1)
+
+	active, trashed := r.albumOrder(albumUUID)
+	r.albumTracks[albumUUID] = append(active, trashed...)
+	return r.albumDetails(albumUUID)
+}
+
+func (r *MemorySongRepository) ReorderAlbumTracks(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUIDs []uuid.UUID) (model.AlbumDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.AlbumDetails{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("ReorderAlbumTracks in-memory query:",
+		slog.String("album_id", albumUUID.String()), slog.Int("tracks", len(songUUIDs)))
+
+	if r.albumIndexOf(albumUUID) == -1 {
+		return model.AlbumDetails{}, ErrNotFound
+	}
+	active, trashed := r.albumOrder(albumUUID)
+	if !sameTracks(active, songUUIDs) {
+		return model.AlbumDetails{}, ErrInvalidTrackOrder
+	}
+	r.albumTracks[albumUUID] = append(slices.Clone(songUUIDs), trashed...)
+	return r.albumDetails(albumUUID)
+}
+
+// albumDetails вызывается под r.mu
+func (r *MemorySongRepository) albumDetails(albumUUID uuid.UUID) (model.AlbumDetails, error) {
+	i := r.albumIndexOf(albumUUID)
+	if i == -1 {
+		return model.AlbumDetails{}, ErrNotFound
+	}
+
+	songs := []model.Song{}
+	for _, songUUID := range r.albumTracks[albumUUID] {
+		if j := r.activeIndexOf(songUUID); j != -1 {
+			songs = append(songs, r.songs[j])
+		}
+	}
+	return model.AlbumDetails{Album: r.albums[i], Tracks: numberTracks(songs)}, nil
+}
+
+// albumOrder разделяет песни альбома на активные и из корзины, сохраняя порядок
+func (r *MemorySongRepository) albumOrder(albumUUID uuid.UUID) ([]uuid.UUID, []uuid.UUID) {
+	active, trashed := []uuid.UUID{}, []uuid.UUID{}
+	for _, songUUID := range r.albumTracks[albumUUID] {
+		if r.activeIndexOf(songUUID) != -1 {
+			active = append(active, songUUID)
+		} else {
+			trashed = append(trashed, songUUID)
+		}
+	}
+	return active, trashed
+}
+
+func (r *MemorySongRepository) albumIndexOf(id uuid.UUID) int {
+	for i := range r.albums {
+		if r.albums[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
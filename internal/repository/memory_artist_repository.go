@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (r *MemorySongRepository) CreateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Artist{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("CreateArtist in-memory query:",
+		slog.String("id", artist.Id.String()),
+		slog.String("name", artist.Name))
+
+	if r.artistNameTaken(artist.Name, artist.Id) {
+		return model.Artist{}, ErrDuplicateArtist
+	}
+	if artist.CreatedAt.IsZero() {
+		artist.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	}
+	r.artists = append(r.artists, artist)
+	return artist, nil
+}
+
+func (r *MemorySongRepository) UpdateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Artist{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("UpdateArtist in-memory query:",
+		slog.String("id", artist.Id.String()),
+		slog.String("name", artist.Name))
+
+	i := r.artistIndexOf(artist.Id)
+	if i == -1 {
+		return model.Artist{}, ErrNotFound
+	}
+	if artist.Name != "" && r.artistNameTaken(artist.Name, artist.Id) {
+		return model.Artist{}, ErrDuplicateArtist
+	}
+
+	stored := &r.artists[i]
+	if artist.Name != "" {
+		stored.Name = artist.Name
+	}
+	if artist.Description != "" {
+		stored.Description = artist.Description
+	}
+	for j := range r.songs {
+		if r.songs[j].ArtistId == stored.Id && r.songs[j].Group != stored.Name {
+			r.songs[j].Group = stored.Name
+			r.songs[j].Version++
+		}
+	}
+	return *stored, nil
+}
+
+func (r *MemorySongRepository) DeleteArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("DeleteArtist in-memory query:",
+		slog.String("id", artistUUID.String()))
+
+	i := r.artistIndexOf(artistUUID)
+	if i == -1 {
+		return ErrNotFound
+	}
+	for _, song := range r.songs {
+		if song.ArtistId == artistUUID {
+			return ErrArtistHasSongs
+		}
+	}
+	for _, album := range r.albums {
+		if album.ArtistId == artistUUID {
+			return ErrArtistHasAlbums
+		}
+	}
+	r.artists = append(r.artists[:i], r.artists[i+1:]...)
+	return nil
+}
+
+func (r *MemorySongRepository) GetArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) (model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Artist{}, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetArtist in-memory query:",
+		slog.String("id", artistUUID.String()))
+
+	i := r.artistIndexOf(artistUUID)
+	if i == -1 {
+		return model.Artist{}, ErrNotFound
+	}
+	return r.artists[i], nil
+}
+
+func (r *MemorySongRepository) GetArtists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Artist, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetArtists in-memory query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+	artists := append([]model.Artist{}, r.artists...)
+	sort.Slice(artists, func(i, j int) bool {
+		if c := strings.Compare(strings.ToLower(artists[i].Name), strings.ToLower(artists[j].Name)); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(artists[i].Id[:], artists[j].Id[:]) < 0
+	})
+	return paginate(artists, limit, offset), nil
+}
+
+func (r *MemorySongRepository) GetArtistSongs(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID, limit int, offset int) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetArtistSongs in-memory query:",
+		slog.String("id", artistUUID.String()), slog.Int("limit", limit), slog.Int("offset", offset))
+
+	if r.artistIndexOf(artistUUID) == -1 {
+		return nil, ErrNotFound
+	}
+	songs := []model.Song{}
+	for _, song := range r.active() {
+		if song.ArtistId == artistUUID {
+			songs = append(songs, song)
+		}
+	}
+	sort.Slice(songs, func(i, j int) bool {
+		return compareKeyset(songs[i].CreatedAt, songs[i].Id, songs[j].CreatedAt, songs[j].Id) < 0
+	})
+	return paginate(songs, limit, offset), nil
+}
+
+// resolveArtist повторяет resolveArtist для бд, вызывается под r.mu.Lock
+func (r *MemorySongRepository) resolveArtist(song *model.Song) error {
+	var i int
+	if song.ArtistId != uuid.Nil {
+		if i = r.artistIndexOf(song.ArtistId); i == -1 {
+			return ErrUnknownArtist
+		}
+	} else {
+		name := strings.TrimSpace(song.Group)
+		if i = r.artistIndexByName(name); i == -1 {
+			r.artists = append(r.artists, model.Artist{
+				Id:        uuid.New(),
+				Name:      name,
+				CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+			})
+			i = len(r.artists) - 1
+		}
+	}
+	song.ArtistId, song.Group = r.artists[i].Id, r.artists[i].Name
+	return nil
+}
+
+func (r *MemorySongRepository) artistIndexOf(id uuid.UUID) int {
+	for i := range r.artists {
+		if r.artists[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *MemorySongRepository) artistIndexByName(name string) int {
+	for i := range r.artists {
+		if strings.EqualFold(r.artists[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *MemorySongRepository) artistNameTaken(name string, owner uuid.UUID) bool {
+	i := r.artistIndexByName(name)
+	return i != -1 && r.artists[i].Id != owner
+}
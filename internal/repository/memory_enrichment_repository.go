@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+	"slices"
+	"time"
+)
+
+func (r *MemorySongRepository) GetPendingEnrichment(ctx context.Context, log *slog.Logger, due time.Time, limit int) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("GetPendingEnrichment in-memory query:", slog.Time("due", due), slog.Int("limit", limit))
+
+	// r.songs хранится в порядке создания
+	var songs []model.Song
+	for _, song := range r.active() {
+		if len(songs) == limit {
+			break
+		}
+		if song.EnrichmentStatus == model.EnrichmentPending && (song.EnrichmentNextAt == nil || !song.EnrichmentNextAt.After(due)) {
+			songs = append(songs, song)
+		}
+	}
+	return songs, nil
+}
+
+func (r *MemorySongRepository) UpdateEnrichment(ctx context.Context, log *slog.Logger, update model.EnrichmentUpdate) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("UpdateEnrichment in-memory query:",
+		slog.String("id", update.SongId.String()), slog.String("status", string(update.Status)), slog.Int("attempts", update.Attempts))
+
+	i := r.activeIndexOf(update.SongId)
+	if i == -1 {
+		return model.Song{}, ErrNotFound
+	}
+
+	stored := r.songs[i]
+	stored.EnrichmentStatus = update.Status
+	stored.EnrichmentAttempts = update.Attempts
+	stored.EnrichmentNextAt = update.NextAt
+	if update.Status != model.EnrichmentPending {
+		stored.EnrichmentForce = false
+	}
+	if update.Details != nil {
+		details := *update.Details
+		if details.Link != "" && r.linkTaken(details.Link, stored.Id) {
+			log.Warn("enrichment link is used by another song", slog.String("id", stored.Id.String()), slog.String("link", details.Link))
+			details.Link = ""
+		}
+		stored.ManualFields = slices.Clone(stored.ManualFields)
+		stored.ApplyEnrichment(details, update.Force)
+		enrichedAt := time.Now().UTC()
+		stored.EnrichedAt = &enrichedAt
+		stored.Version++
+		r.writeRevision(stored, model.RevisionUpdate)
+	}
+	r.songs[i] = stored
+
+	return stored, nil
+}
+
+func (r *MemorySongRepository) QueueEnrichment(ctx context.Context, log *slog.Logger, req model.EnrichmentRequest) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("QueueEnrichment in-memory query:", slog.Bool("force", req.Force))
+
+	var queued int64
+	for i, song := range r.songs {
+		if song.DeletedAt.Valid || song.EnrichmentStatus == model.EnrichmentPending || !r.matchSong(song, req.Filter) {
+			continue
+		}
+		if req.SkipFailed && song.EnrichmentStatus == model.EnrichmentFailed {
+			continue
+		}
+		song.EnrichmentStatus = model.EnrichmentPending
+		song.EnrichmentAttempts = 0
+		song.EnrichmentNextAt = nil
+		song.EnrichmentForce = req.Force
+		r.songs[i] = song
+		queued++
+	}
+	return queued, nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+func (r *MemorySongRepository) AddSongLabels(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, names []string) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("AddSongLabels in-memory query:",
+		slog.String("id", songUUID.String()), slog.String("kind", string(kind)), slog.Any("names", names))
+
+	i := r.activeIndexOf(songUUID)
+	if i == -1 {
+		return model.Song{}, ErrNotFound
+	}
+
+	current := songLabels(r.songs[i], kind)
+	for _, name := range normalizeLabels(names) {
+		name = r.registerLabel(kind, name)
+		if !containsLabel(current, name) {
+			current = append(current, name)
+		}
+	}
+	setSongLabels(&r.songs[i], kind, current)
+	return r.songs[i], nil
+}
+
+func (r *MemorySongRepository) RemoveSongLabel(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, name string) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("RemoveSongLabel in-memory query:",
+		slog.String("id", songUUID.String()), slog.String("kind", string(kind)), slog.String("name", name))
+
+	i := r.activeIndexOf(songUUID)
+	if i == -1 {
+		return model.Song{}, ErrNotFound
+	}
+
+	name = strings.TrimSpace(name)
+	current := songLabels(r.songs[i], kind)
+	if !containsLabel(current, name) {
+		return model.Song{}, ErrNotFound
+	}
+	setSongLabels(&r.songs[i], kind, slices.DeleteFunc(current, func(n string) bool { return strings.EqualFold(n, name) }))
+	return r.songs[i], nil
+}
+
+func (r *MemorySongRepository) GetLabels(ctx context.Context, log *slog.Logger, kind model.LabelKind) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetLabels in-memory query:", slog.String("kind", string(kind)))
+
+	names := slices.Clone(r.labels[kind])
+	sortLabels(names)
+	if names == nil {
+		names = []string{}
+	}
+	return names, nil
+}
+
+// registerLabel возвращает имя из справочника, если метка уже есть, иначе добавляет новую.
+// Вызывается под r.mu.Lock
+func (r *MemorySongRepository) registerLabel(kind model.LabelKind, name string) string {
+	for _, stored := range r.labels[kind] {
+		if strings.EqualFold(stored, name) {
+			return stored
+		}
+	}
+	r.labels[kind] = append(r.labels[kind], name)
+	return name
+}
+
+// matchLabels повторяет applyLabelFilter для песен в памяти
+func matchLabels(labels []string, names []string, mode model.LabelMode) bool {
+	names = normalizeLabels(names)
+	if len(names) == 0 {
+		return true
+	}
+	if mode == model.LabelModeOr {
+		return slices.ContainsFunc(names, func(name string) bool { return containsLabel(labels, name) })
+	}
+	for _, name := range names {
+		if !containsLabel(labels, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// songLabels возвращает копию меток, чтобы не менять срез, который уже отдали наружу
+func songLabels(song model.Song, kind model.LabelKind) []string {
+	if kind == model.LabelTag {
+		return slices.Clone(song.Tags)
+	}
+	return slices.Clone(song.Genres)
+}
+
+func setSongLabels(song *model.Song, kind model.LabelKind, labels []string) {
+	if labels == nil {
+		labels = []string{}
+	}
+	sortLabels(labels)
+	if kind == model.LabelTag {
+		song.Tags = labels
+	} else {
+		song.Genres = labels
+	}
+}
+
+func containsLabel(labels []string, name string) bool {
+	return slices.ContainsFunc(labels, func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// sortLabels сортирует так же, как order by lower(name)
+func sortLabels(labels []string) {
+	slices.SortFunc(labels, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (r *MemorySongRepository) CreatePlaylist(ctx context.Context, log *slog.Logger, playlist model.Playlist) (model.Playlist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Playlist{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("CreatePlaylist in-memory query:",
+		slog.String("id", playlist.Id.String()),
+		slog.String("name", playlist.Name))
+
+	if playlist.CreatedAt.IsZero() {
+		playlist.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	}
+	r.playlists = append(r.playlists, playlist)
+	return playlist, nil
+}
+
+func (r *MemorySongRepository) RenamePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, name string) (model.Playlist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Playlist{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("RenamePlaylist in-memory query:",
+		slog.String("id", playlistUUID.String()),
+		slog.String("name", name))
+
+	i := r.playlistIndexOf(playlistUUID)
+	if i == -1 {
+		return model.Playlist{}, ErrNotFound
+	}
+	r.playlists[i].Name = name
+	return r.playlists[i], nil
+}
+
+func (r *MemorySongRepository) DeletePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("DeletePlaylist in-memory query:",
+		slog.String("id", playlistUUID.String()))
+
+	i := r.playlistIndexOf(playlistUUID)
+	if i == -1 {
+		return ErrNotFound
+	}
+	r.playlists = append(r.playlists[:i], r.playlists[i+1:]...)
+	delete(r.playlistSongs, playlistUUID)
+	return nil
+}
+
+func (r *MemorySongRepository) GetPlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetPlaylist in-memory query:",
+		slog.String("id", playlistUUID.String()))
+
+	return r.playlistDetails(playlistUUID)
+}
+
+func (r *MemorySongRepository) GetPlaylists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Playlist, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetPlaylists in-memory query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+	playlists := append([]model.Playlist{}, r.playlists...)
+	sort.Slice(playlists, func(i, j int) bool {
+		return compareKeyset(playlists[i].CreatedAt, playlists[i].Id, playlists[j].CreatedAt, playlists[j].Id) < 0
+	})
+	return paginate(playlists, limit, offset), nil
+}
+
+func (r *MemorySongRepository) AddPlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, songUUID uuid.UUID, position int) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("AddPlaylistSong in-memory query:",
+		slog.String("playlist_id", playlistUUID.String()),
+		slog.String("song_id", songUUID.String()), slog.Int("position", position))
+
+	if r.playlistIndexOf(playlistUUID) == -1 {
+		return model.PlaylistDetails{}, ErrNotFound
+	}
+	if r.activeIndexOf(songUUID) == -1 {
+		return model.PlaylistDetails{}, ErrUnknownSong
+	}
+
+	songs, err := insertTrack(slices.Clone(r.playlistSongs[playlistUUID]), songUUID, position)
+	if err != nil {
+		return model.PlaylistDetails{}, err
+	}
+	r.playlistSongs[playlistUUID] = songs
+	return r.playlistDetails(playlistUUID)
+}
+
+func (r *MemorySongRepository) RemovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, position int) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("RemovePlaylistSong in-memory query:",
+		slog.String("playlist_id", playlistUUID.String()), slog.Int("position", position))
+
+	songs := r.playlistSongs[playlistUUID]
+	if r.playlistIndexOf(playlistUUID) == -1 || position < 1 || position > len(songs) {
+		return model.PlaylistDetails{}, ErrNotFound
+	}
+	r.playlistSongs[playlistUUID] = slices.Delete(songs, position-1, position)
+	return r.playlistDetails(playlistUUID)
+}
+
+func (r *MemorySongRepository) MovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, from int, to int) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("MovePlaylistSong in-memory query:",
+		slog.String("playlist_id", playlistUUID.String()), slog.Int("from", from), slog.Int("to", to))
+
+	if r.playlistIndexOf(playlistUUID) == -1 {
+		return model.PlaylistDetails{}, ErrNotFound
+	}
+	songs, err := moveEntry(slices.Clone(r.playlistSongs[playlistUUID]), from, to)
+	if err != nil {
+		return model.PlaylistDetails{}, err
+	}
+	r.playlistSongs[playlistUUID] = songs
+	return r.playlistDetails(playlistUUID)
+}
+
+// removeSongFromPlaylists вызывается под r.mu.Lock
+func (r *MemorySongRepository) removeSongFromPlaylists(songUUID uuid.UUID) {
+	for playlistUUID, songs := range r.playlistSongs {
+		r.playlistSongs[playlistUUID] = slices.DeleteFunc(songs, func(id uuid.UUID) bool { return id == songUUID })
+	}
+}
+
+// playlistDetails вызывается под r.mu
+func (r *MemorySongRepository) playlistDetails(playlistUUID uuid.UUID) (model.PlaylistDetails, error) {
+	i := r.playlistIndexOf(playlistUUID)
+	if i == -1 {
+		return model.PlaylistDetails{}, ErrNotFound
+	}
+
+	songs := []model.Song{}
+	for _, songUUID := range r.playlistSongs[playlistUUID] {
+		if j := r.activeIndexOf(songUUID); j != -1 {
+			songs = append(songs, r.songs[j])
+		}
+	}
+	return model.PlaylistDetails{Playlist: r.playlists[i], Songs: numberTracks(songs)}, nil
+}
+
+func (r *MemorySongRepository) playlistIndexOf(id uuid.UUID) int {
+	for i := range r.playlists {
+		if r.playlists[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,586 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/model"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MemorySongRepository хранит песни в памяти процесса.
+// Используется в тестах и для локального запуска без postgres.
+type MemorySongRepository struct {
+	mu sync.RWMutex
+	// txMu держит WithinTx до конца fn, записи вне транзакции ждут его
+	txMu      sync.Mutex
+	songs     []model.Song
+	artists   []model.Artist
+	revisions map[uuid.UUID][]model.SongRevision
+	albums    []model.Album
+	// albumTracks - песни альбома по порядку
+	albumTracks map[uuid.UUID][]uuid.UUID
+	// labels - справочники тегов и жанров, имена хранятся в том виде, в каком пришли впервые
+	labels    map[model.LabelKind][]string
+	playlists []model.Playlist
+	// playlistSongs - песни плейлиста по порядку, одна песня может встречаться несколько раз
+	playlistSongs map[uuid.UUID][]uuid.UUID
+}
+
+func NewMemorySongRepository() *MemorySongRepository {
+	return &MemorySongRepository{
+		revisions:     make(map[uuid.UUID][]model.SongRevision),
+		albumTracks:   make(map[uuid.UUID][]uuid.UUID),
+		labels:        make(map[model.LabelKind][]string),
+		playlistSongs: make(map[uuid.UUID][]uuid.UUID),
+	}
+}
+
+func (r *MemorySongRepository) Create(ctx context.Context, log *slog.Logger, song model.Song) (uuid.UUID, error) {
+	select {
+	case <-ctx.Done():
+		return uuid.Nil, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("Create in-memory query:",
+		slog.String("id", song.Id.String()),
+		slog.String("gruop", song.Group),
+		slog.String("title", song.Title),
+		slog.Time("release_date", song.ReleaseDate),
+		slog.String("link", song.Link))
+
+	if r.indexOf(song.Id) != -1 {
+		return uuid.Nil, errors.New("duplicate key value violates primary key constraint")
+	}
+	if r.linkTaken(song.Link, song.Id) {
+		return uuid.Nil, ErrDuplicateLink
+	}
+
+	if err := r.resolveArtist(&song); err != nil {
+		return uuid.Nil, err
+	}
+	if song.CreatedAt.IsZero() {
+		song.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	}
+	// метки добавляются только через AddSongLabels, как и в бд
+	song.Tags, song.Genres = []string{}, []string{}
+	song.Version = 1
+	// как default колонки в бд
+	if song.EnrichmentStatus == "" {
+		song.EnrichmentStatus = model.EnrichmentDone
+	}
+	r.songs = append(r.songs, song)
+	r.writeRevision(song, model.RevisionCreate)
+	return song.Id, nil
+}
+
+func (r *MemorySongRepository) Get(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("Get in-memory query:",
+		slog.String("id", songUUID.String()))
+
+	i := r.activeIndexOf(songUUID)
+	if i == -1 {
+		return model.Song{}, ErrNotFound
+	}
+	return r.songs[i], nil
+}
+
+// GetFields в памяти ничего не экономит и возвращает песню целиком, лишние поля отрезает контроллер
+func (r *MemorySongRepository) GetFields(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, fields model.FieldSet) (model.Song, error) {
+	return r.Get(ctx, log, songUUID)
+}
+
+// Update как и gorm Updates перезаписывает только ненулевые поля.
+// Ненулевая song.Version должна совпадать с текущей, иначе ErrVersionConflict
+func (r *MemorySongRepository) Update(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("Update in-memory query:",
+		slog.String("id", song.Id.String()),
+		slog.String("gruop", song.Group), slog.String("title", song.Title))
+
+	i := r.activeIndexOf(song.Id)
+	if i == -1 {
+		return model.Song{}, ErrNotFound
+	}
+	if song.Version != 0 && song.Version != r.songs[i].Version {
+		return model.Song{}, ErrVersionConflict
+	}
+	if song.Link != "" && r.linkTaken(song.Link, song.Id) {
+		return model.Song{}, ErrDuplicateLink
+	}
+
+	stored := r.songs[i]
+	stored.ManualFields = stored.EditedFields(song, true)
+	stored.EnrichmentSources = stored.EnrichmentSources.Without(stored.ManualFields)
+	if song.ArtistId != uuid.Nil || song.Group != "" {
+		if err := r.resolveArtist(&song); err != nil {
+			return model.Song{}, err
+		}
+		stored.Group, stored.ArtistId = song.Group, song.ArtistId
+	}
+	if song.Title != "" {
+		stored.Title = song.Title
+	}
+	if !song.ReleaseDate.IsZero() {
+		stored.ReleaseDate = song.ReleaseDate
+	}
+	if song.Text != "" {
+		stored.Text = song.Text
+	}
+	if song.Link != "" {
+		stored.Link = song.Link
+	}
+	stored.Version++
+	r.songs[i] = stored
+	r.writeRevision(stored, model.RevisionUpdate)
+
+	return stored, nil
+}
+
+// Replace записывает поля из model.SongFields как есть, включая пустые значения
+func (r *MemorySongRepository) Replace(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("Replace in-memory query:",
+		slog.String("id", song.Id.String()),
+		slog.String("gruop", song.Group), slog.String("title", song.Title))
+
+	i := r.activeIndexOf(song.Id)
+	if i == -1 {
+		return model.Song{}, ErrNotFound
+	}
+	if song.Version != 0 && song.Version != r.songs[i].Version {
+		return model.Song{}, ErrVersionConflict
+	}
+	if r.linkTaken(song.Link, song.Id) {
+		return model.Song{}, ErrDuplicateLink
+	}
+	if err := r.resolveArtist(&song); err != nil {
+		return model.Song{}, err
+	}
+
+	stored := r.songs[i]
+	stored.ManualFields = stored.EditedFields(song, false)
+	stored.EnrichmentSources = stored.EnrichmentSources.Without(stored.ManualFields)
+	stored.SetFields(song.Fields())
+	stored.ArtistId = song.ArtistId
+	stored.Version++
+	r.songs[i] = stored
+	r.writeRevision(stored, model.RevisionUpdate)
+
+	return stored, nil
+}
+
+func (r *MemorySongRepository) Delete(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("Delete in-memory query:",
+		slog.String("id", songUUID.String()))
+
+	i := r.activeIndexOf(songUUID)
+	if i == -1 {
+		return ErrNotFound
+	}
+	r.songs[i].DeletedAt = gorm.DeletedAt{Time: time.Now().UTC().Truncate(time.Microsecond), Valid: true}
+	r.removeSongFromPlaylists(songUUID)
+	r.writeRevision(r.songs[i], model.RevisionDelete)
+	return nil
+}
+
+func (r *MemorySongRepository) GetAll(ctx context.Context, log *slog.Logger, limit int, offset int, filter model.SongFilter) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetAll in-memory query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+	models := []model.Song{}
+	for _, song := range r.songs {
+		if !song.DeletedAt.Valid && r.matchSong(song, filter) {
+			models = append(models, song)
+		}
+	}
+	if hasFuzzy(filter) {
+		models = filterFuzzy(models, filter)
+	}
+	return paginate(models, limit, offset), nil
+}
+
+func (r *MemorySongRepository) GetAllKeyset(ctx context.Context, log *slog.Logger, limit int, cursor *model.SongCursor, filter model.SongFilter) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetAllKeyset in-memory query:", slog.Int("limit", limit), slog.Any("cursor", cursor))
+
+	models := []model.Song{}
+	for _, song := range r.songs {
+		if song.DeletedAt.Valid || !r.matchSong(song, filter) {
+			continue
+		}
+		if cursor != nil {
+			cmp := compareKeyset(song.CreatedAt, song.Id, cursor.CreatedAt, cursor.Id)
+			if (cursor.Direction == model.CursorPrev && cmp >= 0) || (cursor.Direction != model.CursorPrev && cmp <= 0) {
+				continue
+			}
+		}
+		models = append(models, song)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return compareKeyset(models[i].CreatedAt, models[i].Id, models[j].CreatedAt, models[j].Id) < 0
+	})
+
+	// для prev нужны последние limit записей перед курсором
+	if cursor != nil && cursor.Direction == model.CursorPrev && limit >= 0 && len(models) > limit {
+		return models[len(models)-limit:], nil
+	}
+	return paginate(models, limit, 0), nil
+}
+
+func (r *MemorySongRepository) GetVerses(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetVerses in-memory query:",
+		slog.String("id", songUUID.String()))
+
+	i := r.activeIndexOf(songUUID)
+	if i == -1 {
+		return "", ErrNotFound
+	}
+	return r.songs[i].Text, nil
+}
+
+func (r *MemorySongRepository) Search(ctx context.Context, log *slog.Logger, query string, limit int, offset int) ([]model.SongSearchResult, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("Search in-memory query:", slog.String("query", query), slog.Int("limit", limit), slog.Int("offset", offset))
+
+	return rankSongs(r.active(), query, limit, offset), nil
+}
+
+func (r *MemorySongRepository) GetTrash(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetTrash in-memory query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+	models := []model.Song{}
+	for _, song := range r.songs {
+		if song.DeletedAt.Valid {
+			models = append(models, song)
+		}
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		if c := models[i].DeletedAt.Time.Compare(models[j].DeletedAt.Time); c != 0 {
+			return c > 0
+		}
+		return bytes.Compare(models[i].Id[:], models[j].Id[:]) < 0
+	})
+	return paginate(models, limit, offset), nil
+}
+
+func (r *MemorySongRepository) Restore(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("Restore in-memory query:",
+		slog.String("id", songUUID.String()))
+
+	i := r.indexOf(songUUID)
+	if i == -1 || !r.songs[i].DeletedAt.Valid {
+		return model.Song{}, ErrNotFound
+	}
+	if r.linkTaken(r.songs[i].Link, songUUID) {
+		return model.Song{}, ErrDuplicateLink
+	}
+	r.songs[i].DeletedAt = gorm.DeletedAt{}
+	r.writeRevision(r.songs[i], model.RevisionRestore)
+	return r.songs[i], nil
+}
+
+func (r *MemorySongRepository) Purge(ctx context.Context, log *slog.Logger, deletedBefore time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("Purge in-memory query:", slog.Time("deleted_before", deletedBefore))
+
+	kept := r.songs[:0]
+	var purged int64
+	for _, song := range r.songs {
+		if song.DeletedAt.Valid && song.DeletedAt.Time.Before(deletedBefore) {
+			delete(r.revisions, song.Id)
+			for albumId, tracks := range r.albumTracks {
+				r.albumTracks[albumId] = slices.DeleteFunc(tracks, func(id uuid.UUID) bool { return id == song.Id })
+			}
+			purged++
+			continue
+		}
+		kept = append(kept, song)
+	}
+	r.songs = kept
+	return purged, nil
+}
+
+func (r *MemorySongRepository) GetRevisions(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) ([]model.SongRevision, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetRevisions in-memory query:",
+		slog.String("id", songUUID.String()))
+
+	if r.indexOf(songUUID) == -1 {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(r.revisions[songUUID]), nil
+}
+
+func (r *MemorySongRepository) GetRevision(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.SongRevision, error) {
+	select {
+	case <-ctx.Done():
+		return model.SongRevision{}, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log.Debug("GetRevision in-memory query:",
+		slog.String("id", songUUID.String()), slog.Int("revision", revision))
+
+	revisions := r.revisions[songUUID]
+	if revision < 1 || revision > len(revisions) {
+		return model.SongRevision{}, ErrNotFound
+	}
+	return revisions[revision-1], nil
+}
+
+func (r *MemorySongRepository) Revert(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	defer r.lock(ctx)()
+
+	log.Debug("Revert in-memory query:",
+		slog.String("id", songUUID.String()), slog.Int("revision", revision))
+
+	i := r.activeIndexOf(songUUID)
+	revisions := r.revisions[songUUID]
+	if i == -1 || revision < 1 || revision > len(revisions) {
+		return model.Song{}, ErrNotFound
+	}
+	rev := revisions[revision-1]
+	if r.linkTaken(rev.Link, songUUID) {
+		return model.Song{}, ErrDuplicateLink
+	}
+
+	reverted := r.songs[i]
+	reverted.ManualFields = reverted.EditedFields(model.Song{ReleaseDate: rev.ReleaseDate, Text: rev.Text, Link: rev.Link}, false)
+	reverted.EnrichmentSources = reverted.EnrichmentSources.Without(reverted.ManualFields)
+	reverted.Group, reverted.Title, reverted.ReleaseDate, reverted.Text, reverted.Link = rev.Group, rev.Title, rev.ReleaseDate, rev.Text, rev.Link
+	reverted.ArtistId = uuid.Nil
+	if err := r.resolveArtist(&reverted); err != nil {
+		return model.Song{}, err
+	}
+	reverted.Version++
+	r.songs[i] = reverted
+	r.writeRevision(reverted, model.RevisionRevert)
+	return reverted, nil
+}
+
+// writeRevision вызывается под r.mu.Lock
+func (r *MemorySongRepository) writeRevision(song model.Song, op model.RevisionOperation) {
+	r.revisions[song.Id] = append(r.revisions[song.Id], model.SongRevision{
+		SongId:      song.Id,
+		Revision:    len(r.revisions[song.Id]) + 1,
+		Operation:   op,
+		Group:       song.Group,
+		Title:       song.Title,
+		ReleaseDate: song.ReleaseDate,
+		Text:        song.Text,
+		Link:        song.Link,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	})
+}
+
+// active возвращает песни не из корзины
+func (r *MemorySongRepository) active() []model.Song {
+	songs := make([]model.Song, 0, len(r.songs))
+	for _, song := range r.songs {
+		if !song.DeletedAt.Valid {
+			songs = append(songs, song)
+		}
+	}
+	return songs
+}
+
+func (r *MemorySongRepository) indexOf(id uuid.UUID) int {
+	for i := range r.songs {
+		if r.songs[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// activeIndexOf как и gorm не видит песни из корзины
+func (r *MemorySongRepository) activeIndexOf(id uuid.UUID) int {
+	i := r.indexOf(id)
+	if i != -1 && r.songs[i].DeletedAt.Valid {
+		return -1
+	}
+	return i
+}
+
+// linkTaken - уникальность ссылки проверяется только среди песен не из корзины
+func (r *MemorySongRepository) linkTaken(link string, owner uuid.UUID) bool {
+	// пустая ссылка у еще не обогащенных песен не уникальна
+	if link == "" {
+		return false
+	}
+	for i := range r.songs {
+		if r.songs[i].Link == link && r.songs[i].Id != owner && !r.songs[i].DeletedAt.Valid {
+			return true
+		}
+	}
+	return false
+}
+
+// compareKeyset сравнивает ключи (created_at, id) так же, как postgres сравнивает row values
+func compareKeyset(createdA time.Time, idA uuid.UUID, createdB time.Time, idB uuid.UUID) int {
+	if c := createdA.Compare(createdB); c != 0 {
+		return c
+	}
+	return bytes.Compare(idA[:], idB[:])
+}
+
+// matchSong дополняет matchFilter фильтром по альбому, которому нужно состояние репозитория
+func (r *MemorySongRepository) matchSong(song model.Song, filter model.SongFilter) bool {
+	if filter.AlbumId != nil && !slices.Contains(r.albumTracks[*filter.AlbumId], song.Id) {
+		return false
+	}
+	if !matchLabels(song.Tags, filter.Tags, filter.TagMode) || !matchLabels(song.Genres, filter.Genres, filter.GenreMode) {
+		return false
+	}
+	return matchFilter(song, filter)
+}
+
+func matchFilter(song model.Song, filter model.SongFilter) bool {
+	if filter.Id != nil && song.Id != *filter.Id {
+		return false
+	}
+	if !matchText(song.Group, filter.Group, filter.GroupMatch) {
+		return false
+	}
+	if !matchText(song.Title, filter.Title, filter.TitleMatch) {
+		return false
+	}
+	if filter.ReleaseDate != nil && !song.ReleaseDate.Equal(*filter.ReleaseDate) {
+		return false
+	}
+	if filter.Text != nil && song.Text != *filter.Text {
+		return false
+	}
+	if filter.Link != nil && song.Link != *filter.Link {
+		return false
+	}
+	if filter.Enrichment != nil && song.EnrichmentStatus != *filter.Enrichment {
+		return false
+	}
+	if filter.EnrichedBefore != nil {
+		enrichedAt := song.CreatedAt
+		if song.EnrichedAt != nil {
+			enrichedAt = *song.EnrichedAt
+		}
+		if !enrichedAt.Before(*filter.EnrichedBefore) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"online-song-library/internal/model"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+type memoryTxKey struct{}
+
+// memoryState - копия данных MemorySongRepository для отката
+type memoryState struct {
+	songs         []model.Song
+	artists       []model.Artist
+	revisions     map[uuid.UUID][]model.SongRevision
+	albums        []model.Album
+	albumTracks   map[uuid.UUID][]uuid.UUID
+	labels        map[model.LabelKind][]string
+	playlists     []model.Playlist
+	playlistSongs map[uuid.UUID][]uuid.UUID
+}
+
+// WithinTx запоминает состояние перед fn и возвращает его, если fn вернул ошибку или запаниковал.
+// Пока fn выполняется, другие запросы на запись ждут, поэтому откат не затирает чужих изменений.
+// Чтения не ждут и видят незакоммиченные данные
+func (r *MemorySongRepository) WithinTx(ctx context.Context, log *slog.Logger, fn func(ctx context.Context) error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if ctx.Value(memoryTxKey{}) != nil {
+		return fn(ctx)
+	}
+
+	log.Debug("WithinTx in-memory transaction")
+
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	r.mu.RLock()
+	saved := r.snapshot()
+	r.mu.RUnlock()
+
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		r.mu.Lock()
+		r.restore(saved)
+		r.mu.Unlock()
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, true)); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// lock берет r.mu на запись и возвращает функцию для снятия блокировки.
+// Вне транзакции сначала дожидается конца открытой WithinTx
+func (r *MemorySongRepository) lock(ctx context.Context) func() {
+	if ctx.Value(memoryTxKey{}) != nil {
+		r.mu.Lock()
+		return r.mu.Unlock
+	}
+	r.txMu.Lock()
+	r.mu.Lock()
+	return func() {
+		r.mu.Unlock()
+		r.txMu.Unlock()
+	}
+}
+
+// snapshot вызывается под r.mu. Срезы внутри map копируются, потому что их меняют на месте
+func (r *MemorySongRepository) snapshot() memoryState {
+	return memoryState{
+		songs:         slices.Clone(r.songs),
+		artists:       slices.Clone(r.artists),
+		revisions:     cloneSliceMap(r.revisions),
+		albums:        slices.Clone(r.albums),
+		albumTracks:   cloneSliceMap(r.albumTracks),
+		labels:        cloneSliceMap(r.labels),
+		playlists:     slices.Clone(r.playlists),
+		playlistSongs: cloneSliceMap(r.playlistSongs),
+	}
+}
+
+// restore вызывается под r.mu.Lock
+func (r *MemorySongRepository) restore(state memoryState) {
+	r.songs = state.songs
+	r.artists = state.artists
+	r.revisions = state.revisions
+	r.albums = state.albums
+	r.albumTracks = state.albumTracks
+	r.labels = state.labels
+	r.playlists = state.playlists
+	r.playlistSongs = state.playlistSongs
+}
+
+func cloneSliceMap[K comparable, V any](m map[K][]V) map[K][]V {
+	cloned := maps.Clone(m)
+	for k, v := range cloned {
+		cloned[k] = slices.Clone(v)
+	}
+	return cloned
+}
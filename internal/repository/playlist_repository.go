@@ -0,0 +1,368 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/model"
+	"slices"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PlaylistRepository interface {
+	CreatePlaylist(ctx context.Context, log *slog.Logger, playlist model.Playlist) (model.Playlist, error)
+	RenamePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, name string) (model.Playlist, error)
+	DeletePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) error
+	GetPlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) (model.PlaylistDetails, error)
+	GetPlaylists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Playlist, error)
+	AddPlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, songUUID uuid.UUID, position int) (model.PlaylistDetails, error)
+	RemovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, position int) (model.PlaylistDetails, error)
+	MovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, from int, to int) (model.PlaylistDetails, error)
+}
+
+func (r *SongRepository) CreatePlaylist(ctx context.Context, log *slog.Logger, playlist model.Playlist) (model.Playlist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Playlist{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("CreatePlaylist sql query:",
+			slog.String("id", playlist.Id.String()),
+			slog.String("name", playlist.Name))
+
+		return d.Create(&playlist).Error
+	}); err != nil {
+		return model.Playlist{}, err
+	}
+	return playlist, nil
+}
+
+func (r *SongRepository) RenamePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, name string) (model.Playlist, error) {
+	select {
+	case <-ctx.Done():
+		return model.Playlist{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var stored model.Playlist
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("RenamePlaylist sql query:",
+			slog.String("id", playlistUUID.String()),
+			slog.String("name", name))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&stored, "id = ?", playlistUUID); result.Error != nil {
+				return result.Error
+			}
+			return tx.Model(&stored).Update("name", name).Error
+		})
+	}); err != nil {
+		return model.Playlist{}, err
+	}
+	return stored, nil
+}
+
+// DeletePlaylist удаляет плейлист вместе с вхождениями, сами песни остаются
+func (r *SongRepository) DeletePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("DeletePlaylist sql query:",
+			slog.String("id", playlistUUID.String()))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.Delete(&model.PlaylistEntry{}, "playlist_id = ?", playlistUUID); result.Error != nil {
+				return result.Error
+			}
+			result := tx.Delete(&model.Playlist{}, "id = ?", playlistUUID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return nil
+		})
+	})
+}
+
+func (r *SongRepository) GetPlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.PlaylistDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetPlaylist sql query:",
+			slog.String("id", playlistUUID.String()))
+
+		var err error
+		details, err = loadPlaylistDetails(d, playlistUUID)
+		return err
+	}); err != nil {
+		return model.PlaylistDetails{}, err
+	}
+	return details, nil
+}
+
+// GetPlaylists возвращает плейлисты в порядке создания
+func (r *SongRepository) GetPlaylists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Playlist, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var playlists []model.Playlist
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetPlaylists sql query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+		return d.Order("created_at, id").Limit(limit).Offset(offset).Find(&playlists).Error
+	}); err != nil {
+		return nil, err
+	}
+	return playlists, nil
+}
+
+// AddPlaylistSong вставляет песню перед песней с номером position, 0 - в конец плейлиста
+func (r *SongRepository) AddPlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, songUUID uuid.UUID, position int) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.PlaylistDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("AddPlaylistSong sql query:",
+			slog.String("playlist_id", playlistUUID.String()),
+			slog.String("song_id", songUUID.String()), slog.Int("position", position))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			entries, err := loadPlaylistOrder(tx, playlistUUID)
+			if err != nil {
+				return err
+			}
+			if result := tx.First(&model.Song{}, "id = ?", songUUID); result.Error != nil {
+				if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+					return ErrUnknownSong
+				}
+				return result.Error
+			}
+
+			entry := model.PlaylistEntry{Id: uuid.New(), PlaylistId: playlistUUID, SongId: songUUID}
+			entries, err = insertTrack(entries, entry.Id, position)
+			if err != nil {
+				return err
+			}
+			if result := tx.Create(&entry); result.Error != nil {
+				return result.Error
+			}
+			if err := savePlaylistOrder(tx, entries); err != nil {
+				return err
+			}
+			details, err = loadPlaylistDetails(tx, playlistUUID)
+			return err
+		})
+	}); err != nil {
+		return model.PlaylistDetails{}, err
+	}
+	return details, nil
+}
+
+// RemovePlaylistSong убирает вхождение с номером position, остальные сдвигаются
+func (r *SongRepository) RemovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, position int) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.PlaylistDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("RemovePlaylistSong sql query:",
+			slog.String("playlist_id", playlistUUID.String()), slog.Int("position", position))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			entries, err := loadPlaylistOrder(tx, playlistUUID)
+			if err != nil {
+				return err
+			}
+			if position < 1 || position > len(entries) {
+				return gorm.ErrRecordNotFound
+			}
+			if result := tx.Delete(&model.PlaylistEntry{}, "id = ?", entries[position-1]); result.Error != nil {
+				return result.Error
+			}
+			if err := savePlaylistOrder(tx, slices.Delete(entries, position-1, position)); err != nil {
+				return err
+			}
+			details, err = loadPlaylistDetails(tx, playlistUUID)
+			return err
+		})
+	}); err != nil {
+		return model.PlaylistDetails{}, err
+	}
+	return details, nil
+}
+
+// MovePlaylistSong переносит вхождение с номера from на номер to
+func (r *SongRepository) MovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, from int, to int) (model.PlaylistDetails, error) {
+	select {
+	case <-ctx.Done():
+		return model.PlaylistDetails{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var details model.PlaylistDetails
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("MovePlaylistSong sql query:",
+			slog.String("playlist_id", playlistUUID.String()), slog.Int("from", from), slog.Int("to", to))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			entries, err := loadPlaylistOrder(tx, playlistUUID)
+			if err != nil {
+				return err
+			}
+			entries, err = moveEntry(entries, from, to)
+			if err != nil {
+				return err
+			}
+			if err := savePlaylistOrder(tx, entries); err != nil {
+				return err
+			}
+			details, err = loadPlaylistDetails(tx, playlistUUID)
+			return err
+		})
+	}); err != nil {
+		return model.PlaylistDetails{}, err
+	}
+	return details, nil
+}
+
+// removeSongFromPlaylists убирает все вхождения песни и перенумеровывает затронутые плейлисты
+func removeSongFromPlaylists(tx *gorm.DB, songUUID uuid.UUID) error {
+	var playlistUUIDs []uuid.UUID
+	if result := tx.Model(&model.PlaylistEntry{}).
+		Distinct("playlist_id").
+		Where("song_id = ?", songUUID).
+		Pluck("playlist_id", &playlistUUIDs); result.Error != nil {
+		return result.Error
+	}
+	if len(playlistUUIDs) == 0 {
+		return nil
+	}
+	if result := tx.Delete(&model.PlaylistEntry{}, "song_id = ?", songUUID); result.Error != nil {
+		return result.Error
+	}
+
+	for _, playlistUUID := range playlistUUIDs {
+		entries, err := loadPlaylistOrder(tx, playlistUUID)
+		if err != nil {
+			return err
+		}
+		if err := savePlaylistOrder(tx, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPlaylistDetails возвращает плейлист и его песни по порядку
+func loadPlaylistDetails(tx *gorm.DB, playlistUUID uuid.UUID) (model.PlaylistDetails, error) {
+	var details model.PlaylistDetails
+	if result := tx.First(&details.Playlist, "id = ?", playlistUUID); result.Error != nil {
+		return model.PlaylistDetails{}, result.Error
+	}
+
+	var songs []model.Song
+	if result := tx.Model(&model.Song{}).
+		Joins("JOIN playlist_entries ON playlist_entries.song_id = songs.id").
+		Where("playlist_entries.playlist_id = ?", playlistUUID).
+		Order("playlist_entries.position").
+		Find(&songs); result.Error != nil {
+		return model.PlaylistDetails{}, result.Error
+	}
+	if err := loadLabels(tx, songPtrs(songs)); err != nil {
+		return model.PlaylistDetails{}, err
+	}
+	details.Songs = numberTracks(songs)
+	return details, nil
+}
+
+// loadPlaylistOrder возвращает id вхождений плейлиста по порядку.
+// Отсутствие плейлиста - gorm.ErrRecordNotFound
+func loadPlaylistOrder(tx *gorm.DB, playlistUUID uuid.UUID) ([]uuid.UUID, error) {
+	if result := tx.First(&model.Playlist{}, "id = ?", playlistUUID); result.Error != nil {
+		return nil, result.Error
+	}
+
+	entries := []uuid.UUID{}
+	if result := tx.Model(&model.PlaylistEntry{}).
+		Where("playlist_id = ?", playlistUUID).
+		Order("position").
+		Pluck("id", &entries); result.Error != nil {
+		return nil, result.Error
+	}
+	return entries, nil
+}
+
+// savePlaylistOrder перенумеровывает вхождения с 1 в порядке entries
+func savePlaylistOrder(tx *gorm.DB, entries []uuid.UUID) error {
+	for i, entryUUID := range entries {
+		if result := tx.Model(&model.PlaylistEntry{}).
+			Where("id = ?", entryUUID).
+			Update("position", i+1); result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// moveEntry переносит элемент с номера from на номер to (оба с 1).
+// Несуществующий from - gorm.ErrRecordNotFound, to за пределами списка - ErrInvalidPosition
+func moveEntry(entries []uuid.UUID, from int, to int) ([]uuid.UUID, error) {
+	if from < 1 || from > len(entries) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if to < 1 || to > len(entries) {
+		return nil, ErrInvalidPosition
+	}
+	entry := entries[from-1]
+	entries = slices.Delete(entries, from-1, from)
+	return slices.Insert(entries, to-1, entry), nil
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"online-song-library/internal/model"
+	"online-song-library/pkg/textsearch"
+	"sort"
+)
+
+// rankSongs - поиск в памяти для хранилищ без полнотекстового индекса postgres
+func rankSongs(songs []model.Song, query string, limit, offset int) []model.SongSearchResult {
+	terms := textsearch.Terms(query)
+
+	results := []model.SongSearchResult{}
+	for _, song := range songs {
+		rank := textsearch.Rank(song.Text, terms)
+		if rank == 0 {
+			continue
+		}
+		results = append(results, model.SongSearchResult{Song: song, Rank: rank})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+
+	return paginate(results, limit, offset)
+}
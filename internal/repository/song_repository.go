@@ -3,30 +3,89 @@ package repository
 import (
 	"context"
 	"log/slog"
+	"online-song-library/internal/apperr"
 	"online-song-library/internal/model"
 	"online-song-library/pkg/storage/postgresql"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict - песню изменили после того, как клиент прочитал ее версию
+var ErrVersionConflict = apperr.PreconditionFailed("Song version does not match")
+
+// UnitOfWork выполняет fn в одной транзакции: все вызовы репозитория с ctx, который получил fn,
+// коммитятся или откатываются вместе
+type UnitOfWork interface {
+	WithinTx(ctx context.Context, log *slog.Logger, fn func(ctx context.Context) error) error
+}
+
 // for mocks
 type Repository interface {
+	UnitOfWork
+	ArtistRepository
+	AlbumRepository
+	LabelRepository
+	PlaylistRepository
+	EnrichmentRepository
 	Create(ctx context.Context, log *slog.Logger, song model.Song) (uuid.UUID, error)
+	Get(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error)
+	GetFields(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, fields model.FieldSet) (model.Song, error)
 	Update(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error)
-	Delete(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) error 
+	Replace(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error)
+	Delete(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) error
 	GetAll(ctx context.Context, log *slog.Logger, limit int, offset int, filter model.SongFilter) ([]model.Song, error)
+	GetAllKeyset(ctx context.Context, log *slog.Logger, limit int, cursor *model.SongCursor, filter model.SongFilter) ([]model.Song, error)
 	GetVerses(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (string, error)
+	Search(ctx context.Context, log *slog.Logger, query string, limit int, offset int) ([]model.SongSearchResult, error)
+	GetTrash(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Song, error)
+	Restore(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error)
+	Purge(ctx context.Context, log *slog.Logger, deletedBefore time.Time) (int64, error)
+	GetRevisions(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) ([]model.SongRevision, error)
+	GetRevision(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.SongRevision, error)
+	Revert(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.Song, error)
 }
 
 type SongRepository struct {
 	db *gorm.DB
+	// statementTimeout ограничивает время каждого вызова репозитория, 0 - без ограничения
+	statementTimeout time.Duration
 }
 
-func NewSongRepository(conn *gorm.DB) *SongRepository {
+func NewSongRepository(conn *gorm.DB, statementTimeout time.Duration) *SongRepository {
 	return &SongRepository{
-		db: conn,
+		db:               conn,
+		statementTimeout: statementTimeout,
+	}
+}
+
+// withTimeout добавляет к контексту запроса таймаут бд. Отмена контекста клиентом тоже прерывает запрос
+func (r *SongRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.statementTimeout)
+}
+
+// exec выполняет fn через TxSaveExecutor и переводит ошибки бд в доменные
+func (r *SongRepository) exec(ctx context.Context, fn func(*gorm.DB) error) error {
+	return wrapError(postgresql.TxSaveExecutor(ctx, r.db, fn))
+}
+
+func (r *SongRepository) WithinTx(ctx context.Context, log *slog.Logger, fn func(ctx context.Context) error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
 	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	log.Debug("WithinTx sql transaction")
+	return wrapError(postgresql.RunInTx(ctx, r.db, fn))
 }
 
 // http запросы на обогащение будут проводиться в service
@@ -37,24 +96,71 @@ func (r *SongRepository) Create(ctx context.Context, log *slog.Logger, song mode
 	default:
 	}
 
-	if err := postgresql.TxSaveExecutor(r.db, func(d *gorm.DB) error {
-		log.Debug("Create sql query:", 
-			slog.String("id", song.Id.String()), 
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("Create sql query:",
+			slog.String("id", song.Id.String()),
 			slog.String("gruop", song.Group),
 			slog.String("title", song.Title),
 			slog.Time("release_date", song.ReleaseDate),
 			slog.String("link", song.Link))
 
-		if result := d.Create(&song); result.Error != nil {
-			return result.Error
-		}
-		return nil
+		// песня и ее ревизия пишутся в одной транзакции
+		return d.Transaction(func(tx *gorm.DB) error {
+			if err := resolveArtist(tx, &song); err != nil {
+				return err
+			}
+			song.Version = 1
+			if result := tx.Create(&song); result.Error != nil {
+				return result.Error
+			}
+			return writeRevision(tx, song, model.RevisionCreate)
+		})
 	}); err != nil {
 		return uuid.Nil, err
 	}
 	return song.Id, nil
 }
 
+func (r *SongRepository) Get(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error) {
+	return r.GetFields(ctx, log, songUUID, nil)
+}
+
+// GetFields читает только колонки из fields, остальные поля песни остаются нулевыми.
+// id и version читаются всегда: по ним грузятся метки и строится ETag
+func (r *SongRepository) GetFields(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, fields model.FieldSet) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var song model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("Get sql query:",
+			slog.String("id", songUUID.String()), slog.Any("fields", fields))
+
+		if result := selectFields(d, fields, "version").First(&song, "id = ?", songUUID); result.Error != nil {
+			return result.Error
+		}
+		if !needsLabels(fields) {
+			return nil
+		}
+		return loadLabels(d, []*model.Song{&song})
+	}); err != nil {
+		return model.Song{}, err
+	}
+	return song, nil
+}
+
+// Update перезаписывает ненулевые поля и увеличивает версию. Если song.Version не 0,
+// а версия в бд другая - ErrVersionConflict. Запись идет с условием на версию,
+// поэтому параллельное изменение между чтением и записью тоже дает конфликт
 func (r *SongRepository) Update(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
 	select {
 	case <-ctx.Done():
@@ -63,26 +169,106 @@ func (r *SongRepository) Update(ctx context.Context, log *slog.Logger, song mode
 	default:
 	}
 
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	var oldModel model.Song
-	if err := postgresql.TxSaveExecutor(r.db, func(d *gorm.DB) error {
+	if err := r.exec(ctx, func(d *gorm.DB) error {
 		log.Debug("Update sql query:",
 			slog.String("id", song.Id.String()),
 			slog.String("gruop", song.Group), slog.String("title", song.Title))
 
-		if result := d.First(&oldModel, "id = ?", song.Id); result.Error != nil {
-			return result.Error
-		}
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&oldModel, "id = ?", song.Id); result.Error != nil {
+				return result.Error
+			}
+			if song.Version != 0 && song.Version != oldModel.Version {
+				return ErrVersionConflict
+			}
+			if song.ArtistId != uuid.Nil || song.Group != "" {
+				if err := resolveArtist(tx, &song); err != nil {
+					return err
+				}
+			}
 
-		if result := d.Model(&oldModel).Updates(&song); result.Error != nil {
-			return result.Error
-		}
-		return nil
+			current := oldModel.Version
+			song.Version = current + 1
+			song.ManualFields = oldModel.EditedFields(song, true)
+			song.EnrichmentSources = oldModel.EnrichmentSources.Without(song.ManualFields)
+			// статус обогащения меняет только UpdateEnrichment
+			result := tx.Model(&oldModel).Where("version = ?", current).
+				Omit("EnrichmentStatus", "EnrichmentAttempts", "EnrichmentNextAt", "EnrichmentForce", "EnrichedAt").
+				Updates(&song)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrVersionConflict
+			}
+			if err := writeRevision(tx, oldModel, model.RevisionUpdate); err != nil {
+				return err
+			}
+			return loadLabels(tx, []*model.Song{&oldModel})
+		})
 	}); err != nil {
 		return model.Song{}, err
 	}
 	return oldModel, nil
 }
 
+// Replace записывает поля из model.SongFields как есть, включая пустые значения, и увеличивает версию.
+// Версия проверяется так же, как в Update. Нулевой ArtistId - исполнитель ищется по Group
+func (r *SongRepository) Replace(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var stored model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("Replace sql query:",
+			slog.String("id", song.Id.String()),
+			slog.String("gruop", song.Group), slog.String("title", song.Title))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&stored, "id = ?", song.Id); result.Error != nil {
+				return result.Error
+			}
+			if song.Version != 0 && song.Version != stored.Version {
+				return ErrVersionConflict
+			}
+			if err := resolveArtist(tx, &song); err != nil {
+				return err
+			}
+
+			current := stored.Version
+			song.Version = current + 1
+			song.ManualFields = stored.EditedFields(song, false)
+			song.EnrichmentSources = stored.EnrichmentSources.Without(song.ManualFields)
+			result := tx.Model(&stored).Where("version = ?", current).
+				Select("Group", "ArtistId", "Title", "ReleaseDate", "Text", "Link", "ManualFields", "EnrichmentSources", "Version").
+				Updates(&song)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrVersionConflict
+			}
+			if err := writeRevision(tx, stored, model.RevisionUpdate); err != nil {
+				return err
+			}
+			return loadLabels(tx, []*model.Song{&stored})
+		})
+	}); err != nil {
+		return model.Song{}, err
+	}
+	return stored, nil
+}
+
 func (r *SongRepository) Delete(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) error {
 	select {
 	case <-ctx.Done():
@@ -90,19 +276,28 @@ func (r *SongRepository) Delete(ctx context.Context, log *slog.Logger, songUUID
 	default:
 	}
 
-	if err := postgresql.TxSaveExecutor(r.db, func(d *gorm.DB) error {
-		var model model.Song
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
-		log.Debug("Delete sql query:", 
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		var song model.Song
+
+		log.Debug("Delete sql query:",
 			slog.String("id", songUUID.String()))
 
-		if result := d.First(&model, "id = ?", songUUID); result.Error != nil {
-			return result.Error
-		}
-		if err := d.Delete(&model).Error; err != nil {
-			return err
-		}
-		return nil
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&song, "id = ?", songUUID); result.Error != nil {
+				return result.Error
+			}
+			if err := tx.Delete(&song).Error; err != nil {
+				return err
+			}
+			// песня из корзины не должна висеть в плейлистах, после восстановления ее добавляют заново
+			if err := removeSongFromPlaylists(tx, song.Id); err != nil {
+				return err
+			}
+			return writeRevision(tx, song, model.RevisionDelete)
+		})
 	}); err != nil {
 		return err
 	}
@@ -116,50 +311,86 @@ func (r *SongRepository) GetAll(ctx context.Context, log *slog.Logger, limit int
 	default:
 	}
 
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	var models []model.Song
-	if err := postgresql.TxSaveExecutor(r.db, func(d *gorm.DB) error {
-		query := d.Limit(limit).Offset(offset)
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		query := d.Model(&model.Song{})
 
 		log.Debug("GetAll sql query:", slog.Int("limit", limit), slog.Int("offset", offset))
 
-		if filter.Id != nil {
-			query = query.Where("id = ?", *filter.Id)
-			log.Debug("filter detected", slog.String("filter_id", (*filter.Id).String()))
-		}
-		if filter.Group != nil {
-			query = query.Where("group = ?", *filter.Group)
-			log.Debug("filter detected", slog.String("filter_group", (*filter.Group)))
-		}
-		if filter.Title != nil {
-			query = query.Where("title = ?", *filter.Title)
-			log.Debug("filter detected", slog.String("filter_title", (*filter.Group)))
+		query = applyFilter(query, log, filter)
+
+		// без pg_trgm похожесть считается в памяти, поэтому пагинация тоже
+		if hasFuzzy(filter) && d.Dialector.Name() != "postgres" {
+			query = selectFields(query, filter.Fields, "group", "title")
+			if res := query.Find(&models); res.Error != nil {
+				return res.Error
+			}
+			models = paginate(filterFuzzy(models, filter), limit, offset)
+			return loadSelectedLabels(d, models, filter.Fields)
 		}
-		if filter.ReleaseDate != nil {
-			query = query.Where("release_date = ?", *filter.ReleaseDate)
-			log.Debug("filter detected", slog.String("filter_release_date", (*filter.ReleaseDate).String()))
+		query = selectFields(query, filter.Fields)
+		if hasFuzzy(filter) {
+			query = orderBySimilarity(query, filter)
 		}
-		if filter.Text != nil {
-			query = query.Where("text = ?", *filter.Text)
-			log.Debug("filter detected", slog.String("filter_text", (*filter.Text)))
 
+		res := query.Limit(limit).Offset(offset).Find(&models)
+		if res.Error != nil {
+			return res.Error
 		}
-		if filter.Link != nil {
-			query = query.Where("link = ?", *filter.Link)
-			log.Debug("filter detected", slog.String("filter_link", (*filter.Link)))
+		return loadSelectedLabels(d, models, filter.Fields)
+	}); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// GetAllKeyset возвращает до limit песен строго после (next) или до (prev) курсора,
+// всегда в порядке возрастания (created_at, id). Без курсора - с начала выдачи.
+func (r *SongRepository) GetAllKeyset(ctx context.Context, log *slog.Logger, limit int, cursor *model.SongCursor, filter model.SongFilter) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var models []model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		query := d.Model(&model.Song{})
+
+		log.Debug("GetAllKeyset sql query:", slog.Int("limit", limit), slog.Any("cursor", cursor))
+
+		// created_at и id нужны для курсоров следующей и предыдущей страницы
+		query = selectFields(applyFilter(query, log, filter), filter.Fields, "created_at")
+
+		order := "created_at ASC, id ASC"
+		if cursor != nil && cursor.Direction == model.CursorPrev {
+			query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.Id)
+			order = "created_at DESC, id DESC"
+		} else if cursor != nil {
+			query = query.Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.Id)
 		}
 
-		res := query.Find(&models)
+		res := query.Order(order).Limit(limit).Find(&models)
 		if res.Error != nil {
 			return res.Error
 		}
-		return nil
+		return loadSelectedLabels(d, models, filter.Fields)
 	}); err != nil {
 		return nil, err
 	}
+
+	if cursor != nil && cursor.Direction == model.CursorPrev {
+		slices.Reverse(models)
+	}
 	return models, nil
 }
 
-
 func (r *SongRepository) GetVerses(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (string, error) {
 	select {
 	case <-ctx.Done():
@@ -167,9 +398,12 @@ func (r *SongRepository) GetVerses(ctx context.Context, log *slog.Logger, songUU
 	default:
 	}
 
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	var verses string
-	if err := postgresql.TxSaveExecutor(r.db, func(d *gorm.DB) error {
-		log.Debug("GetVerses sql query:", 
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetVerses sql query:",
 			slog.String("id", songUUID.String()))
 
 		res := d.Model(&model.Song{}).Select("text").Where("id = ?", songUUID).First(&verses)
@@ -182,3 +416,363 @@ func (r *SongRepository) GetVerses(ctx context.Context, log *slog.Logger, songUU
 	}
 	return verses, nil
 }
+
+// Search ищет по тексту песни. На postgres используется tsvector колонка text_search с GIN индексом,
+// на остальных бд песни ранжируются в памяти.
+func (r *SongRepository) Search(ctx context.Context, log *slog.Logger, query string, limit int, offset int) ([]model.SongSearchResult, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var results []model.SongSearchResult
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("Search sql query:", slog.String("query", query), slog.Int("limit", limit), slog.Int("offset", offset))
+
+		if d.Dialector.Name() != "postgres" {
+			var songs []model.Song
+			if res := d.Find(&songs); res.Error != nil {
+				return res.Error
+			}
+			results = rankSongs(songs, query, limit, offset)
+			return loadLabels(d, searchResultPtrs(results))
+		}
+
+		res := d.Table("songs").
+			Select("songs.*, ts_rank_cd(songs.text_search, q, 1) AS rank").
+			Joins("CROSS JOIN websearch_to_tsquery('simple', ?) q", query).
+			Where("songs.text_search @@ q AND songs.deleted_at IS NULL").
+			Order("rank DESC, songs.id").
+			Limit(limit).Offset(offset).
+			Scan(&results)
+		if res.Error != nil {
+			return res.Error
+		}
+		return loadLabels(d, searchResultPtrs(results))
+	}); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetTrash возвращает удаленные песни, последние удаленные первыми
+func (r *SongRepository) GetTrash(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var models []model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetTrash sql query:", slog.Int("limit", limit), slog.Int("offset", offset))
+
+		res := d.Unscoped().Where("deleted_at IS NOT NULL").
+			Order("deleted_at DESC, id").
+			Limit(limit).Offset(offset).
+			Find(&models)
+		if res.Error != nil {
+			return res.Error
+		}
+		return loadLabels(d, songPtrs(models))
+	}); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Restore возвращает песню из корзины. Если ссылку уже заняла другая песня - ErrDuplicateLink.
+func (r *SongRepository) Restore(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var song model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("Restore sql query:",
+			slog.String("id", songUUID.String()))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.Unscoped().First(&song, "id = ? AND deleted_at IS NOT NULL", songUUID); result.Error != nil {
+				return result.Error
+			}
+			if err := checkLinkFree(tx, song.Link, song.Id); err != nil {
+				return err
+			}
+
+			if result := tx.Unscoped().Model(&song).Update("deleted_at", nil); result.Error != nil {
+				return result.Error
+			}
+			song.DeletedAt = gorm.DeletedAt{}
+			if err := writeRevision(tx, song, model.RevisionRestore); err != nil {
+				return err
+			}
+			return loadLabels(tx, []*model.Song{&song})
+		})
+	}); err != nil {
+		return model.Song{}, err
+	}
+	return song, nil
+}
+
+// Purge окончательно удаляет песни, которые лежат в корзине с момента раньше deletedBefore
+func (r *SongRepository) Purge(ctx context.Context, log *slog.Logger, deletedBefore time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var purged int64
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("Purge sql query:", slog.Time("deleted_before", deletedBefore))
+
+		res := d.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).Delete(&model.Song{})
+		if res.Error != nil {
+			return res.Error
+		}
+		purged = res.RowsAffected
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// GetRevisions возвращает историю песни по возрастанию номера, в том числе для песни из корзины
+func (r *SongRepository) GetRevisions(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) ([]model.SongRevision, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var revisions []model.SongRevision
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetRevisions sql query:",
+			slog.String("id", songUUID.String()))
+
+		var song model.Song
+		if result := d.Unscoped().Select("id").First(&song, "id = ?", songUUID); result.Error != nil {
+			return result.Error
+		}
+		if result := d.Where("song_id = ?", songUUID).Order("revision").Find(&revisions); result.Error != nil {
+			return result.Error
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *SongRepository) GetRevision(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.SongRevision, error) {
+	select {
+	case <-ctx.Done():
+		return model.SongRevision{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var rev model.SongRevision
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("GetRevision sql query:",
+			slog.String("id", songUUID.String()), slog.Int("revision", revision))
+
+		if result := d.First(&rev, "song_id = ? AND revision = ?", songUUID, revision); result.Error != nil {
+			return result.Error
+		}
+		return nil
+	}); err != nil {
+		return model.SongRevision{}, err
+	}
+	return rev, nil
+}
+
+// Revert возвращает поля песни к состоянию ревизии и записывает это новой ревизией.
+// Поля перезаписываются все, включая пустые, поэтому Updates идет с явным Select.
+func (r *SongRepository) Revert(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.Song, error) {
+	select {
+	case <-ctx.Done():
+		return model.Song{}, ctx.Err()
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var song model.Song
+	if err := r.exec(ctx, func(d *gorm.DB) error {
+		log.Debug("Revert sql query:",
+			slog.String("id", songUUID.String()), slog.Int("revision", revision))
+
+		return d.Transaction(func(tx *gorm.DB) error {
+			if result := tx.First(&song, "id = ?", songUUID); result.Error != nil {
+				return result.Error
+			}
+			var rev model.SongRevision
+			if result := tx.First(&rev, "song_id = ? AND revision = ?", songUUID, revision); result.Error != nil {
+				return result.Error
+			}
+			if err := checkLinkFree(tx, rev.Link, song.Id); err != nil {
+				return err
+			}
+
+			// откат - тоже правка руками, обогащение ее не перезапишет
+			song.ManualFields = song.EditedFields(model.Song{ReleaseDate: rev.ReleaseDate, Text: rev.Text, Link: rev.Link}, false)
+			song.EnrichmentSources = song.EnrichmentSources.Without(song.ManualFields)
+			song.Group, song.Title, song.ReleaseDate, song.Text, song.Link = rev.Group, rev.Title, rev.ReleaseDate, rev.Text, rev.Link
+			// в ревизии хранится только имя, исполнитель находится или создается по нему заново
+			song.ArtistId = uuid.Nil
+			if err := resolveArtist(tx, &song); err != nil {
+				return err
+			}
+			current := song.Version
+			song.Version = current + 1
+			result := tx.Model(&song).
+				Where("version = ?", current).
+				Select("group", "artist_id", "title", "release_date", "text", "link", "manual_fields", "enrichment_sources", "version").
+				Updates(&song)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrVersionConflict
+			}
+			if err := writeRevision(tx, song, model.RevisionRevert); err != nil {
+				return err
+			}
+			return loadLabels(tx, []*model.Song{&song})
+		})
+	}); err != nil {
+		return model.Song{}, err
+	}
+	return song, nil
+}
+
+// writeRevision сохраняет снимок песни следующим номером ревизии, вызывается внутри транзакции
+func writeRevision(tx *gorm.DB, song model.Song, op model.RevisionOperation) error {
+	var last int
+	if result := tx.Model(&model.SongRevision{}).
+		Select("COALESCE(MAX(revision), 0)").
+		Where("song_id = ?", song.Id).
+		Scan(&last); result.Error != nil {
+		return result.Error
+	}
+	return tx.Create(&model.SongRevision{
+		SongId:      song.Id,
+		Revision:    last + 1,
+		Operation:   op,
+		Group:       song.Group,
+		Title:       song.Title,
+		ReleaseDate: song.ReleaseDate,
+		Text:        song.Text,
+		Link:        song.Link,
+	}).Error
+}
+
+// checkLinkFree - ссылка должна быть свободна среди остальных песен не из корзины
+func checkLinkFree(tx *gorm.DB, link string, owner uuid.UUID) error {
+	// пустая ссылка у еще не обогащенных песен не уникальна
+	if link == "" {
+		return nil
+	}
+	var taken int64
+	if result := tx.Model(&model.Song{}).Where("link = ? AND id <> ?", link, owner).Count(&taken); result.Error != nil {
+		return result.Error
+	}
+	if taken > 0 {
+		return ErrDuplicateLink
+	}
+	return nil
+}
+
+// selectFields ограничивает select колонками из fields и required. id читается всегда
+func selectFields(query *gorm.DB, fields model.FieldSet, required ...string) *gorm.DB {
+	columns := fields.Columns(append([]string{"id"}, required...)...)
+	if columns == nil {
+		return query
+	}
+	return query.Select(columns)
+}
+
+func needsLabels(fields model.FieldSet) bool {
+	return fields.Has("tags") || fields.Has("genres")
+}
+
+// loadSelectedLabels грузит метки, только если они попали в fields
+func loadSelectedLabels(tx *gorm.DB, songs []model.Song, fields model.FieldSet) error {
+	if !needsLabels(fields) {
+		return nil
+	}
+	return loadLabels(tx, songPtrs(songs))
+}
+
+// applyFilter добавляет условия SongFilter к запросу, общий для offset и keyset пагинации
+func applyFilter(query *gorm.DB, log *slog.Logger, filter model.SongFilter) *gorm.DB {
+	if filter.Id != nil {
+		query = query.Where("id = ?", *filter.Id)
+		log.Debug("filter detected", slog.String("filter_id", (*filter.Id).String()))
+	}
+	if filter.AlbumId != nil {
+		query = query.Where("id IN (SELECT song_id FROM album_tracks WHERE album_id = ?)", *filter.AlbumId)
+		log.Debug("filter detected", slog.String("filter_album_id", (*filter.AlbumId).String()))
+	}
+	if len(filter.Tags) > 0 {
+		query = applyLabelFilter(query, model.LabelTag, filter.Tags, filter.TagMode)
+		log.Debug("filter detected", slog.Any("filter_tags", filter.Tags), slog.String("mode", string(filter.TagMode)))
+	}
+	if len(filter.Genres) > 0 {
+		query = applyLabelFilter(query, model.LabelGenre, filter.Genres, filter.GenreMode)
+		log.Debug("filter detected", slog.Any("filter_genres", filter.Genres), slog.String("mode", string(filter.GenreMode)))
+	}
+	if filter.Group != nil {
+		query = applyTextFilter(query, `"group"`, filter.Group, filter.GroupMatch)
+		log.Debug("filter detected", slog.String("filter_group", (*filter.Group)), slog.String("match", string(filter.GroupMatch)))
+	}
+	if filter.Title != nil {
+		query = applyTextFilter(query, "title", filter.Title, filter.TitleMatch)
+		log.Debug("filter detected", slog.String("filter_title", (*filter.Title)), slog.String("match", string(filter.TitleMatch)))
+	}
+	if filter.ReleaseDate != nil {
+		query = query.Where("release_date = ?", *filter.ReleaseDate)
+		log.Debug("filter detected", slog.String("filter_release_date", (*filter.ReleaseDate).String()))
+	}
+	if filter.Text != nil {
+		query = query.Where("text = ?", *filter.Text)
+		log.Debug("filter detected", slog.String("filter_text", (*filter.Text)))
+	}
+	if filter.Link != nil {
+		query = query.Where("link = ?", *filter.Link)
+		log.Debug("filter detected", slog.String("filter_link", (*filter.Link)))
+	}
+	if filter.Enrichment != nil {
+		query = query.Where("enrichment_status = ?", *filter.Enrichment)
+		log.Debug("filter detected", slog.String("filter_enrichment_status", string(*filter.Enrichment)))
+	}
+	if filter.EnrichedBefore != nil {
+		query = query.Where("COALESCE(enriched_at, created_at) < ?", *filter.EnrichedBefore)
+		log.Debug("filter detected", slog.Time("filter_enriched_before", *filter.EnrichedBefore))
+	}
+	return query
+}
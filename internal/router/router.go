@@ -3,6 +3,7 @@ package router
 import (
 	"log/slog"
 	"online-song-library/internal/controller"
+	"online-song-library/internal/validation"
 
 	"github.com/gin-gonic/gin"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -14,18 +15,66 @@ import (
 func SetupRouter(songController *controller.SongController, log *slog.Logger) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	validation.Register()
 
-	router.Use(gin.Recovery())
+	router.Use(gin.CustomRecovery(controller.RecoveryHandler(log)))
+	router.Use(controller.RequestIdMiddleware())
 	router.Use(func(c *gin.Context) {
-		log.Debug("INCOMING REQUEST", slog.String("method", c.Request.Method), slog.String("path", c.Request.URL.Path) , slog.String("IP", c.ClientIP()))
+		log.Debug("INCOMING REQUEST", slog.String("method", c.Request.Method), slog.String("path", c.Request.URL.Path) , slog.String("IP", c.ClientIP()), slog.String("request_id", controller.RequestId(c)))
 		c.Next()
 	})
+	router.Use(controller.ErrorMiddleware(log))
+	router.NoRoute(controller.NoRoute)
+
+	// счетчики и состояние breaker внешнего апи (music_info) и кэша обогащения в формате expvar
+	router.GET("/debug/vars", controller.DebugVars("music_info", "enrichment_cache"))
 
 	router.POST("/songs", songController.CreateSong)
+	router.GET("/songs/:id", songController.GetSong)
 	router.PUT("/songs/:id", songController.UpdateSong)
+	router.PATCH("/songs/:id", songController.PatchSong)
 	router.DELETE("/songs/:id", songController.DeleteSong)
 	router.GET("/songs", songController.GetLibrary)
+	router.GET("/songs/search", songController.SearchSongs)
+	router.GET("/songs/trash", songController.GetTrash)
+	router.POST("/songs/:id/restore", songController.RestoreSong)
+	router.POST("/songs/enrich", songController.EnrichSongs)
+	router.POST("/songs/:id/enrich", songController.EnrichSong)
+	router.GET("/songs/:id/revisions", songController.GetSongRevisions)
+	router.GET("/songs/:id/revisions/:revision", songController.GetSongRevision)
+	router.POST("/songs/:id/revisions/:revision/revert", songController.RevertSong)
 	router.GET("/songs/:id/verses", songController.GetSongVerses)
+	router.POST("/songs/:id/tags", songController.AddSongTags)
+	router.DELETE("/songs/:id/tags/:name", songController.RemoveSongTag)
+	router.POST("/songs/:id/genres", songController.AddSongGenres)
+	router.DELETE("/songs/:id/genres/:name", songController.RemoveSongGenre)
+	router.GET("/tags", songController.GetTags)
+	router.GET("/genres", songController.GetGenres)
+
+	router.POST("/artists", songController.CreateArtist)
+	router.GET("/artists", songController.GetArtists)
+	router.GET("/artists/:id", songController.GetArtist)
+	router.PUT("/artists/:id", songController.UpdateArtist)
+	router.DELETE("/artists/:id", songController.DeleteArtist)
+	router.GET("/artists/:id/songs", songController.GetArtistSongs)
+
+	router.POST("/albums", songController.CreateAlbum)
+	router.GET("/albums", songController.GetAlbums)
+	router.GET("/albums/:id", songController.GetAlbum)
+	router.PUT("/albums/:id", songController.UpdateAlbum)
+	router.DELETE("/albums/:id", songController.DeleteAlbum)
+	router.POST("/albums/:id/tracks", songController.AddAlbumTrack)
+	router.PUT("/albums/:id/tracks", songController.ReorderAlbumTracks)
+	router.DELETE("/albums/:id/tracks/:song_id", songController.RemoveAlbumTrack)
+
+	router.POST("/playlists", songController.CreatePlaylist)
+	router.GET("/playlists", songController.GetPlaylists)
+	router.GET("/playlists/:id", songController.GetPlaylist)
+	router.PUT("/playlists/:id", songController.RenamePlaylist)
+	router.DELETE("/playlists/:id", songController.DeletePlaylist)
+	router.POST("/playlists/:id/songs", songController.AddPlaylistSong)
+	router.DELETE("/playlists/:id/songs/:position", songController.RemovePlaylistSong)
+	router.POST("/playlists/:id/songs/:position/move", songController.MovePlaylistSong)
 
 	// swagger UI
 	router.GET("/api/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type AlbumService interface {
+	CreateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error)
+	UpdateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error)
+	DeleteAlbum(ctx context.Context, log *slog.Logger, albumId uuid.UUID) error
+	GetAlbum(ctx context.Context, log *slog.Logger, albumId uuid.UUID) (model.AlbumDetails, error)
+	GetAlbums(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Album, error)
+	AddAlbumTrack(ctx context.Context, log *slog.Logger, albumId, songId uuid.UUID, position int) (model.AlbumDetails, error)
+	RemoveAlbumTrack(ctx context.Context, log *slog.Logger, albumId, songId uuid.UUID) (model.AlbumDetails, error)
+	ReorderAlbumTracks(ctx context.Context, log *slog.Logger, albumId uuid.UUID, songIds []uuid.UUID) (model.AlbumDetails, error)
+}
+
+func (s *SongService) CreateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	return s.repo.CreateAlbum(ctx, log, album)
+}
+
+func (s *SongService) UpdateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	return s.repo.UpdateAlbum(ctx, log, album)
+}
+
+func (s *SongService) DeleteAlbum(ctx context.Context, log *slog.Logger, albumId uuid.UUID) error {
+	return s.repo.DeleteAlbum(ctx, log, albumId)
+}
+
+func (s *SongService) GetAlbum(ctx context.Context, log *slog.Logger, albumId uuid.UUID) (model.AlbumDetails, error) {
+	return s.repo.GetAlbum(ctx, log, albumId)
+}
+
+func (s *SongService) GetAlbums(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Album, error) {
+	return s.repo.GetAlbums(ctx, log, limit, offset)
+}
+
+func (s *SongService) AddAlbumTrack(ctx context.Context, log *slog.Logger, albumId, songId uuid.UUID, position int) (model.AlbumDetails, error) {
+	return s.repo.AddAlbumTrack(ctx, log, albumId, songId, position)
+}
+
+func (s *SongService) RemoveAlbumTrack(ctx context.Context, log *slog.Logger, albumId, songId uuid.UUID) (model.AlbumDetails, error) {
+	return s.repo.RemoveAlbumTrack(ctx, log, albumId, songId)
+}
+
+func (s *SongService) ReorderAlbumTracks(ctx context.Context, log *slog.Logger, albumId uuid.UUID, songIds []uuid.UUID) (model.AlbumDetails, error) {
+	return s.repo.ReorderAlbumTracks(ctx, log, albumId, songIds)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type ArtistService interface {
+	CreateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error)
+	UpdateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error)
+	DeleteArtist(ctx context.Context, log *slog.Logger, artistId uuid.UUID) error
+	GetArtist(ctx context.Context, log *slog.Logger, artistId uuid.UUID) (model.Artist, error)
+	GetArtists(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Artist, error)
+	GetArtistSongs(ctx context.Context, log *slog.Logger, artistId uuid.UUID, limit, offset int) ([]model.Song, error)
+}
+
+func (s *SongService) CreateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	return s.repo.CreateArtist(ctx, log, artist)
+}
+
+func (s *SongService) UpdateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	return s.repo.UpdateArtist(ctx, log, artist)
+}
+
+func (s *SongService) DeleteArtist(ctx context.Context, log *slog.Logger, artistId uuid.UUID) error {
+	return s.repo.DeleteArtist(ctx, log, artistId)
+}
+
+func (s *SongService) GetArtist(ctx context.Context, log *slog.Logger, artistId uuid.UUID) (model.Artist, error) {
+	return s.repo.GetArtist(ctx, log, artistId)
+}
+
+func (s *SongService) GetArtists(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Artist, error) {
+	return s.repo.GetArtists(ctx, log, limit, offset)
+}
+
+func (s *SongService) GetArtistSongs(ctx context.Context, log *slog.Logger, artistId uuid.UUID, limit, offset int) ([]model.Song, error) {
+	return s.repo.GetArtistSongs(ctx, log, artistId, limit, offset)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EnrichmentService interface {
+	GetPendingEnrichment(ctx context.Context, log *slog.Logger, limit int) ([]model.Song, error)
+	EnrichSong(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error)
+	UpdateEnrichment(ctx context.Context, log *slog.Logger, update model.EnrichmentUpdate) (model.Song, error)
+	ReenrichSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, force bool) (model.Song, error)
+	QueueEnrichment(ctx context.Context, log *slog.Logger, req model.EnrichmentRequest) (int64, error)
+	RefreshEnrichment(ctx context.Context, log *slog.Logger, maxAge time.Duration) (int64, error)
+	// EnrichmentSignal срабатывает, когда появилась новая pending песня, чтобы воркер не ждал своего интервала
+	EnrichmentSignal() <-chan struct{}
+}
+
+// GetPendingEnrichment возвращает pending песни, чья очередная попытка уже наступила
+func (s *SongService) GetPendingEnrichment(ctx context.Context, log *slog.Logger, limit int) ([]model.Song, error) {
+	return s.repo.GetPendingEnrichment(ctx, log, time.Now().UTC(), limit)
+}
+
+// EnrichSong запрашивает детали песни у провайдеров обогащения и помечает ее done.
+// Поля, исправленные руками, перезаписываются, только если песню поставили в очередь с force.
+// Ошибку апи сохраняет не он, а воркер: только тот знает, будет ли повтор
+func (s *SongService) EnrichSong(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
+	details, err := s.FetchSongDetailsFromAPI(ctx, log, song.Group, song.Title)
+	if err != nil {
+		return model.Song{}, err
+	}
+	return s.repo.UpdateEnrichment(ctx, log, model.EnrichmentUpdate{
+		SongId:   song.Id,
+		Status:   model.EnrichmentDone,
+		Attempts: song.EnrichmentAttempts + 1,
+		Details:  &details,
+		Force:    song.EnrichmentForce,
+	})
+}
+
+// ReenrichSong сразу обогащает песню заново. При ошибке апи статус песни не меняется
+func (s *SongService) ReenrichSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, force bool) (model.Song, error) {
+	song, err := s.repo.Get(ctx, log, songId)
+	if err != nil {
+		return model.Song{}, err
+	}
+	song.EnrichmentAttempts = 0
+	song.EnrichmentForce = force
+	return s.EnrichSong(ctx, log, song)
+}
+
+// QueueEnrichment отдает песни под фильтром воркеру на повторное обогащение и возвращает их число
+func (s *SongService) QueueEnrichment(ctx context.Context, log *slog.Logger, req model.EnrichmentRequest) (int64, error) {
+	queued, err := s.repo.QueueEnrichment(ctx, log, req)
+	if err != nil {
+		return 0, err
+	}
+	if queued > 0 {
+		s.signalEnrichment()
+	}
+	return queued, nil
+}
+
+// RefreshEnrichment ставит в очередь песни, обогащенные дольше maxAge назад. Ручные правки сохраняются.
+// Песни, для которых детали не нашлись, по расписанию не повторяются, только по запросу
+func (s *SongService) RefreshEnrichment(ctx context.Context, log *slog.Logger, maxAge time.Duration) (int64, error) {
+	before := time.Now().UTC().Add(-maxAge)
+	return s.QueueEnrichment(ctx, log, model.EnrichmentRequest{Filter: model.SongFilter{EnrichedBefore: &before}, SkipFailed: true})
+}
+
+func (s *SongService) UpdateEnrichment(ctx context.Context, log *slog.Logger, update model.EnrichmentUpdate) (model.Song, error) {
+	return s.repo.UpdateEnrichment(ctx, log, update)
+}
+
+func (s *SongService) EnrichmentSignal() <-chan struct{} {
+	return s.enrichmentSignal
+}
+
+// signalEnrichment не блокируется: один непрочитанный сигнал уже разбудит воркер
+func (s *SongService) signalEnrichment() {
+	select {
+	case s.enrichmentSignal <- struct{}{}:
+	default:
+	}
+}
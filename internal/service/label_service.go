@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type LabelService interface {
+	AddSongLabels(ctx context.Context, log *slog.Logger, songId uuid.UUID, kind model.LabelKind, names []string) (model.Song, error)
+	RemoveSongLabel(ctx context.Context, log *slog.Logger, songId uuid.UUID, kind model.LabelKind, name string) (model.Song, error)
+	GetLabels(ctx context.Context, log *slog.Logger, kind model.LabelKind) ([]string, error)
+}
+
+func (s *SongService) AddSongLabels(ctx context.Context, log *slog.Logger, songId uuid.UUID, kind model.LabelKind, names []string) (model.Song, error) {
+	return s.repo.AddSongLabels(ctx, log, songId, kind, names)
+}
+
+func (s *SongService) RemoveSongLabel(ctx context.Context, log *slog.Logger, songId uuid.UUID, kind model.LabelKind, name string) (model.Song, error) {
+	return s.repo.RemoveSongLabel(ctx, log, songId, kind, name)
+}
+
+func (s *SongService) GetLabels(ctx context.Context, log *slog.Logger, kind model.LabelKind) ([]string, error) {
+	return s.repo.GetLabels(ctx, log, kind)
+}
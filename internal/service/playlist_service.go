@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type PlaylistService interface {
+	CreatePlaylist(ctx context.Context, log *slog.Logger, playlist model.Playlist) (model.Playlist, error)
+	RenamePlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, name string) (model.Playlist, error)
+	DeletePlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID) error
+	GetPlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID) (model.PlaylistDetails, error)
+	GetPlaylists(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Playlist, error)
+	AddPlaylistSong(ctx context.Context, log *slog.Logger, playlistId, songId uuid.UUID, position int) (model.PlaylistDetails, error)
+	RemovePlaylistSong(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, position int) (model.PlaylistDetails, error)
+	MovePlaylistSong(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, from, to int) (model.PlaylistDetails, error)
+}
+
+func (s *SongService) CreatePlaylist(ctx context.Context, log *slog.Logger, playlist model.Playlist) (model.Playlist, error) {
+	return s.repo.CreatePlaylist(ctx, log, playlist)
+}
+
+func (s *SongService) RenamePlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, name string) (model.Playlist, error) {
+	return s.repo.RenamePlaylist(ctx, log, playlistId, name)
+}
+
+func (s *SongService) DeletePlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID) error {
+	return s.repo.DeletePlaylist(ctx, log, playlistId)
+}
+
+func (s *SongService) GetPlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID) (model.PlaylistDetails, error) {
+	return s.repo.GetPlaylist(ctx, log, playlistId)
+}
+
+func (s *SongService) GetPlaylists(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Playlist, error) {
+	return s.repo.GetPlaylists(ctx, log, limit, offset)
+}
+
+func (s *SongService) AddPlaylistSong(ctx context.Context, log *slog.Logger, playlistId, songId uuid.UUID, position int) (model.PlaylistDetails, error) {
+	return s.repo.AddPlaylistSong(ctx, log, playlistId, songId, position)
+}
+
+func (s *SongService) RemovePlaylistSong(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, position int) (model.PlaylistDetails, error) {
+	return s.repo.RemovePlaylistSong(ctx, log, playlistId, position)
+}
+
+func (s *SongService) MovePlaylistSong(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, from, to int) (model.PlaylistDetails, error) {
+	return s.repo.MovePlaylistSong(ctx, log, playlistId, from, to)
+}
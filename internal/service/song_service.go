@@ -1,52 +1,229 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
-	"net/url"
+	"online-song-library/internal/apperr"
+	"online-song-library/internal/enrichment"
 	"online-song-library/internal/model"
 	"online-song-library/internal/repository"
-	"os"
-	"strconv"
+	"online-song-library/internal/validation"
+	"online-song-library/pkg/jsonpatch"
+	"online-song-library/pkg/textsearch"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrInvalidCursor = apperr.Validation("Invalid cursor")
+	ErrInvalidPage   = apperr.Validation("Invalid page number")
+	// ErrUpstream - внешний апи недоступен или ответил ошибкой, причина лежит в Unwrap
+	ErrUpstream = apperr.Upstream("Failed to fetch song details")
+	// ErrInvalidPatch - патч не разобрался, ссылается на несуществующее поле или дает неверный документ
+	ErrInvalidPatch = apperr.Validation("Invalid patch")
+	// ErrPatchTestFailed - операция test из JSON Patch не совпала с сохраненной песней
+	ErrPatchTestFailed = apperr.Conflict("Patch test failed")
+)
+
+// patchAttempts - сколько раз PATCH без If-Match перечитывает песню, если ее изменили параллельно
+const patchAttempts = 3
+
 // for mocks
 type Service interface {
-	CreateSong(ctx context.Context, log *slog.Logger, song model.Song) (uuid.UUID, error)
+	ArtistService
+	AlbumService
+	LabelService
+	PlaylistService
+	EnrichmentService
+	CreateSong(ctx context.Context, log *slog.Logger, song model.Song, albumId *uuid.UUID) (uuid.UUID, error)
+	GetSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, fields model.FieldSet) (model.Song, error)
 	UpdateSong(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error)
+	PatchSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, version int, patch model.SongPatch) (model.SongPatchResult, error)
 	DeleteSong(ctx context.Context, log *slog.Logger, songId uuid.UUID) error
 	GetLibrary(ctx context.Context, log *slog.Logger, filter model.SongFilter, limit, offset int) ([]model.Song, error)
+	GetLibraryPage(ctx context.Context, log *slog.Logger, filter model.SongFilter, limit int, cursor string) (model.SongPage, error)
 	GetSongVerses(ctx context.Context, log *slog.Logger, songId uuid.UUID, page, pageSize int) ([]string, error)
 	FetchSongDetailsFromAPI(ctx context.Context, log *slog.Logger, group, title string) (model.Song, error)
+	SearchSongs(ctx context.Context, log *slog.Logger, query string, limit, offset int) ([]model.SongSearchResult, error)
+	GetTrash(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Song, error)
+	RestoreSong(ctx context.Context, log *slog.Logger, songId uuid.UUID) (model.Song, error)
+	PurgeTrash(ctx context.Context, log *slog.Logger, retention time.Duration) (int64, error)
+	GetSongRevisions(ctx context.Context, log *slog.Logger, songId uuid.UUID) ([]model.SongRevision, error)
+	GetSongRevision(ctx context.Context, log *slog.Logger, songId uuid.UUID, revision int) (model.SongRevision, error)
+	RevertSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, revision int) (model.Song, error)
 }
 
 
 type SongService struct {
-	repo repository.Repository
+	repo             repository.Repository
+	enricher         enrichment.Enricher
+	enrichmentSignal chan struct{}
 }
 
-func NewSongService(r repository.Repository) *SongService {
+func NewSongService(r repository.Repository, enricher enrichment.Enricher) *SongService {
 	return &SongService{
-		repo: r,
+		repo:             r,
+		enricher:         enricher,
+		enrichmentSignal: make(chan struct{}, 1),
 	}
 }
 
-func (s *SongService) CreateSong(ctx context.Context, log *slog.Logger, song model.Song) (uuid.UUID, error) {
-	return s.repo.Create(ctx, log, song)
+// CreateSong создает песню вместе с тегами и жанрами из song в одной транзакции.
+// Для pending песни будит воркер обогащения
+func (s *SongService) CreateSong(ctx context.Context, log *slog.Logger, song model.Song, albumId *uuid.UUID) (uuid.UUID, error) {
+	var songId uuid.UUID
+	if err := s.repo.WithinTx(ctx, log, func(ctx context.Context) error {
+		var err error
+		songId, err = s.repo.Create(ctx, log, song)
+		if err != nil {
+			return err
+		}
+		if len(song.Tags) > 0 {
+			if _, err := s.repo.AddSongLabels(ctx, log, songId, model.LabelTag, song.Tags); err != nil {
+				return err
+			}
+		}
+		if len(song.Genres) > 0 {
+			if _, err := s.repo.AddSongLabels(ctx, log, songId, model.LabelGenre, song.Genres); err != nil {
+				return err
+			}
+		}
+		if albumId != nil {
+			if _, err := s.repo.AddAlbumTrack(ctx, log, *albumId, songId, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return uuid.Nil, err
+	}
+	if song.EnrichmentStatus == model.EnrichmentPending {
+		s.signalEnrichment()
+	}
+	return songId, nil
+}
+
+// GetSong читает песню целиком или только поля из fields
+func (s *SongService) GetSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, fields model.FieldSet) (model.Song, error) {
+	return s.repo.GetFields(ctx, log, songId, fields)
 }
 
 func (s *SongService) UpdateSong(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
 	return s.repo.Update(ctx, log, song)
 }
 
+// PatchSong применяет patch к сохраненной песне и записывает результат как есть, включая пустые значения.
+// version - ожидаемая версия из If-Match, 0 - без проверки. Проверяются только измененные поля
+func (s *SongService) PatchSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, version int, patch model.SongPatch) (model.SongPatchResult, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := s.patchSong(ctx, log, songId, version, patch)
+		if version == 0 && errors.Is(err, repository.ErrVersionConflict) && attempt < patchAttempts {
+			log.Debug("song changed while patching, retrying", slog.Int("attempt", attempt))
+			continue
+		}
+		return result, err
+	}
+}
+
+func (s *SongService) patchSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, version int, patch model.SongPatch) (model.SongPatchResult, error) {
+	song, err := s.repo.Get(ctx, log, songId)
+	if err != nil {
+		return model.SongPatchResult{}, err
+	}
+	if version != 0 && version != song.Version {
+		return model.SongPatchResult{}, repository.ErrVersionConflict
+	}
+
+	before := song.Fields()
+	after, err := applySongPatch(before, patch)
+	if err != nil {
+		return model.SongPatchResult{}, err
+	}
+
+	changes := songChanges(before, after)
+	if len(changes) == 0 {
+		return model.SongPatchResult{Song: song, Changes: changes}, nil
+	}
+	changed := make([]string, len(changes))
+	for i, change := range changes {
+		changed[i] = change.Field
+	}
+	if err := validation.Struct(after, changed...); err != nil {
+		return model.SongPatchResult{}, err
+	}
+
+	// запись с прочитанной версией: параллельное изменение даст ErrVersionConflict, а не потерю данных
+	song.SetFields(after)
+	updated, err := s.repo.Replace(ctx, log, song)
+	if err != nil {
+		return model.SongPatchResult{}, err
+	}
+	return model.SongPatchResult{Song: updated, Changes: changes}, nil
+}
+
+func applySongPatch(fields model.SongFields, patch model.SongPatch) (model.SongFields, error) {
+	doc, err := json.Marshal(fields)
+	if err != nil {
+		return model.SongFields{}, err
+	}
+
+	var patched []byte
+	switch patch.Format {
+	case model.PatchMerge:
+		patched, err = jsonpatch.MergePatch(doc, patch.Body)
+	case model.PatchJSON:
+		patched, err = jsonpatch.Apply(doc, patch.Body)
+	default:
+		return model.SongFields{}, fmt.Errorf("unknown patch format %q", patch.Format)
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return model.SongFields{}, ErrPatchTestFailed.Wrap(err)
+	}
+	if err != nil {
+		return model.SongFields{}, ErrInvalidPatch.Wrap(err).WithField("body", err.Error())
+	}
+
+	// неизвестное поле - ошибка клиента, а не молча пропущенное изменение
+	var result model.SongFields
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return model.SongFields{}, ErrInvalidPatch.Wrap(err).WithField("body", err.Error())
+	}
+	return result, nil
+}
+
+// songChanges - отличающиеся поля в порядке model.SongFields
+func songChanges(before, after model.SongFields) []model.FieldChange {
+	changes := []model.FieldChange{}
+	add := func(field string, from, to any) {
+		changes = append(changes, model.FieldChange{Field: field, Old: from, New: to})
+	}
+	if before.Group != after.Group {
+		add("group", before.Group, after.Group)
+	}
+	if before.Title != after.Title {
+		add("song", before.Title, after.Title)
+	}
+	if !before.ReleaseDate.Equal(after.ReleaseDate) {
+		add("release_date", before.ReleaseDate, after.ReleaseDate)
+	}
+	if before.Text != after.Text {
+		add("text", before.Text, after.Text)
+	}
+	if before.Link != after.Link {
+		add("link", before.Link, after.Link)
+	}
+	return changes
+}
+
 func (s *SongService) DeleteSong(ctx context.Context, log *slog.Logger, songId uuid.UUID) error {
 	return s.repo.Delete(ctx, log, songId)
 }
@@ -55,6 +232,73 @@ func (s *SongService) GetLibrary(ctx context.Context, log *slog.Logger, filter m
 	return s.repo.GetAll(ctx, log, limit, offset, filter)
 }
 
+func (s *SongService) GetTrash(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Song, error) {
+	return s.repo.GetTrash(ctx, log, limit, offset)
+}
+
+func (s *SongService) RestoreSong(ctx context.Context, log *slog.Logger, songId uuid.UUID) (model.Song, error) {
+	return s.repo.Restore(ctx, log, songId)
+}
+
+// PurgeTrash окончательно удаляет песни, пролежавшие в корзине дольше retention
+func (s *SongService) PurgeTrash(ctx context.Context, log *slog.Logger, retention time.Duration) (int64, error) {
+	return s.repo.Purge(ctx, log, time.Now().UTC().Add(-retention))
+}
+
+func (s *SongService) GetSongRevisions(ctx context.Context, log *slog.Logger, songId uuid.UUID) ([]model.SongRevision, error) {
+	return s.repo.GetRevisions(ctx, log, songId)
+}
+
+func (s *SongService) GetSongRevision(ctx context.Context, log *slog.Logger, songId uuid.UUID, revision int) (model.SongRevision, error) {
+	return s.repo.GetRevision(ctx, log, songId, revision)
+}
+
+func (s *SongService) RevertSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, revision int) (model.Song, error) {
+	return s.repo.Revert(ctx, log, songId, revision)
+}
+
+// GetLibraryPage - курсорная пагинация: пустой cursor означает первую страницу.
+// Запрашивается на одну запись больше limit, чтобы понять, есть ли следующая страница.
+func (s *SongService) GetLibraryPage(ctx context.Context, log *slog.Logger, filter model.SongFilter, limit int, cursor string) (model.SongPage, error) {
+	var cur *model.SongCursor
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil {
+			log.Error("failed to decode cursor", slog.String("err", err.Error()))
+			return model.SongPage{}, ErrInvalidCursor
+		}
+		cur = &decoded
+	}
+
+	songs, err := s.repo.GetAllKeyset(ctx, log, limit+1, cur, filter)
+	if err != nil {
+		log.Error("failed to get library page", slog.String("err", err.Error()))
+		return model.SongPage{}, err
+	}
+
+	backward := cur != nil && cur.Direction == model.CursorPrev
+	hasMore := len(songs) > limit
+	if hasMore && backward {
+		songs = songs[1:]
+	} else if hasMore {
+		songs = songs[:limit]
+	}
+
+	page := model.SongPage{Songs: songs}
+	if len(songs) == 0 {
+		return page, nil
+	}
+	if hasMore || backward {
+		next := encodeCursor(songs[len(songs)-1], model.CursorNext)
+		page.NextCursor = &next
+	}
+	if (hasMore && backward) || (cur != nil && !backward) {
+		prev := encodeCursor(songs[0], model.CursorPrev)
+		page.PrevCursor = &prev
+	}
+	return page, nil
+}
+
 func (s *SongService) GetSongVerses(ctx context.Context, log *slog.Logger, songId uuid.UUID, page, pageSize int) ([]string, error) {
 	text, err := s.repo.GetVerses(ctx, log, songId)
 	if err != nil {
@@ -70,7 +314,7 @@ func (s *SongService) GetSongVerses(ctx context.Context, log *slog.Logger, songI
 	totalPages := (totalVerses + pageSize - 1) / pageSize
 
 	if page < 1 || page > totalPages {
-		return nil, errors.New("invalid page number")
+		return nil, ErrInvalidPage
 	}
 
 	start := (page - 1) * pageSize
@@ -91,45 +335,61 @@ func (s *SongService) GetSongVerses(ctx context.Context, log *slog.Logger, songI
 	return finalVerses, nil
 }
 
-func (s *SongService) FetchSongDetailsFromAPI(ctx context.Context, log *slog.Logger, group, title string) (model.Song, error) {
-	path := os.Getenv("PATH_EXTERNAL_API_HTTPTEST_SERVER")
-
-	baseURL := fmt.Sprintf("%s/info", path)
-	params := url.Values{}
-	params.Add("group", group)
-	params.Add("song", title)
-
-	apiURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
-	log.Debug("Request URL", slog.String("url", apiURL))
-
-	resp, err := http.Get(apiURL)
+// SearchSongs ищет песни по тексту и подсвечивает самый подходящий куплет
+func (s *SongService) SearchSongs(ctx context.Context, log *slog.Logger, query string, limit, offset int) ([]model.SongSearchResult, error) {
+	results, err := s.repo.Search(ctx, log, query, limit, offset)
 	if err != nil {
-		return model.Song{}, err
+		log.Error("failed to search songs", slog.String("err", err.Error()))
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return model.Song{}, errors.New("external API status: " + strconv.Itoa(resp.StatusCode))
+	terms := textsearch.Terms(query)
+	for i := range results {
+		results[i].Snippet = textsearch.Snippet(results[i].Text, terms)
 	}
+	return results, nil
+}
 
-	var songDetails model.Song
-
-	tmp := struct {
-		ReleaseDate string
-		Text        string
-		Link        string
-	}{}
+// FetchSongDetailsFromAPI обогащает песню данными провайдеров, их ошибка - ErrUpstream.
+// EnrichmentSources результата - провайдер каждого найденного поля
+func (s *SongService) FetchSongDetailsFromAPI(ctx context.Context, log *slog.Logger, group, title string) (model.Song, error) {
+	song, err := s.fetchSongDetails(ctx, log, group, title)
+	if err != nil {
+		return model.Song{}, ErrUpstream.Wrap(err)
+	}
+	return song, nil
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&tmp); err != nil {
+func (s *SongService) fetchSongDetails(ctx context.Context, log *slog.Logger, group, title string) (model.Song, error) {
+	result, err := s.enricher.Enrich(ctx, log, group, title)
+	if err != nil {
 		return model.Song{}, err
 	}
+	// дата может отсутствовать, тогда ее подставляет альбом песни
+	return model.Song{
+		ReleaseDate:       result.ReleaseDate,
+		Text:              result.Text,
+		Link:              result.Link,
+		EnrichmentSources: result.Sources,
+	}, nil
+}
 
-	songDetails.ReleaseDate, err = time.Parse("02.01.2006", tmp.ReleaseDate)
+func encodeCursor(song model.Song, direction model.CursorDirection) string {
+	raw, _ := json.Marshal(model.SongCursor{CreatedAt: song.CreatedAt, Id: song.Id, Direction: direction})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(token string) (model.SongCursor, error) {
+	var cursor model.SongCursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
 	if err != nil {
-		return model.Song{}, err
+		return cursor, err
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.Direction != model.CursorNext && cursor.Direction != model.CursorPrev {
+		return cursor, errors.New("unknown cursor direction")
 	}
-	songDetails.Link = tmp.Link
-	songDetails.Text = tmp.Text
-	
-	return songDetails, nil
+	return cursor, nil
 }
@@ -0,0 +1,128 @@
+// Package validation проверяет тела запросов по тегам binding в model и переводит нарушения
+// в ошибки валидации с полями
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"online-song-library/internal/apperr"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+// minReleaseDate - раньше первой сохранившейся звукозаписи песня выйти не могла
+var minReleaseDate = time.Date(1860, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var registerOnce sync.Once
+
+// Register добавляет в валидатор gin теги notblank и release_date,
+// а в ошибки полей - имена из json вместо имен полей go
+func Register() {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(jsonFieldName)
+		if err := v.RegisterValidation("notblank", validators.NotBlank); err != nil {
+			panic(err)
+		}
+		if err := v.RegisterValidation("release_date", validReleaseDate); err != nil {
+			panic(err)
+		}
+	})
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// validReleaseDate - пустая дата допустима, иначе от minReleaseDate до текущего момента
+func validReleaseDate(fl validator.FieldLevel) bool {
+	date, ok := fl.Field().Interface().(time.Time)
+	if !ok {
+		return false
+	}
+	return date.IsZero() || !date.Before(minReleaseDate) && !date.After(time.Now())
+}
+
+// Struct проверяет v по тегам binding, нестандартные теги регистрируются при первом вызове. Если заданы fields, в ошибку попадают только эти поля -
+// так изменение одних полей не упирается в старые неверные значения других
+func Struct(v any, fields ...string) error {
+	Register()
+	err := binding.Validator.ValidateStruct(v)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if len(fields) > 0 && errors.As(err, &fieldErrs) {
+		fieldErrs = slices.DeleteFunc(slices.Clone(fieldErrs), func(fieldErr validator.FieldError) bool {
+			return !slices.Contains(fields, fieldPath(fieldErr))
+		})
+		if len(fieldErrs) == 0 {
+			return nil
+		}
+		err = fieldErrs
+	}
+	return Error(err)
+}
+
+// Error - тело запроса не разобралось или не прошло валидацию.
+// Поля известны для правил binding и для ошибок типа json
+func Error(err error) *apperr.Error {
+	problem := apperr.Validation("Invalid input").Wrap(err)
+
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		for _, fieldErr := range fieldErrs {
+			problem = problem.WithField(fieldPath(fieldErr), fieldMessage(fieldErr))
+		}
+		return problem
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return problem.WithField(typeErr.Field, "unexpected "+typeErr.Value)
+	}
+	return problem.WithField("body", err.Error())
+}
+
+// fieldPath - путь поля без имени структуры: tags[0], а не SongDTO.tags[0]
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, found := strings.Cut(fieldErr.Namespace(), ".")
+	if !found {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+func fieldMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "max":
+		if fieldErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at most %s items", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters", fieldErr.Param())
+	case "http_url":
+		return "must be a valid http(s) URL"
+	case "release_date":
+		return fmt.Sprintf("must be between %s and now", minReleaseDate.Format(time.DateOnly))
+	}
+	return "is invalid"
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/service"
+	"time"
+)
+
+// EnrichmentRefresher периодически отдает EnrichmentWorker песни, обогащенные дольше maxAge назад
+type EnrichmentRefresher struct {
+	serv     service.Service
+	log      *slog.Logger
+	interval time.Duration
+	maxAge   time.Duration
+}
+
+func NewEnrichmentRefresher(serv service.Service, log *slog.Logger, interval, maxAge time.Duration) *EnrichmentRefresher {
+	return &EnrichmentRefresher{
+		serv:     serv,
+		log:      log,
+		interval: interval,
+		maxAge:   maxAge,
+	}
+}
+
+// Run блокируется до отмены ctx, первое обновление - сразу при старте
+func (r *EnrichmentRefresher) Run(ctx context.Context) {
+	r.log.Info("enrichment refresher started", slog.Duration("interval", r.interval), slog.Duration("max_age", r.maxAge))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			r.log.Info("enrichment refresher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *EnrichmentRefresher) refresh(ctx context.Context) {
+	queued, err := r.serv.RefreshEnrichment(ctx, r.log, r.maxAge)
+	if err != nil {
+		r.log.Error("failed to queue stale songs for enrichment", slog.String("err", err.Error()))
+		return
+	}
+	if queued > 0 {
+		r.log.Info("stale songs queued for enrichment", slog.Int64("songs", queued))
+	}
+}
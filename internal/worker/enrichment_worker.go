@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/enrichment"
+	"online-song-library/internal/model"
+	"online-song-library/internal/service"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EnrichmentConfig struct {
+	// Workers - сколько песен обогащается параллельно
+	Workers int
+	// Interval - как часто искать pending песни без сигнала от CreateSong
+	Interval time.Duration
+	// MaxAttempts попыток, после которых песня становится failed
+	MaxAttempts int
+	// повтор после n-й неудачи откладывается на BackoffBase*2^(n-1), но не больше BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// DrainTimeout - сколько после остановки ждать начатые попытки, прежде чем отменить их
+	DrainTimeout time.Duration
+}
+
+// EnrichmentWorker - пул воркеров, который обогащает pending песни данными внешнего апи
+// и откладывает неудачные попытки с экспоненциальной задержкой
+type EnrichmentWorker struct {
+	serv service.Service
+	log  *slog.Logger
+	cfg  EnrichmentConfig
+
+	mu       sync.Mutex
+	inFlight map[uuid.UUID]bool
+	// freed будит поиск, когда воркер освободился, а в очереди могли остаться песни
+	freed chan struct{}
+}
+
+func NewEnrichmentWorker(serv service.Service, log *slog.Logger, cfg EnrichmentConfig) *EnrichmentWorker {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	return &EnrichmentWorker{
+		serv:     serv,
+		log:      log,
+		cfg:      cfg,
+		inFlight: map[uuid.UUID]bool{},
+		freed:    make(chan struct{}, 1),
+	}
+}
+
+// Run блокируется до отмены ctx и дожидается начатых попыток. Первый поиск - сразу при старте.
+// Отмена ctx прекращает раздачу песен, а начатые попытки получают еще cfg.DrainTimeout, чтобы записать результат
+func (w *EnrichmentWorker) Run(ctx context.Context) {
+	w.log.Info("enrichment worker started", slog.Int("workers", w.cfg.Workers), slog.Duration("interval", w.cfg.Interval))
+
+	attemptCtx, cancelAttempts := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelAttempts()
+
+	jobs := make(chan model.Song)
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for song := range jobs {
+				w.enrich(attemptCtx, song)
+				w.release(song.Id)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		// пока находятся новые песни, ищем дальше без ожидания: отправка в jobs сама ждет свободный воркер
+		for w.dispatch(ctx, jobs) > 0 {
+		}
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			drain := time.AfterFunc(w.cfg.DrainTimeout, cancelAttempts)
+			wg.Wait()
+			drain.Stop()
+			w.log.Info("enrichment worker stopped")
+			return
+		case <-ticker.C:
+		case <-w.serv.EnrichmentSignal():
+		case <-w.freed:
+		}
+	}
+}
+
+// dispatch отдает воркерам pending песни, которые еще не в работе, и возвращает их число
+func (w *EnrichmentWorker) dispatch(ctx context.Context, jobs chan<- model.Song) int {
+	songs, err := w.serv.GetPendingEnrichment(ctx, w.log, w.cfg.Workers*4)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.log.Error("failed to get pending songs", slog.String("err", err.Error()))
+		}
+		return 0
+	}
+
+	sent := 0
+	for _, song := range songs {
+		if !w.acquire(song.Id) {
+			continue
+		}
+		select {
+		case jobs <- song:
+			sent++
+		case <-ctx.Done():
+			w.release(song.Id)
+			return 0
+		}
+	}
+	return sent
+}
+
+func (w *EnrichmentWorker) enrich(ctx context.Context, song model.Song) {
+	log := w.log.With(slog.String("song_id", song.Id.String()))
+
+	_, err := w.serv.EnrichSong(ctx, log, song)
+	if err == nil {
+		log.Info("song enriched", slog.Int("attempts", song.EnrichmentAttempts+1))
+		return
+	}
+	// при остановке песня остается pending и достанется следующему запуску
+	if ctx.Err() != nil {
+		return
+	}
+
+	update := model.EnrichmentUpdate{
+		SongId:   song.Id,
+		Status:   model.EnrichmentPending,
+		Attempts: song.EnrichmentAttempts + 1,
+	}
+	if errors.Is(err, enrichment.ErrNotFound) || update.Attempts >= w.cfg.MaxAttempts {
+		update.Status = model.EnrichmentFailed
+	} else {
+		next := time.Now().UTC().Add(w.backoff(update.Attempts))
+		update.NextAt = &next
+	}
+	log.Warn("failed to enrich song", slog.String("err", err.Error()),
+		slog.Int("attempts", update.Attempts), slog.String("status", string(update.Status)))
+
+	if _, err := w.serv.UpdateEnrichment(ctx, log, update); err != nil {
+		log.Error("failed to save enrichment status", slog.String("err", err.Error()))
+	}
+}
+
+func (w *EnrichmentWorker) backoff(attempts int) time.Duration {
+	delay := w.cfg.BackoffBase
+	for i := 1; i < attempts && delay < w.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, w.cfg.BackoffMax)
+}
+
+func (w *EnrichmentWorker) acquire(id uuid.UUID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.inFlight[id] {
+		return false
+	}
+	w.inFlight[id] = true
+	return true
+}
+
+func (w *EnrichmentWorker) release(id uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.inFlight, id)
+
+	select {
+	case w.freed <- struct{}{}:
+	default:
+	}
+}
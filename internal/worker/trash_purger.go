@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"online-song-library/internal/service"
+	"time"
+)
+
+// TrashPurger периодически окончательно удаляет песни, пролежавшие в корзине дольше retention
+type TrashPurger struct {
+	serv      service.Service
+	log       *slog.Logger
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewTrashPurger(serv service.Service, log *slog.Logger, interval, retention time.Duration) *TrashPurger {
+	return &TrashPurger{
+		serv:      serv,
+		log:       log,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Run блокируется до отмены ctx, первая очистка - сразу при старте
+func (p *TrashPurger) Run(ctx context.Context) {
+	p.log.Info("trash purger started", slog.Duration("interval", p.interval), slog.Duration("retention", p.retention))
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			p.log.Info("trash purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) purge(ctx context.Context) {
+	purged, err := p.serv.PurgeTrash(ctx, p.log, p.retention)
+	if err != nil {
+		p.log.Error("failed to purge trash", slog.String("err", err.Error()))
+		return
+	}
+	if purged > 0 {
+		p.log.Info("trash purged", slog.Int64("songs", purged))
+	}
+}
@@ -0,0 +1,112 @@
+package musicinfo
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	// outcomeIgnored - запрос отменил сам вызывающий, о здоровье апи это ничего не говорит
+	outcomeIgnored
+)
+
+// breaker размыкается после threshold неудач подряд и не пускает запросы cooldown.
+// Потом пропускает один пробный запрос: успех замыкает его, неудача снова размыкает.
+// threshold 0 отключает breaker
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	changes   int64
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow решает, можно ли сейчас идти в апи
+func (b *breaker) allow(log *slog.Logger) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(log, StateHalfOpen)
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *breaker) record(log *slog.Logger, result outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+	}
+	switch result {
+	case outcomeSuccess:
+		b.failures = 0
+		if b.state != StateClosed {
+			b.setState(log, StateClosed)
+		}
+	case outcomeFailure:
+		b.failures++
+		if b.state == StateHalfOpen || (b.state == StateClosed && b.threshold > 0 && b.failures >= b.threshold) {
+			b.openedAt = b.now()
+			b.setState(log, StateOpen)
+		}
+	}
+}
+
+// snapshot - текущее состояние и число его смен
+func (b *breaker) snapshot() (State, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.changes
+}
+
+func (b *breaker) setState(log *slog.Logger, state State) {
+	log.Warn("music info circuit breaker state changed",
+		slog.String("from", b.state.String()), slog.String("to", state.String()), slog.Int("failures", b.failures))
+	b.state = state
+	b.changes++
+}
@@ -0,0 +1,241 @@
+// Package musicinfo - клиент внешнего апи /info с деталями песни.
+// Каждая попытка ограничена таймаутом, 5xx и сетевые ошибки повторяются с backoff и jitter,
+// а circuit breaker перестает ходить в апи, пока тот отвечает ошибками
+package musicinfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrNotFound - апи не знает такую песню и ответил 404. 400 - ошибка запроса, это StatusError
+	ErrNotFound = errors.New("song not found")
+	// ErrCircuitOpen - breaker разомкнут, запрос в апи не отправлялся
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrInvalidResponse - апи ответил 200 с телом, которое не разбирается
+	ErrInvalidResponse = errors.New("invalid response")
+)
+
+// StatusError - неожиданный http статус ответа
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("external API status: %d", e.Code)
+}
+
+type Config struct {
+	BaseURL string
+	// Timeout ограничивает одну попытку, а не весь вызов с повторами
+	Timeout time.Duration
+	// Retries - сколько раз повторить запрос после первой неудачной попытки
+	Retries     int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// BreakerThreshold неудачных вызовов подряд размыкают breaker на BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultConfig - настройки для BaseURL, если не заданы свои
+func DefaultConfig(baseURL string) Config {
+	return Config{
+		BaseURL:          baseURL,
+		Timeout:          5 * time.Second,
+		Retries:          2,
+		BackoffBase:      200 * time.Millisecond,
+		BackoffMax:       2 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Details - детали песни из апи. Пустая ReleaseDate значит, что апи ее не знает
+type Details struct {
+	ReleaseDate time.Time
+	Text        string
+	Link        string
+}
+
+// Stats - счетчики клиента для метрик
+type Stats struct {
+	State        string `json:"state"`
+	Requests     int64  `json:"requests"`
+	Retries      int64  `json:"retries"`
+	Failures     int64  `json:"failures"`
+	Rejected     int64  `json:"rejected"`
+	StateChanges int64  `json:"state_changes"`
+}
+
+type Client struct {
+	cfg     Config
+	http    *http.Client
+	breaker *breaker
+
+	requests atomic.Int64
+	retries  atomic.Int64
+	failures atomic.Int64
+	rejected atomic.Int64
+}
+
+func New(cfg Config) *Client {
+	return &Client{
+		cfg:     cfg,
+		http:    &http.Client{},
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// Info возвращает детали песни. Пока breaker разомкнут, сразу отдает ErrCircuitOpen
+func (c *Client) Info(ctx context.Context, log *slog.Logger, group, song string) (Details, error) {
+	if !c.breaker.allow(log) {
+		c.rejected.Add(1)
+		return Details{}, ErrCircuitOpen
+	}
+
+	var (
+		details Details
+		err     error
+	)
+	for attempt := 0; ; attempt++ {
+		c.requests.Add(1)
+		details, err = c.fetch(ctx, log, group, song)
+		if err == nil || !retryable(err) || attempt >= c.cfg.Retries || ctx.Err() != nil {
+			break
+		}
+
+		delay := c.backoff(attempt)
+		c.retries.Add(1)
+		log.Warn("music info request failed, retrying",
+			slog.String("err", err.Error()), slog.Int("attempt", attempt+1), slog.Duration("delay", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	switch {
+	case err == nil || !failure(err):
+		c.breaker.record(log, outcomeSuccess)
+	case ctx.Err() != nil:
+		c.breaker.record(log, outcomeIgnored)
+		return Details{}, ctx.Err()
+	default:
+		c.failures.Add(1)
+		c.breaker.record(log, outcomeFailure)
+	}
+	return details, err
+}
+
+func (c *Client) Stats() Stats {
+	state, changes := c.breaker.snapshot()
+	return Stats{
+		State:        state.String(),
+		Requests:     c.requests.Load(),
+		Retries:      c.retries.Load(),
+		Failures:     c.failures.Load(),
+		Rejected:     c.rejected.Load(),
+		StateChanges: changes,
+	}
+}
+
+func (c *Client) State() State {
+	state, _ := c.breaker.snapshot()
+	return state
+}
+
+func (c *Client) fetch(ctx context.Context, log *slog.Logger, group, song string) (Details, error) {
+	if c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+
+	params := url.Values{}
+	params.Add("group", group)
+	params.Add("song", song)
+	apiURL := fmt.Sprintf("%s/info?%s", strings.TrimSuffix(c.cfg.BaseURL, "/"), params.Encode())
+	log.Debug("Request URL", slog.String("url", apiURL))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return Details{}, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Details{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return Details{}, ErrNotFound
+	default:
+		return Details{}, &StatusError{Code: resp.StatusCode}
+	}
+
+	var body struct {
+		ReleaseDate string `json:"releaseDate"`
+		Text        string `json:"text"`
+		Link        string `json:"link"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Details{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	details := Details{Text: body.Text, Link: body.Link}
+	if body.ReleaseDate != "" {
+		details.ReleaseDate, err = time.Parse("02.01.2006", body.ReleaseDate)
+		if err != nil {
+			return Details{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+		}
+	}
+	return details, nil
+}
+
+// backoff - экспоненциальная задержка перед повтором attempt со случайной половиной
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.cfg.BackoffBase << attempt
+	if delay <= 0 || (c.cfg.BackoffMax > 0 && delay > c.cfg.BackoffMax) {
+		delay = c.cfg.BackoffMax
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// failure - ошибка говорит о том, что апи нездоров. Неизвестная песня и 4xx - нормальные ответы
+func failure(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// retryable - ошибка может пройти при повторе: 5xx, сетевые ошибки и таймаут попытки
+func retryable(err error) bool {
+	return failure(err) && !errors.Is(err, ErrInvalidResponse)
+}
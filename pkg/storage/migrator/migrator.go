@@ -0,0 +1,273 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	lockID          = 1
+	lockTimeout     = 30 * time.Second
+	lockRetryPeriod = 500 * time.Millisecond
+)
+
+// имя файла миграции: 0001_create_songs.up.sql / 0001_create_songs.down.sql
+var fileNameRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrLocked = errors.New("migrations are locked by another process, remove the row from schema_migrations_lock if it is stale")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// Migrator применяет пронумерованные sql миграции и ведет их учет в таблице schema_migrations.
+// Параллельный запуск нескольких мигратов исключается блокировкой в schema_migrations_lock.
+type Migrator struct {
+	db         *gorm.DB
+	log        *slog.Logger
+	migrations []Migration
+}
+
+// New читает миграции из корня fsys
+func New(db *gorm.DB, log *slog.Logger, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		log:        log,
+		migrations: migrations,
+	}, nil
+}
+
+// Up применяет все еще не примененные миграции, возвращает их количество
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			m.log.Info("applying migration", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(mig.Up).Error; err != nil {
+					return err
+				}
+				return tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+					mig.Version, mig.Name, time.Now().UTC()).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down откатывает последние steps примененных миграций
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			m.log.Info("rolling back migration", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(mig.Down).Error; err != nil {
+					return err
+				}
+				return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", mig.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status возвращает все известные миграции с отметкой о применении
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.db.WithContext(ctx)
+	if err := ensureTables(db); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Migration: mig}
+		if a, ok := applied[mig.Version]; ok {
+			st.Applied = true
+			appliedAt := a.AppliedAt
+			st.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) applied(db *gorm.DB) (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := db.Raw("SELECT version, name, applied_at FROM schema_migrations").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(*gorm.DB) error) error {
+	db := m.db.WithContext(ctx)
+	if err := ensureTables(db); err != nil {
+		return err
+	}
+	if err := acquireLock(ctx, db); err != nil {
+		return err
+	}
+	defer func() {
+		// лок снимается даже если ctx уже отменен
+		if err := m.db.Exec("DELETE FROM schema_migrations_lock WHERE id = ?", lockID).Error; err != nil {
+			m.log.Error("unable to release migration lock", slog.String("err", err.Error()))
+		}
+	}()
+	return fn(db)
+}
+
+func ensureTables(db *gorm.DB) error {
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id INTEGER PRIMARY KEY,
+		locked_at TIMESTAMP NOT NULL
+	)`).Error
+}
+
+// acquireLock вставляет единственную строку в schema_migrations_lock,
+// пока строка есть - другие процессы ждут до lockTimeout
+func acquireLock(ctx context.Context, db *gorm.DB) error {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := db.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (?, ?)", lockID, time.Now().UTC()).Error
+		if err == nil {
+			return nil
+		}
+
+		var held int64
+		if cerr := db.Raw("SELECT COUNT(*) FROM schema_migrations_lock WHERE id = ?", lockID).Scan(&held).Error; cerr != nil {
+			return cerr
+		}
+		if held == 0 {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryPeriod):
+		}
+	}
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		parts := fileNameRe.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = mig
+		} else if mig.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d has different names: %q and %q", version, mig.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -1,9 +1,12 @@
 package postgresql
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -12,7 +15,7 @@ import (
 func Connect(log *slog.Logger) (*gorm.DB, error) {
 	dsn := newDSN()
 	log.Debug(dsn)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{NowFunc: NowFunc})
 	if err != nil {
 		return nil, err
 	}
@@ -23,6 +26,12 @@ func Connect(log *slog.Logger) (*gorm.DB, error) {
 	return db, nil
 }
 
+// NowFunc - время для autoCreateTime полей: UTC с точностью до микросекунд, как хранит timestamp,
+// чтобы значения из бд и из курсоров пагинации сравнивались без расхождений
+func NowFunc() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
 func Ping(db *gorm.DB) error {
 	dbSql, err := db.DB()
 	if err != nil {
@@ -34,33 +43,77 @@ func Ping(db *gorm.DB) error {
 	return nil
 }
 
-// обертка миграции данных
-func Migrate(db *gorm.DB, models ...any) error {
-	return db.AutoMigrate(models...)
+type txKey struct{}
+
+// ContextWithTx кладет транзакцию в контекст, ее подхватывают все вызовы TxSaveExecutor с этим контекстом
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext возвращает транзакцию, открытую RunInTx выше по стеку
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return tx, ok
 }
 
-func TxSaveExecutor(db *gorm.DB, fn func(*gorm.DB) error) error {
-	tx := db.Begin()
+// RunInTx - unit of work: открывает транзакцию, передает ее в fn через контекст и коммитит,
+// если fn вернул nil. При ошибке или панике транзакция откатывается, паника пробрасывается дальше.
+// Если в ctx уже есть транзакция, fn выполняется в ней, а коммитит ее внешний RunInTx.
+// Все запросы транзакции идут с ctx, поэтому отмена или дедлайн ctx прерывают их в бд
+func RunInTx(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) (err error) {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	dbSql, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := dbSql.PingContext(ctx); err != nil {
+		return canceled(ctx, err)
+	}
+	tx := db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return canceled(ctx, tx.Error)
+	}
+	committed := false
 	defer func() {
+		if committed {
+			return
+		}
 		if r := recover(); r != nil {
 			tx.Rollback()
+			panic(r)
 		}
-	}()
-	dbSql, err := db.DB()
-	if err != nil {
 		tx.Rollback()
-		return err
+	}()
+
+	if err := fn(ContextWithTx(ctx, tx)); err != nil {
+		return canceled(ctx, err)
 	}
-	if err = dbSql.Ping(); err != nil {
-		tx.Rollback()
-		return err
+	if err := tx.Commit().Error; err != nil {
+		return canceled(ctx, err)
 	}
-	if err = fn(db); err != nil {
-		tx.Rollback()
+	committed = true
+	return nil
+}
+
+// TxSaveExecutor выполняет fn в транзакции из ctx, а если ее нет - в своей собственной.
+// Запросы fn идут с ctx вызывающего, даже если транзакцию открыли с другим контекстом
+func TxSaveExecutor(ctx context.Context, db *gorm.DB, fn func(*gorm.DB) error) error {
+	return RunInTx(ctx, db, func(ctx context.Context) error {
+		tx, _ := TxFromContext(ctx)
+		return canceled(ctx, fn(tx.WithContext(ctx)))
+	})
+}
+
+// canceled добавляет к ошибке ctx.Err(): драйверы по-разному сообщают о прерванном запросе,
+// а вызывающим нужно отличать отмену и таймаут через errors.Is
+func canceled(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
 		return err
 	}
-	tx.Commit()
-	return nil
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
 }
 
 func newDSN() string {
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"online-song-library/pkg/storage/postgresql"
+	"os"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const defaultPath = "song-lib.db"
+
+// Connect открывает файловую бд sqlite по пути из SQLITE_PATH.
+// Драйвер написан на чистом go, поэтому cgo не требуется.
+func Connect(log *slog.Logger) (*gorm.DB, error) {
+	return Open(log, os.Getenv("SQLITE_PATH"))
+}
+
+// Open открывает бд sqlite по явному пути, удобно для тестов
+func Open(log *slog.Logger, path string) (*gorm.DB, error) {
+	dsn := newDSN(path)
+	log.Debug(dsn)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{NowFunc: postgresql.NowFunc})
+	if err != nil {
+		return nil, err
+	}
+	err = Ping(db)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func Ping(db *gorm.DB) error {
+	dbSql, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err = dbSql.Ping(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TxSaveExecutor - тот же unit of work, что и для postgres: транзакция в контексте общая для обоих драйверов
+func TxSaveExecutor(ctx context.Context, db *gorm.DB, fn func(*gorm.DB) error) error {
+	return postgresql.TxSaveExecutor(ctx, db, fn)
+}
+
+// busy_timeout нужен, чтобы параллельные запросы ждали блокировку файла, а не падали с SQLITE_BUSY
+func newDSN(path string) string {
+	if path == "" {
+		path = defaultPath
+	}
+	return fmt.Sprintf("%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+}
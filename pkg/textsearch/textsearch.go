@@ -0,0 +1,118 @@
+// Package textsearch - простой полнотекстовый поиск без бд.
+// Токенизация повторяет postgres конфигурацию 'simple': слова из букв и цифр в нижнем регистре.
+package textsearch
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	StartSel = "<mark>"
+	StopSel  = "</mark>"
+
+	verseSeparator = "\n\n"
+)
+
+// Terms разбивает поисковую строку на уникальные термы
+func Terms(query string) []string {
+	seen := make(map[string]struct{})
+	var terms []string
+	for _, t := range Tokenize(query) {
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+// Rank возвращает 0, если в тексте есть не все термы.
+// Иначе - доля совпавших слов с логарифмическим штрафом за длину текста, как normalization=1 у ts_rank.
+func Rank(text string, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	tokens := Tokenize(text)
+	counts := make(map[string]int, len(terms))
+	for _, t := range terms {
+		counts[t] = 0
+	}
+	hits := 0
+	for _, tok := range tokens {
+		if _, ok := counts[tok]; ok {
+			counts[tok]++
+			hits++
+		}
+	}
+	for _, c := range counts {
+		if c == 0 {
+			return 0
+		}
+	}
+	return float64(hits) / (1 + math.Log(float64(len(tokens))))
+}
+
+// Snippet выбирает куплет с наибольшим числом разных совпавших термов и подсвечивает их
+func Snippet(text string, terms []string) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	wanted := make(map[string]struct{}, len(terms))
+	for _, t := range terms {
+		wanted[t] = struct{}{}
+	}
+
+	best, bestDistinct, bestHits := "", 0, 0
+	for _, verse := range strings.Split(text, verseSeparator) {
+		distinct := make(map[string]struct{})
+		hits := 0
+		for _, tok := range Tokenize(verse) {
+			if _, ok := wanted[tok]; ok {
+				distinct[tok] = struct{}{}
+				hits++
+			}
+		}
+		if len(distinct) > bestDistinct || (len(distinct) == bestDistinct && hits > bestHits) {
+			best, bestDistinct, bestHits = verse, len(distinct), hits
+		}
+	}
+	if bestDistinct == 0 {
+		return ""
+	}
+	return highlight(best, wanted)
+}
+
+func highlight(verse string, wanted map[string]struct{}) string {
+	var b strings.Builder
+	runes := []rune(verse)
+	for i := 0; i < len(runes); {
+		if isSeparator(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && !isSeparator(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if _, ok := wanted[strings.ToLower(word)]; ok {
+			b.WriteString(StartSel + word + StopSel)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,43 @@
+package textsearch
+
+import "strings"
+
+// SimilarityThreshold совпадает с pg_trgm.similarity_threshold по умолчанию
+const SimilarityThreshold = 0.3
+
+// Similarity считает похожесть строк по триграммам так же, как similarity() из pg_trgm:
+// каждое слово дополняется двумя пробелами слева и одним справа, результат - доля общих триграмм.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range Tokenize(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// ContainsFold - регистронезависимый поиск подстроки
+func ContainsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// HasPrefixFold - регистронезависимая проверка префикса
+func HasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
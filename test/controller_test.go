@@ -2,16 +2,24 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"online-song-library/internal/apperr"
 	"online-song-library/internal/controller"
 	"online-song-library/internal/model"
+	"online-song-library/internal/repository"
 	"online-song-library/internal/router"
+	"online-song-library/internal/service"
 	external_api_test "online-song-library/test/external_api"
 	mocks "online-song-library/test/mock"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -78,14 +86,10 @@ func TestCreateSong(t *testing.T) {
 	router := router.SetupRouter(songController, mockLogger)
 	external_api_test.CreateMockExternalAPIServer(mockLogger)
 
-	// mock service settings
-	tmpTime, _ := time.Parse("02.01.2006", "16.07.2006")
-	mockService.On("CreateSong", mock.Anything, mock.Anything, mock.Anything).Return(uuid.New(), nil)
-	mockService.On("FetchSongDetailsFromAPI", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Song{
-		ReleaseDate: tmpTime,
-		Text:        "Ooh baby, don't you know I suffer?",
-		Link:        "https://www.youtube.com/watch?v=Xsp3_a-PMTw",
-	}, nil)
+	// mock service settings: детали из апи подтягивает воркер, песня сохраняется pending
+	mockService.On("CreateSong", mock.Anything, mock.Anything, mock.MatchedBy(func(s model.Song) bool {
+		return s.EnrichmentStatus == model.EnrichmentPending && s.Text == "" && s.Link == ""
+	}), (*uuid.UUID)(nil)).Return(uuid.New(), nil)
 
 	// create request and req body
 	song := model.Song{
@@ -102,16 +106,16 @@ func TestCreateSong(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	// test asserting
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusAccepted, w.Code)
 
-	response := struct {
-		SongID string `json:"song_id"`
-	}{}
+	var response model.SongAccepted
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 
-	_, err = uuid.Parse(response.SongID)
-	assert.NoError(t, err, "song_id is not a valid UUID")
+	assert.NotEqual(t, uuid.Nil, response.SongId)
+	assert.Equal(t, model.EnrichmentPending, response.EnrichmentStatus)
+	assert.Equal(t, "/songs/"+response.SongId.String(), w.Header().Get("Location"))
+	mockService.AssertNotCalled(t, "FetchSongDetailsFromAPI", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	// assert.Equal(t,  updatedSong.Group, song.Group)
 	// assert.Equal(t,updatedSong.Title, song.Title )
 	// assert.Equal(t, checkTime, song.ReleaseDate)
@@ -230,4 +234,700 @@ func TestGetSongVerses(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &returnedVerses)
 	assert.NoError(t, err)
 	assert.Equal(t, mockVerses, returnedVerses)
-}
\ No newline at end of file
+}
+
+func TestGetSongVerses_InvalidPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	// нулевой и отрицательный размер страницы - ошибка поля, а не паника в сервисе
+	songID := uuid.New()
+	for query, field := range map[string]string{
+		"page_size=0":  "page_size",
+		"page_size=-1": "page_size",
+		"page=0":       "page",
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/songs/"+songID.String()+"/verses?"+query, nil)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+
+		var problem model.Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		if assert.Len(t, problem.Errors, 1, query) {
+			assert.Equal(t, field, problem.Errors[0].Field, query)
+		}
+	}
+	mockService.AssertNotCalled(t, "GetSongVerses", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSearchSongs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	found := []model.SongSearchResult{
+		{
+			Song:    model.Song{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole"},
+			Rank:    0.1,
+			Snippet: "Ooh baby, don't you know I <mark>suffer</mark>?",
+		},
+	}
+	mockService.On("SearchSongs", mock.Anything, mock.Anything, "suffer", 10, 0).Return(found, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/songs/search?q=suffer", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var results []model.SongSearchResult
+	err = json.Unmarshal(w.Body.Bytes(), &results)
+	assert.NoError(t, err)
+	assert.Equal(t, found[0].Id, results[0].Id)
+	assert.Equal(t, found[0].Snippet, results[0].Snippet)
+
+	// пустой запрос
+	req, err = http.NewRequest(http.MethodGet, "/songs/search?q=", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetLibrary_MatchModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID := uuid.New()
+	group := "muse"
+	expectedFilter := model.SongFilter{Id: &songID, Group: &group, GroupMatch: model.MatchFuzzy}
+	mockService.On("GetLibrary", mock.Anything, mock.Anything, expectedFilter, 10, 0).Return([]model.Song{}, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/songs?id="+songID.String()+"&group=muse&group_match=fuzzy", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, err = http.NewRequest(http.MethodGet, "/songs?group=muse&group_match=regexp", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetLibrary_CursorMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	next := "next-token"
+	page := model.SongPage{Songs: []model.Song{{Id: uuid.New(), Group: "Muse"}}, NextCursor: &next}
+	mockService.On("GetLibraryPage", mock.Anything, mock.Anything, model.SongFilter{}, 1, "").Return(page, nil)
+	mockService.On("GetLibraryPage", mock.Anything, mock.Anything, model.SongFilter{}, 1, "broken").Return(model.SongPage{}, service.ErrInvalidCursor)
+
+	req, err := http.NewRequest(http.MethodGet, "/songs?cursor=&limit=1", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var returned model.SongPage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &returned))
+	assert.Equal(t, page.Songs[0].Id, returned.Songs[0].Id)
+	assert.Equal(t, next, *returned.NextCursor)
+	assert.Nil(t, returned.PrevCursor)
+
+	req, err = http.NewRequest(http.MethodGet, "/songs?cursor=broken&limit=1", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRestoreSong(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	restoredID, conflictID := uuid.New(), uuid.New()
+	mockService.On("RestoreSong", mock.Anything, mock.Anything, restoredID).Return(model.Song{Id: restoredID}, nil)
+	mockService.On("RestoreSong", mock.Anything, mock.Anything, conflictID).Return(model.Song{}, repository.ErrDuplicateLink)
+
+	req, err := http.NewRequest(http.MethodPost, "/songs/"+restoredID.String()+"/restore", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, err = http.NewRequest(http.MethodPost, "/songs/"+conflictID.String()+"/restore", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestRevertSong(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID := uuid.New()
+	mockService.On("RevertSong", mock.Anything, mock.Anything, songID, 1).Return(model.Song{Id: songID, Title: "Uprising"}, nil)
+	mockService.On("RevertSong", mock.Anything, mock.Anything, songID, 7).Return(model.Song{}, repository.ErrNotFound)
+
+	req, err := http.NewRequest(http.MethodPost, "/songs/"+songID.String()+"/revisions/1/revert", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, err = http.NewRequest(http.MethodPost, "/songs/"+songID.String()+"/revisions/7/revert", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, err = http.NewRequest(http.MethodPost, "/songs/"+songID.String()+"/revisions/zero/revert", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateArtist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	mockService.On("CreateArtist", mock.Anything, mock.Anything, mock.MatchedBy(func(a model.Artist) bool { return a.Name == "Muse" })).
+		Return(model.Artist{Id: uuid.New(), Name: "Muse"}, nil)
+	mockService.On("CreateArtist", mock.Anything, mock.Anything, mock.MatchedBy(func(a model.Artist) bool { return a.Name == "Enigma" })).
+		Return(model.Artist{}, repository.ErrDuplicateArtist)
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"name": " Muse "}`, http.StatusOK},
+		{`{"name": "Enigma"}`, http.StatusConflict},
+		{`{"name": "  "}`, http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(http.MethodPost, "/artists", bytes.NewBufferString(tc.body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.body)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateSong_AlbumReleaseDate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	// дата альбома сохраняется сразу, дата из внешнего апи перекроет ее при обогащении
+	albumDate, _ := time.Parse("02.01.2006", "03.07.2006")
+	album := model.AlbumDetails{Album: model.Album{Id: uuid.New(), Title: "Black Holes and Revelations", ReleaseDate: albumDate}}
+	songID := uuid.New()
+	mockService.On("GetAlbum", mock.Anything, mock.Anything, album.Id).Return(album, nil)
+	mockService.On("CreateSong", mock.Anything, mock.Anything, mock.MatchedBy(func(s model.Song) bool { return s.ReleaseDate.Equal(albumDate) }), &album.Id).Return(songID, nil)
+
+	body, _ := json.Marshal(model.SongDTO{Group: "Muse", Title: "Starlight", AlbumId: &album.Id})
+	req, err := http.NewRequest(http.MethodPost, "/songs", bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	unknown := uuid.New()
+	mockService.On("GetAlbum", mock.Anything, mock.Anything, unknown).Return(model.AlbumDetails{}, repository.ErrNotFound)
+	body, _ = json.Marshal(model.SongDTO{Group: "Muse", Title: "Starlight", AlbumId: &unknown})
+	req, err = http.NewRequest(http.MethodPost, "/songs", bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestSongLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID := uuid.New()
+	tagged := model.Song{Id: songID, Group: "Muse", Tags: []string{"live"}, Genres: []string{}}
+	mockService.On("AddSongLabels", mock.Anything, mock.Anything, songID, model.LabelTag, []string{"live"}).Return(tagged, nil)
+	mockService.On("RemoveSongLabel", mock.Anything, mock.Anything, songID, model.LabelGenre, "rock").Return(model.Song{}, repository.ErrNotFound)
+	expectedFilter := model.SongFilter{Genres: []string{"rock", "pop"}, GenreMode: model.LabelModeOr}
+	mockService.On("GetLibrary", mock.Anything, mock.Anything, expectedFilter, 10, 0).Return([]model.Song{}, nil)
+
+	req, err := http.NewRequest(http.MethodPost, "/songs/"+songID.String()+"/tags", bytes.NewBufferString(`{"names":["live"]}`))
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var returned model.Song
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &returned))
+	assert.Equal(t, []string{"live"}, returned.Tags)
+
+	req, err = http.NewRequest(http.MethodPost, "/songs/"+songID.String()+"/genres", bytes.NewBufferString(`{"names":[]}`))
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, err = http.NewRequest(http.MethodDelete, "/songs/"+songID.String()+"/genres/rock", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, err = http.NewRequest(http.MethodGet, "/songs?genre=rock&genre=pop&genre_mode=or", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, err = http.NewRequest(http.MethodGet, "/songs?tag=live&tag_mode=xor", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPlaylistSongs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	playlistID := uuid.New()
+	songID := uuid.New()
+	details := model.PlaylistDetails{
+		Playlist: model.Playlist{Id: playlistID, Name: "Road trip"},
+		Songs:    []model.Track{{Position: 1, Song: model.Song{Id: songID, Group: "Muse", Title: "Uprising"}}},
+	}
+	mockService.On("AddPlaylistSong", mock.Anything, mock.Anything, playlistID, songID, 0).Return(details, nil)
+	mockService.On("MovePlaylistSong", mock.Anything, mock.Anything, playlistID, 1, 3).Return(model.PlaylistDetails{}, repository.ErrInvalidPosition)
+	mockService.On("RemovePlaylistSong", mock.Anything, mock.Anything, playlistID, 2).Return(model.PlaylistDetails{}, repository.ErrNotFound)
+
+	req, err := http.NewRequest(http.MethodPost, "/playlists/"+playlistID.String()+"/songs", bytes.NewBufferString(`{"song_id":"`+songID.String()+`"}`))
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var returned model.PlaylistDetails
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &returned))
+	assert.Equal(t, "Uprising", returned.Songs[0].Title)
+
+	req, err = http.NewRequest(http.MethodPost, "/playlists/"+playlistID.String()+"/songs/1/move", bytes.NewBufferString(`{"position":3}`))
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, err = http.NewRequest(http.MethodDelete, "/playlists/"+playlistID.String()+"/songs/2", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, err = http.NewRequest(http.MethodDelete, "/playlists/"+playlistID.String()+"/songs/last", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetSong_Fields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID := uuid.New()
+	song := model.Song{Id: songID, Title: "Uprising", Link: "https://example.com/uprising", Version: 2}
+	mockService.On("GetSong", mock.Anything, mock.Anything, songID, model.FieldSet{"song", "link"}).Return(song, nil)
+	mockService.On("GetLibrary", mock.Anything, mock.Anything, model.SongFilter{Fields: model.FieldSet{"group"}}, 10, 0).
+		Return([]model.Song{{Id: songID, Group: "Muse"}}, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/songs/"+songID.String()+"?fields=song,link", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{"id": songID.String(), "song": "Uprising", "link": "https://example.com/uprising"}, body)
+
+	req, err = http.NewRequest(http.MethodGet, "/songs?fields=group", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"id":"`+songID.String()+`","group":"Muse"}]`, w.Body.String())
+
+	req, err = http.NewRequest(http.MethodGet, "/songs/"+songID.String()+"?fields=lyrics", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "fields")
+}
+
+func TestUpdateSong_IfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID := uuid.New()
+	mockService.On("GetSong", mock.Anything, mock.Anything, songID, model.FieldSet(nil)).Return(model.Song{Id: songID, Title: "Uprising", Version: 3}, nil)
+	mockService.On("UpdateSong", mock.Anything, mock.Anything, model.Song{Id: songID, Title: "Starlight", Version: 3}).
+		Return(model.Song{Id: songID, Title: "Starlight", Version: 4}, nil)
+	mockService.On("UpdateSong", mock.Anything, mock.Anything, model.Song{Id: songID, Title: "Starlight", Version: 2}).
+		Return(model.Song{}, repository.ErrVersionConflict)
+
+	req, err := http.NewRequest(http.MethodGet, "/songs/"+songID.String(), nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	// версия из тела игнорируется, важен только If-Match
+	req, err = http.NewRequest(http.MethodPut, "/songs/"+songID.String(), bytes.NewBufferString(`{"song":"Starlight","version":7}`))
+	assert.NoError(t, err)
+	req.Header.Set("If-Match", `"3"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+
+	req, err = http.NewRequest(http.MethodPut, "/songs/"+songID.String(), bytes.NewBufferString(`{"song":"Starlight"}`))
+	assert.NoError(t, err)
+	req.Header.Set("If-Match", `"2"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	req, err = http.NewRequest(http.MethodPut, "/songs/"+songID.String(), bytes.NewBufferString(`{"song":"Starlight"}`))
+	assert.NoError(t, err)
+	req.Header.Set("If-Match", `W/"3"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestGetLibrary_CanceledQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	mockService.On("GetLibrary", mock.Anything, mock.Anything, model.SongFilter{}, 10, 0).
+		Return([]model.Song{}, fmt.Errorf("%w: interrupted", context.DeadlineExceeded)).Once()
+	mockService.On("GetLibrary", mock.Anything, mock.Anything, model.SongFilter{}, 10, 0).
+		Return([]model.Song{}, context.Canceled).Once()
+
+	req, err := http.NewRequest(http.MethodGet, "/songs", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, controller.StatusClientClosedRequest, w.Code)
+}
+
+func TestErrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID := uuid.New()
+	mockService.On("GetSongVerses", mock.Anything, mock.Anything, songID, 5, 1).Return([]string(nil), service.ErrInvalidPage)
+	artistID := uuid.New()
+	mockService.On("DeleteArtist", mock.Anything, mock.Anything, artistID).Return(repository.ErrArtistHasSongs)
+	mockService.On("GetSong", mock.Anything, mock.Anything, songID, model.FieldSet(nil)).Return(model.Song{}, errors.New("connection reset"))
+
+	// ошибка валидации из сервиса - 400 в формате problem+json, id запроса клиента сохраняется
+	req, _ := http.NewRequest(http.MethodGet, "/songs/"+songID.String()+"/verses?page=5&page_size=1", nil)
+	req.Header.Set(controller.RequestIdHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, controller.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "req-1", w.Header().Get(controller.RequestIdHeader))
+	var problem model.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, model.Problem{
+		Type:      "/problems/validation",
+		Title:     "Bad Request",
+		Status:    http.StatusBadRequest,
+		Detail:    "Invalid page number",
+		Instance:  "/songs/" + songID.String() + "/verses",
+		RequestId: "req-1",
+	}, problem)
+
+	// ошибки полей
+	req, _ = http.NewRequest(http.MethodGet, "/songs?limit=x&group_match=bad&title_match=bad", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	problem = model.Problem{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, []apperr.FieldError{
+		{Field: "group_match", Message: "unknown match mode"},
+		{Field: "title_match", Message: "unknown match mode"},
+	}, problem.Errors)
+	assert.NotEmpty(t, problem.RequestId)
+
+	// конфликт - 409, старый формат по Accept: application/json
+	req, _ = http.NewRequest(http.MethodDelete, "/artists/"+artistID.String(), nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error": "Artist has songs"}`, w.Body.String())
+
+	// причина ошибки без доменного типа клиенту не отдается
+	req, _ = http.NewRequest(http.MethodGet, "/songs/"+songID.String(), nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error": "Failed to get song"}`, w.Body.String())
+
+	// неизвестный путь
+	req, _ = http.NewRequest(http.MethodGet, "/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, controller.ProblemContentType, w.Header().Get("Content-Type"))
+}
+
+func TestSongValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	send := func(method, url string, body any) model.Problem {
+		raw, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(raw))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var problem model.Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		return problem
+	}
+
+	// создание: ошибки по всем полям, внешний апи не вызывается
+	problem := send(http.MethodPost, "/songs", map[string]any{
+		"song": strings.Repeat("a", 1001),
+		"tags": []string{"live", " "},
+	})
+	assert.Equal(t, []apperr.FieldError{
+		{Field: "group", Message: "is required"},
+		{Field: "song", Message: "must be at most 1000 characters"},
+		{Field: "tags[1]", Message: "must not be blank"},
+	}, problem.Errors)
+
+	// изменение: ссылка и дата из будущего, до бд запрос не доходит
+	problem = send(http.MethodPut, "/songs/"+uuid.NewString(), map[string]any{
+		"group":        " ",
+		"link":         "not a link",
+		"release_date": time.Now().AddDate(1, 0, 0),
+	})
+	assert.Equal(t, []apperr.FieldError{
+		{Field: "group", Message: "must not be blank"},
+		{Field: "release_date", Message: "must be between 1860-01-01 and now"},
+		{Field: "link", Message: "must be a valid http(s) URL"},
+	}, problem.Errors)
+
+	mockService.AssertNotCalled(t, "FetchSongDetailsFromAPI", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockService.AssertNotCalled(t, "UpdateSong", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchSong(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID := uuid.New()
+	patch := model.SongPatch{Format: model.PatchMerge, Body: []byte(`{"text": null}`)}
+	changes := []model.FieldChange{{Field: "text", Old: "verse", New: ""}}
+	mockService.On("PatchSong", mock.Anything, mock.Anything, songID, 3, patch).
+		Return(model.SongPatchResult{Song: model.Song{Id: songID, Version: 4}, Changes: changes}, nil)
+
+	req, _ := http.NewRequest(http.MethodPatch, "/songs/"+songID.String(), bytes.NewBufferString(`{"text": null}`))
+	req.Header.Set("Content-Type", controller.MergePatchContentType)
+	req.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+
+	var result struct {
+		Changes []model.FieldChange `json:"changes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, changes, result.Changes)
+
+	// обычный json не считается патчем
+	req, _ = http.NewRequest(http.MethodPatch, "/songs/"+songID.String(), bytes.NewBufferString(`{"text": null}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Header().Get("Accept-Patch"), controller.JSONPatchContentType)
+	mockService.AssertNumberOfCalls(t, "PatchSong", 1)
+}
+
+func TestEnrichSong(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	songID, downID := uuid.New(), uuid.New()
+	mockService.On("ReenrichSong", mock.Anything, mock.Anything, songID, true).Return(model.Song{Id: songID, Version: 3}, nil)
+	mockService.On("ReenrichSong", mock.Anything, mock.Anything, downID, false).Return(model.Song{}, service.ErrUpstream.Wrap(errors.New("timeout")))
+
+	req, err := http.NewRequest(http.MethodPost, "/songs/"+songID.String()+"/enrich?force=true", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	req, err = http.NewRequest(http.MethodPost, "/songs/"+downID.String()+"/enrich", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	req, err = http.NewRequest(http.MethodPost, "/songs/"+songID.String()+"/enrich?force=maybe", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNumberOfCalls(t, "ReenrichSong", 2)
+}
+
+func TestEnrichSongs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	group := "Muse"
+	failed := model.EnrichmentFailed
+	mockService.On("QueueEnrichment", mock.Anything, mock.Anything, model.EnrichmentRequest{
+		Filter: model.SongFilter{Group: &group, Enrichment: &failed},
+		Force:  true,
+	}).Return(int64(4), nil)
+
+	req, err := http.NewRequest(http.MethodPost, "/songs/enrich?group=Muse&enrichment_status=failed&force=1", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"queued":4}`, w.Body.String())
+
+	req, err = http.NewRequest(http.MethodPost, "/songs/enrich?group=Mus&group_match=fuzzy", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNumberOfCalls(t, "QueueEnrichment", 1)
+}
+
+func TestDebugVars(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockSongService)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	songController := controller.NewSongController(mockService, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	// наружу отдается только статистика обогащения, без cmdline и memstats
+	expvar.NewInt("enrichment_cache").Set(3)
+	req, err := http.NewRequest(http.MethodGet, "/debug/vars", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var vars map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	assert.Equal(t, map[string]json.RawMessage{"enrichment_cache": json.RawMessage("3")}, vars)
+}
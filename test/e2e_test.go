@@ -0,0 +1,142 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"online-song-library/internal/controller"
+	"online-song-library/internal/enrichment"
+	"online-song-library/internal/model"
+	"online-song-library/internal/repository"
+	"online-song-library/internal/router"
+	"online-song-library/internal/service"
+	"online-song-library/internal/worker"
+	"online-song-library/pkg/musicinfo"
+	external_api_test "online-song-library/test/external_api"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestE2E_InMemory прогоняет весь api поверх in-memory хранилища без postgres
+func TestE2E_InMemory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	externalAPI := external_api_test.CreateMockExternalAPIServer(mockLogger)
+	defer externalAPI.Close()
+
+	repo := repository.NewMemorySongRepository()
+	info := musicinfo.New(musicinfo.DefaultConfig(externalAPI.URL))
+	serv := service.NewSongService(repo, enrichment.NewChain(enrichment.NewMusicInfoProvider(info)))
+	songController := controller.NewSongController(serv, mockLogger)
+	router := router.SetupRouter(songController, mockLogger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	enricherDone := make(chan struct{})
+	go func() {
+		defer close(enricherDone)
+		worker.NewEnrichmentWorker(serv, mockLogger, worker.EnrichmentConfig{
+			Workers: 2, Interval: time.Hour, MaxAttempts: 3, BackoffBase: time.Millisecond, BackoffMax: time.Millisecond,
+		}).Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-enricherDone
+	}()
+
+	// create: песня сохраняется сразу, детали подтягивает воркер
+	body, _ := json.Marshal(model.SongDTO{Group: "Enigma", Title: "Sadeness"})
+	req, _ := http.NewRequest(http.MethodPost, "/songs", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	response := struct {
+		SongID string `json:"song_id"`
+	}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	song := waitEnrichment(t, router, response.SongID)
+	assert.Equal(t, model.EnrichmentDone, song.EnrichmentStatus)
+	assert.Equal(t, "https://www.youtube.com/watch?v=4F9DxYhqmKw&ab_channel=EnigmaVEVO", song.Link)
+
+	// песню, которую апи не знает, воркер не повторяет
+	body, _ = json.Marshal(model.SongDTO{Group: "Nobody", Title: "Unknown"})
+	req, _ = http.NewRequest(http.MethodPost, "/songs", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var unknown struct {
+		SongID string `json:"song_id"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &unknown))
+	song = waitEnrichment(t, router, unknown.SongID)
+	assert.Equal(t, model.EnrichmentFailed, song.EnrichmentStatus)
+	assert.Equal(t, 1, song.EnrichmentAttempts)
+
+	// list
+	req, _ = http.NewRequest(http.MethodGet, "/songs?enrichment_status=done", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var songs []model.Song
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &songs))
+	require.Len(t, songs, 1)
+	assert.Equal(t, response.SongID, songs[0].Id.String())
+
+	// artist created from group
+	req, _ = http.NewRequest(http.MethodGet, "/artists/"+songs[0].ArtistId.String()+"/songs", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var artistSongs []model.Song
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &artistSongs))
+	assert.Len(t, artistSongs, 1)
+	assert.Equal(t, "Enigma", artistSongs[0].Group)
+
+	// verses
+	req, _ = http.NewRequest(http.MethodGet, "/songs/"+response.SongID+"/verses?page=1&page_size=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var verses []string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &verses))
+	assert.Equal(t, "Procedamus in pace", verses[0])
+
+	// delete
+	req, _ = http.NewRequest(http.MethodDelete, "/songs/"+response.SongID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/songs/"+response.SongID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// waitEnrichment ждет, пока воркер закончит с песней, и возвращает ее
+func waitEnrichment(t *testing.T, router http.Handler, songID string) model.Song {
+	var song model.Song
+	require.Eventually(t, func() bool {
+		req, _ := http.NewRequest(http.MethodGet, "/songs/"+songID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		song = model.Song{}
+		return w.Code == http.StatusOK && json.Unmarshal(w.Body.Bytes(), &song) == nil &&
+			song.EnrichmentStatus != model.EnrichmentPending
+	}, 5*time.Second, 10*time.Millisecond)
+	return song
+}
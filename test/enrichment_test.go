@@ -0,0 +1,239 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/enrichment"
+	"online-song-library/pkg/musicinfo"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichmentChain_MergesProviders(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	down, _ := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	cfg := testMusicInfoConfig(down.URL)
+	cfg.Retries = 0
+
+	catalogPath := filepath.Join(t.TempDir(), "catalog.csv")
+	require.NoError(t, os.WriteFile(catalogPath, []byte("song,group,text\n"+
+		"Uprising,Muse,Paranoia is in bloom\n"+
+		"Sadeness,Enigma,\n"), 0o600))
+	catalog, err := enrichment.NewCatalogProvider(catalogPath)
+	require.NoError(t, err)
+
+	tracks, _ := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tracks" || r.URL.Query().Get("artist") != "Muse" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"track":{"released":"2009-09-07","lyrics":"The paranoia is in bloom","links":[{"url":"https://example.com/uprising"}]}}`))
+	})
+
+	chain := enrichment.NewChain(
+		enrichment.NewMusicInfoProvider(musicinfo.New(cfg)),
+		catalog,
+		enrichment.NewTracksProvider(tracks.URL, time.Second),
+	)
+
+	// текст из каталога важнее текста tracks_api, недоступный music_info не мешает остальным
+	result, err := chain.Enrich(ctx, mockLogger, "Muse", " UPRISING ")
+	require.NoError(t, err)
+	assert.Equal(t, "Paranoia is in bloom", result.Text)
+	assert.Equal(t, "https://example.com/uprising", result.Link)
+	assert.Equal(t, time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC), result.ReleaseDate)
+	assert.Equal(t, map[string]string{"text": "catalog", "link": "tracks_api", "release_date": "tracks_api"}, result.Sources)
+
+	// никто не нашел песню, но music_info ответил ошибкой: это не повод считать песню неизвестной
+	_, err = chain.Enrich(ctx, mockLogger, "Enigma", "Sadeness")
+	var providerErr *enrichment.ProviderError
+	require.True(t, errors.As(err, &providerErr))
+	assert.Equal(t, "music_info", providerErr.Provider)
+	assert.NotErrorIs(t, err, enrichment.ErrNotFound)
+
+	_, err = enrichment.NewChain(catalog).Enrich(ctx, mockLogger, "Enigma", "Sadeness")
+	assert.ErrorIs(t, err, enrichment.ErrNotFound)
+}
+
+func TestCatalogProvider_JSON(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "catalog.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"group":"Muse","song":"Hysteria","release_date":"2003-12-01","link":"https://example.com/hysteria"}]`), 0o600))
+	catalog, err := enrichment.NewCatalogProvider(path)
+	require.NoError(t, err)
+
+	details, err := catalog.Lookup(context.Background(), mockLogger, "Muse", "Hysteria")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2003, 12, 1, 0, 0, 0, 0, time.UTC), details.ReleaseDate)
+	assert.Equal(t, "https://example.com/hysteria", details.Link)
+	assert.Empty(t, details.Text)
+
+	_, err = catalog.Lookup(context.Background(), mockLogger, "Muse", "Uprising")
+	assert.ErrorIs(t, err, enrichment.ErrNotFound)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`[{"group":"Muse","song":"Hysteria","release_date":"01.12.2003"}]`), 0o600))
+	_, err = enrichment.NewCatalogProvider(invalid)
+	assert.Error(t, err)
+	_, err = enrichment.NewCatalogProvider(filepath.Join(dir, "catalog.yaml"))
+	assert.Error(t, err)
+}
+
+func TestCachedProvider(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+	var healthy atomic.Bool
+	healthy.Store(true)
+	server, calls := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case !healthy.Load():
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.URL.Query().Get("song") != "Uprising":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte(`{"releaseDate":"14.09.2009","text":"Paranoia is in bloom"}`))
+		}
+	})
+	cfg := testMusicInfoConfig(server.URL)
+	cfg.Retries = 0
+	cfg.BreakerThreshold = 0
+	provider := enrichment.NewCachedProvider(enrichment.NewMusicInfoProvider(musicinfo.New(cfg)),
+		enrichment.NewLRUCache(10), time.Hour, 30*time.Millisecond)
+
+	// ключ не зависит от регистра и лишних пробелов
+	for _, title := range []string{"Uprising", " uprising", "UPRISING "} {
+		details, err := provider.Lookup(ctx, mockLogger, "Muse", title)
+		require.NoError(t, err)
+		assert.Equal(t, "Paranoia is in bloom", details.Text)
+	}
+	assert.EqualValues(t, 1, calls.Load())
+
+	// «не найдено» тоже кэшируется, но на свой короткий срок
+	for i := 0; i < 2; i++ {
+		_, err := provider.Lookup(ctx, mockLogger, "Muse", "Unknown")
+		assert.ErrorIs(t, err, enrichment.ErrNotFound)
+	}
+	assert.EqualValues(t, 2, calls.Load())
+	time.Sleep(30 * time.Millisecond)
+	_, err := provider.Lookup(ctx, mockLogger, "Muse", "Unknown")
+	assert.ErrorIs(t, err, enrichment.ErrNotFound)
+	assert.EqualValues(t, 3, calls.Load())
+
+	// ошибки апи не кэшируются
+	healthy.Store(false)
+	for i := 0; i < 2; i++ {
+		_, err := provider.Lookup(ctx, mockLogger, "Muse", "Hysteria")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, enrichment.ErrNotFound)
+	}
+	assert.EqualValues(t, 5, calls.Load())
+
+	assert.Equal(t, enrichment.CacheStats{Hits: 2, NegativeHits: 1, Misses: 5}, provider.Stats())
+}
+
+func TestCachedProvider_CallerCancel(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	server, calls := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		w.Write([]byte(`{"releaseDate":"14.09.2009","text":"Paranoia is in bloom"}`))
+	})
+	provider := enrichment.NewCachedProvider(enrichment.NewMusicInfoProvider(musicinfo.New(testMusicInfoConfig(server.URL))),
+		enrichment.NewLRUCache(10), time.Hour, time.Hour)
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := provider.Lookup(first, mockLogger, "Muse", "Uprising")
+		firstErr <- err
+	}()
+	<-started
+	secondErr := make(chan error, 1)
+	go func() {
+		details, err := provider.Lookup(context.Background(), mockLogger, "Muse", "Uprising")
+		if err == nil && details.Text != "Paranoia is in bloom" {
+			err = errors.New("unexpected details")
+		}
+		secondErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// отмена первого вызывающего не отменяет общий запрос для второго
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	close(release)
+	assert.NoError(t, <-secondErr)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestLRUCache_Evicts(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+	cache := enrichment.NewLRUCache(2)
+	expires := time.Now().Add(time.Hour)
+
+	require.NoError(t, cache.Set(ctx, mockLogger, "a", enrichment.CacheEntry{ExpiresAt: expires}))
+	require.NoError(t, cache.Set(ctx, mockLogger, "b", enrichment.CacheEntry{ExpiresAt: expires}))
+	// чтение делает a свежее b, поэтому вытесняется b
+	_, ok, _ := cache.Get(ctx, mockLogger, "a")
+	assert.True(t, ok)
+	require.NoError(t, cache.Set(ctx, mockLogger, "c", enrichment.CacheEntry{ExpiresAt: expires}))
+
+	_, ok, _ = cache.Get(ctx, mockLogger, "b")
+	assert.False(t, ok)
+	_, ok, _ = cache.Get(ctx, mockLogger, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestDBCache_Tiered(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+	db := newSQLiteDB(t, mockLogger)
+
+	releaseDate := time.Date(2009, 9, 14, 0, 0, 0, 0, time.UTC)
+	entry := enrichment.CacheEntry{
+		Details:   enrichment.Details{ReleaseDate: releaseDate, Text: "Paranoia is in bloom"},
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}
+	require.NoError(t, enrichment.NewTieredCache(enrichment.NewLRUCache(10), enrichment.NewDBCache(db)).
+		Set(ctx, mockLogger, "music_info:muse\nuprising", entry))
+	require.NoError(t, enrichment.NewDBCache(db).Set(ctx, mockLogger, "music_info:muse\nunknown",
+		enrichment.CacheEntry{NotFound: true, ExpiresAt: time.Now().UTC().Add(-time.Second)}))
+
+	// после перезапуска LRU пуст, запись берется из таблицы и копируется в LRU
+	lru := enrichment.NewLRUCache(10)
+	cache := enrichment.NewTieredCache(lru, enrichment.NewDBCache(db))
+	cached, ok, err := cache.Get(ctx, mockLogger, "music_info:muse\nuprising")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Paranoia is in bloom", cached.Text)
+	assert.True(t, releaseDate.Equal(cached.ReleaseDate))
+	assert.Equal(t, 1, lru.Len())
+
+	// просроченная запись - промах, и из таблицы она удаляется
+	_, ok, err = cache.Get(ctx, mockLogger, "music_info:muse\nunknown")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	var rows int64
+	require.NoError(t, db.Table("enrichment_cache").Count(&rows).Error)
+	assert.EqualValues(t, 1, rows)
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"online-song-library/internal/enrichment"
+	"online-song-library/internal/model"
+	"online-song-library/internal/repository"
+	"online-song-library/internal/service"
+	"online-song-library/internal/worker"
+	"online-song-library/pkg/musicinfo"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichmentWorker_Retry(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var failures atomic.Int64
+	failures.Store(2)
+	server, _ := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("group") == "Down" || failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"releaseDate":"14.09.2009","text":"Paranoia is in bloom","link":"https://example.com/uprising"}`))
+	})
+	cfg := testMusicInfoConfig(server.URL)
+	cfg.Retries = 0
+	cfg.BreakerThreshold = 0
+
+	repo := repository.NewMemorySongRepository()
+	serv := service.NewSongService(repo, enrichment.NewChain(enrichment.NewMusicInfoProvider(musicinfo.New(cfg))))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.NewEnrichmentWorker(serv, mockLogger, worker.EnrichmentConfig{
+		Workers: 2, Interval: 5 * time.Millisecond, MaxAttempts: 3, BackoffBase: time.Millisecond, BackoffMax: 10 * time.Millisecond,
+	}).Run(ctx)
+
+	songs := []model.Song{
+		{Id: uuid.New(), Group: "Muse", Title: "Uprising", EnrichmentStatus: model.EnrichmentPending},
+		{Id: uuid.New(), Group: "Down", Title: "Stone the Crow", EnrichmentStatus: model.EnrichmentPending},
+	}
+	for _, song := range songs {
+		_, err := serv.CreateSong(ctx, mockLogger, song, nil)
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		pending, err := repo.GetPendingEnrichment(ctx, mockLogger, time.Now().Add(time.Hour), 10)
+		return err == nil && len(pending) == 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// после двух 503 третья попытка успешна
+	enriched, err := repo.Get(ctx, mockLogger, songs[0].Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.EnrichmentDone, enriched.EnrichmentStatus)
+	assert.Equal(t, 3, enriched.EnrichmentAttempts)
+	assert.Equal(t, "Paranoia is in bloom", enriched.Text)
+	assert.Equal(t, time.Date(2009, 9, 14, 0, 0, 0, 0, time.UTC), enriched.ReleaseDate)
+
+	// апи недоступен все MaxAttempts попыток
+	failed, err := repo.Get(ctx, mockLogger, songs[1].Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.EnrichmentFailed, failed.EnrichmentStatus)
+	assert.Equal(t, 3, failed.EnrichmentAttempts)
+	assert.Empty(t, failed.Text)
+}
+
+func TestEnrichmentWorker_DrainsOnStop(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	started := make(chan struct{}, 1)
+	server, _ := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"releaseDate":"14.09.2009","text":"Paranoia is in bloom","link":"https://example.com/uprising"}`))
+	})
+	repo := repository.NewMemorySongRepository()
+	serv := service.NewSongService(repo, enrichment.NewChain(enrichment.NewMusicInfoProvider(musicinfo.New(testMusicInfoConfig(server.URL)))))
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", EnrichmentStatus: model.EnrichmentPending}
+	_, err := serv.CreateSong(context.Background(), mockLogger, song, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.NewEnrichmentWorker(serv, mockLogger, worker.EnrichmentConfig{
+			Workers: 1, Interval: time.Hour, MaxAttempts: 1, DrainTimeout: 5 * time.Second,
+		}).Run(ctx)
+	}()
+
+	// остановка посреди запроса к апи не отменяет начатую попытку
+	<-started
+	cancel()
+	<-done
+
+	enriched, err := repo.Get(context.Background(), mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.EnrichmentDone, enriched.EnrichmentStatus)
+	assert.Equal(t, "Paranoia is in bloom", enriched.Text)
+}
+
+func TestEnrichmentRefresher_KeepsManualFields(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var releases atomic.Int64
+	server, _ := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// каждый запрос отдает новую дату выхода, чтобы было видно, что песня обогатилась заново
+		day := releases.Add(1)
+		w.Write([]byte(fmt.Sprintf(`{"releaseDate":"%02d.09.2009","text":"Paranoia is in bloom","link":"https://example.com/uprising"}`, day)))
+	})
+	repo := repository.NewMemorySongRepository()
+	serv := service.NewSongService(repo, enrichment.NewChain(enrichment.NewMusicInfoProvider(musicinfo.New(testMusicInfoConfig(server.URL)))))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", EnrichmentStatus: model.EnrichmentPending}
+	_, err := serv.CreateSong(ctx, mockLogger, song, nil)
+	require.NoError(t, err)
+	song, err = serv.ReenrichSong(ctx, mockLogger, song.Id, false)
+	require.NoError(t, err)
+	require.NotNil(t, song.EnrichedAt)
+
+	_, err = serv.UpdateSong(ctx, mockLogger, model.Song{Id: song.Id, Text: "Rise up and take the power back"})
+	require.NoError(t, err)
+
+	go worker.NewEnrichmentWorker(serv, mockLogger, worker.EnrichmentConfig{
+		Workers: 1, Interval: 5 * time.Millisecond, MaxAttempts: 1,
+	}).Run(ctx)
+	go worker.NewEnrichmentRefresher(serv, mockLogger, time.Hour, 0).Run(ctx)
+
+	// обновление берет новую дату, но не трогает исправленный руками текст
+	require.Eventually(t, func() bool {
+		refreshed, err := repo.Get(ctx, mockLogger, song.Id)
+		return err == nil && refreshed.EnrichmentStatus == model.EnrichmentDone && releases.Load() == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	refreshed, err := repo.Get(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2009, 9, 2, 0, 0, 0, 0, time.UTC), refreshed.ReleaseDate)
+	assert.Equal(t, "Rise up and take the power back", refreshed.Text)
+	assert.Equal(t, model.FieldList{"text"}, refreshed.ManualFields)
+	assert.Equal(t, model.FieldSources{"release_date": "music_info", "link": "music_info"}, refreshed.EnrichmentSources)
+
+	forced, err := serv.ReenrichSong(ctx, mockLogger, song.Id, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Paranoia is in bloom", forced.Text)
+	assert.Empty(t, forced.ManualFields)
+}
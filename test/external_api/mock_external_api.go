@@ -94,7 +94,7 @@ func CreateMockExternalAPIServer(log *slog.Logger) *httptest.Server {
 				Link: "https://www.youtube.com/watch?v=k85mRPqvMbE&ab_channel=CrazyFrog",
 			})
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Song not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
 		}
 	})
 
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"online-song-library/pkg/jsonpatch"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	doc := `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"}, "tags": ["example", "sample"], "content": "text"}`
+	patch := `{"title": "Hello!", "phoneNumber": "+01-123-456-7890", "author": {"familyName": null}, "tags": ["example"]}`
+
+	patched, err := jsonpatch.MergePatch([]byte(doc), []byte(patch))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"], "content": "text", "phoneNumber": "+01-123-456-7890"}`, string(patched))
+
+	_, err = jsonpatch.MergePatch([]byte(doc), []byte(`{"title":`))
+	assert.ErrorIs(t, err, jsonpatch.ErrInvalidPatch)
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"a/b": 1, "m~n": 2, "list": ["x", "y"], "nested": {"key": "value"}}`
+	patch := `[
+		{"op": "test", "path": "/a~1b", "value": 1},
+		{"op": "replace", "path": "/m~0n", "value": null},
+		{"op": "add", "path": "/list/1", "value": "inserted"},
+		{"op": "add", "path": "/list/-", "value": "last"},
+		{"op": "remove", "path": "/list/0"},
+		{"op": "copy", "from": "/nested", "path": "/copied"},
+		{"op": "add", "path": "/copied/key", "value": "changed"},
+		{"op": "move", "from": "/nested/key", "path": "/moved"}
+	]`
+
+	patched, err := jsonpatch.Apply([]byte(doc), []byte(patch))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a/b": 1, "m~n": null, "list": ["inserted", "y", "last"], "nested": {}, "copied": {"key": "changed"}, "moved": "value"}`, string(patched))
+
+	for name, tc := range map[string]struct {
+		patch string
+		err   error
+	}{
+		"test mismatch":     {`[{"op": "test", "path": "/a~1b", "value": 2}]`, jsonpatch.ErrTestFailed},
+		"missing path":      {`[{"op": "remove", "path": "/missing"}]`, jsonpatch.ErrPathNotFound},
+		"replace missing":   {`[{"op": "replace", "path": "/missing", "value": 1}]`, jsonpatch.ErrPathNotFound},
+		"index out of list": {`[{"op": "add", "path": "/list/5", "value": 1}]`, jsonpatch.ErrPathNotFound},
+		"leading zero":      {`[{"op": "remove", "path": "/list/01"}]`, jsonpatch.ErrPathNotFound},
+		"unknown op":        {`[{"op": "merge", "path": "/a"}]`, jsonpatch.ErrInvalidPatch},
+		"no value":          {`[{"op": "add", "path": "/a"}]`, jsonpatch.ErrInvalidPatch},
+		"bad pointer":       {`[{"op": "remove", "path": "a"}]`, jsonpatch.ErrInvalidPatch},
+		"move into itself":  {`[{"op": "move", "from": "/nested", "path": "/nested/inner"}]`, jsonpatch.ErrInvalidPatch},
+		"not an array":      {`{"op": "remove", "path": "/a"}`, jsonpatch.ErrInvalidPatch},
+	} {
+		_, err := jsonpatch.Apply([]byte(doc), []byte(tc.patch))
+		assert.ErrorIs(t, err, tc.err, name)
+	}
+}
@@ -0,0 +1,475 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/model"
+	"online-song-library/internal/repository"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestMemoryRepository_CreateUniqueLink(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole", Link: "https://www.youtube.com/watch?v=Xsp3_a-PMTw"}
+	id, err := repo.Create(context.Background(), mockLogger, song)
+	assert.NoError(t, err)
+	assert.Equal(t, song.Id, id)
+
+	duplicate := model.Song{Id: uuid.New(), Group: "Muse", Title: "Other", Link: song.Link}
+	_, err = repo.Create(context.Background(), mockLogger, duplicate)
+	assert.ErrorIs(t, err, repository.ErrDuplicateLink)
+}
+
+func TestMemoryRepository_UpdateSkipsZeroFields(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole", Text: "Ooh baby", Link: "link-1"}
+	_, err := repo.Create(context.Background(), mockLogger, song)
+	assert.NoError(t, err)
+
+	updated, err := repo.Update(context.Background(), mockLogger, model.Song{Id: song.Id, Title: "Uprising"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Uprising", updated.Title)
+	assert.Equal(t, "Muse", updated.Group)
+	assert.Equal(t, "Ooh baby", updated.Text)
+
+	_, err = repo.Update(context.Background(), mockLogger, model.Song{Id: uuid.New(), Title: "Uprising"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestMemoryRepository_GetAllFilterAndPagination(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	for i, title := range []string{"a", "b", "c"} {
+		_, err := repo.Create(context.Background(), mockLogger, model.Song{Id: uuid.New(), Group: "Muse", Title: title, Link: "link-" + title})
+		assert.NoError(t, err, i)
+	}
+	_, err := repo.Create(context.Background(), mockLogger, model.Song{Id: uuid.New(), Group: "Enigma", Title: "Sadeness", Link: "link-enigma"})
+	assert.NoError(t, err)
+
+	group := "Muse"
+	songs, err := repo.GetAll(context.Background(), mockLogger, 2, 1, model.SongFilter{Group: &group})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 2)
+	assert.Equal(t, "b", songs[0].Title)
+	assert.Equal(t, "c", songs[1].Title)
+
+	err = repo.Delete(context.Background(), mockLogger, songs[0].Id)
+	assert.NoError(t, err)
+	err = repo.Delete(context.Background(), mockLogger, songs[0].Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = repo.GetVerses(context.Background(), mockLogger, songs[0].Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestMemoryRepository_Search(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	songs := []model.Song{
+		{Id: uuid.New(), Group: "Axel F", Title: "Crazy Frog", Text: "Ring ding ding\n\nThis is the Crazy Frog\nDing, ding", Link: "link-1"},
+		{Id: uuid.New(), Group: "Enigma", Title: "Sadeness", Text: "Sade, dis-moi\nSade, donne-moi", Link: "link-2"},
+		{Id: uuid.New(), Group: "Other", Title: "Ding", Text: "Ding", Link: "link-3"},
+	}
+	for _, song := range songs {
+		_, err := repo.Create(context.Background(), mockLogger, song)
+		assert.NoError(t, err)
+	}
+
+	results, err := repo.Search(context.Background(), mockLogger, "DING", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, songs[0].Id, results[0].Id, "more occurrences rank higher")
+
+	results, err = repo.Search(context.Background(), mockLogger, "crazy frog", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, songs[0].Id, results[0].Id)
+
+	results, err = repo.Search(context.Background(), mockLogger, "ding sade", 10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestMemoryRepository_GetAllMatchModes(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	for _, song := range []model.Song{
+		{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole", Link: "link-1"},
+		{Id: uuid.New(), Group: "Museum Band", Title: "Hole", Link: "link-2"},
+		{Id: uuid.New(), Group: "Enigma", Title: "Sadeness", Link: "link-3"},
+	} {
+		_, err := repo.Create(context.Background(), mockLogger, song)
+		assert.NoError(t, err)
+	}
+
+	group := "muse"
+	songs, err := repo.GetAll(context.Background(), mockLogger, 10, 0, model.SongFilter{Group: &group})
+	assert.NoError(t, err)
+	assert.Empty(t, songs)
+
+	songs, err = repo.GetAll(context.Background(), mockLogger, 10, 0, model.SongFilter{Group: &group, GroupMatch: model.MatchPrefix})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	title := "black"
+	songs, err = repo.GetAll(context.Background(), mockLogger, 10, 0, model.SongFilter{Title: &title, TitleMatch: model.MatchContains})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 1)
+
+	// fuzzy выдача отсортирована по похожести
+	group = "MUSE"
+	songs, err = repo.GetAll(context.Background(), mockLogger, 10, 0, model.SongFilter{Group: &group, GroupMatch: model.MatchFuzzy})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 2)
+	assert.Equal(t, "Muse", songs[0].Group)
+}
+
+func TestMemoryRepository_TrashRestorePurge(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(ctx, mockLogger, song.Id))
+
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, songs)
+
+	trash, err := repo.GetTrash(ctx, mockLogger, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, trash, 1)
+	assert.True(t, trash[0].DeletedAt.Valid)
+
+	// ссылка удаленной песни свободна, но тогда восстановить ее нельзя
+	recreated := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	_, err = repo.Create(ctx, mockLogger, recreated)
+	assert.NoError(t, err)
+	_, err = repo.Restore(ctx, mockLogger, song.Id)
+	assert.ErrorIs(t, err, repository.ErrDuplicateLink)
+
+	assert.NoError(t, repo.Delete(ctx, mockLogger, recreated.Id))
+	restored, err := repo.Restore(ctx, mockLogger, song.Id)
+	assert.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	_, err = repo.Restore(ctx, mockLogger, song.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	purged, err := repo.Purge(ctx, mockLogger, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+	purged, err = repo.Purge(ctx, mockLogger, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	trash, err = repo.GetTrash(ctx, mockLogger, 10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+}
+
+func TestMemoryRepository_Revisions(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Text: "They will not force us", Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	assert.NoError(t, err)
+	_, err = repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Title: "Resistance"})
+	assert.NoError(t, err)
+
+	revisions, err := repo.GetRevisions(ctx, mockLogger, song.Id)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, model.RevisionCreate, revisions[0].Operation)
+	assert.Equal(t, "Resistance", revisions[1].Title)
+
+	reverted, err := repo.Revert(ctx, mockLogger, song.Id, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Uprising", reverted.Title)
+
+	rev, err := repo.GetRevision(ctx, mockLogger, song.Id, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, model.RevisionRevert, rev.Operation)
+
+	_, err = repo.Revert(ctx, mockLogger, song.Id, 10)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = repo.GetRevisions(ctx, mockLogger, uuid.New())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// откат обогащенного текста считается правкой руками
+	details := model.Song{Text: "Paranoia is in bloom", EnrichmentSources: model.FieldSources{"text": "music_info"}}
+	_, err = repo.UpdateEnrichment(ctx, mockLogger, model.EnrichmentUpdate{SongId: song.Id, Status: model.EnrichmentDone, Details: &details})
+	assert.NoError(t, err)
+	reverted, err = repo.Revert(ctx, mockLogger, song.Id, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "They will not force us", reverted.Text)
+	assert.Equal(t, model.FieldList{"text"}, reverted.ManualFields)
+	assert.Empty(t, reverted.EnrichmentSources)
+}
+
+func TestMemoryRepository_Artists(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	// разный регистр group - один исполнитель
+	first := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	second := model.Song{Id: uuid.New(), Group: "MUSE ", Title: "Resistance", Link: "link-2"}
+	for _, song := range []model.Song{first, second} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		assert.NoError(t, err)
+	}
+	artists, err := repo.GetArtists(ctx, mockLogger, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, artists, 1)
+	assert.Equal(t, "Muse", artists[0].Name)
+
+	songs, err := repo.GetArtistSongs(ctx, mockLogger, artists[0].Id, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, songs, 2)
+	assert.Equal(t, "Muse", songs[1].Group)
+
+	_, err = repo.CreateArtist(ctx, mockLogger, model.Artist{Id: uuid.New(), Name: "muse"})
+	assert.ErrorIs(t, err, repository.ErrDuplicateArtist)
+
+	// переименование исполнителя меняет group его песен
+	renamed, err := repo.UpdateArtist(ctx, mockLogger, model.Artist{Id: artists[0].Id, Name: "Muse (UK)"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Muse (UK)", renamed.Name)
+	group := "Muse (UK)"
+	songs, err = repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Group: &group})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	assert.ErrorIs(t, repo.DeleteArtist(ctx, mockLogger, artists[0].Id), repository.ErrArtistHasSongs)
+
+	other, err := repo.CreateArtist(ctx, mockLogger, model.Artist{Id: uuid.New(), Name: "Enigma"})
+	assert.NoError(t, err)
+	moved, err := repo.Update(ctx, mockLogger, model.Song{Id: first.Id, ArtistId: other.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, "Enigma", moved.Group)
+	_, err = repo.Update(ctx, mockLogger, model.Song{Id: first.Id, ArtistId: uuid.New()})
+	assert.ErrorIs(t, err, repository.ErrUnknownArtist)
+
+	assert.NoError(t, repo.Delete(ctx, mockLogger, second.Id))
+	_, err = repo.Purge(ctx, mockLogger, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.NoError(t, repo.DeleteArtist(ctx, mockLogger, artists[0].Id))
+	_, err = repo.GetArtist(ctx, mockLogger, artists[0].Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestMemoryRepository_AlbumTracks(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	first := model.Song{Id: uuid.New(), Group: "Enigma", Title: "Sadeness", Link: "link-1"}
+	second := model.Song{Id: uuid.New(), Group: "Enigma", Title: "Mea Culpa", Link: "link-2"}
+	for _, song := range []model.Song{first, second} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		assert.NoError(t, err)
+	}
+	artists, err := repo.GetArtists(ctx, mockLogger, 10, 0)
+	assert.NoError(t, err)
+
+	album, err := repo.CreateAlbum(ctx, mockLogger, model.Album{Id: uuid.New(), Title: "MCMXC a.D.", ArtistId: artists[0].Id})
+	assert.NoError(t, err)
+	_, err = repo.AddAlbumTrack(ctx, mockLogger, album.Id, second.Id, 0)
+	assert.NoError(t, err)
+	details, err := repo.AddAlbumTrack(ctx, mockLogger, album.Id, first.Id, 1)
+	assert.NoError(t, err)
+	assert.Len(t, details.Tracks, 2)
+	assert.Equal(t, first.Id, details.Tracks[0].Id)
+
+	_, err = repo.AddAlbumTrack(ctx, mockLogger, album.Id, uuid.New(), 5)
+	assert.ErrorIs(t, err, repository.ErrUnknownSong)
+
+	details, err = repo.ReorderAlbumTracks(ctx, mockLogger, album.Id, []uuid.UUID{second.Id, first.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, second.Id, details.Tracks[0].Id)
+
+	// окончательно удаленная песня пропадает из альбома
+	assert.NoError(t, repo.Delete(ctx, mockLogger, second.Id))
+	_, err = repo.Purge(ctx, mockLogger, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	albumId := album.Id
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{AlbumId: &albumId})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 1)
+	assert.Equal(t, first.Id, songs[0].Id)
+}
+
+func TestMemoryRepository_Labels(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	first := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	second := model.Song{Id: uuid.New(), Group: "Muse", Title: "Starlight", Link: "link-2"}
+	for _, song := range []model.Song{first, second} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		assert.NoError(t, err)
+	}
+
+	song, err := repo.AddSongLabels(ctx, mockLogger, first.Id, model.LabelTag, []string{"Live", "favourite"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"favourite", "Live"}, song.Tags)
+	song, err = repo.AddSongLabels(ctx, mockLogger, second.Id, model.LabelTag, []string{"live"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Live"}, song.Tags)
+
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Tags: []string{"live", "favourite"}})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 1)
+	songs, err = repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Tags: []string{"live", "favourite"}, TagMode: model.LabelModeOr})
+	assert.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	song, err = repo.RemoveSongLabel(ctx, mockLogger, second.Id, model.LabelTag, "LIVE")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, song.Tags)
+	_, err = repo.RemoveSongLabel(ctx, mockLogger, second.Id, model.LabelTag, "live")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	genres, err := repo.GetLabels(ctx, mockLogger, model.LabelGenre)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, genres)
+}
+
+func TestMemoryRepository_Playlists(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	first := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	second := model.Song{Id: uuid.New(), Group: "Muse", Title: "Starlight", Link: "link-2"}
+	for _, song := range []model.Song{first, second} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		assert.NoError(t, err)
+	}
+
+	playlist, err := repo.CreatePlaylist(ctx, mockLogger, model.Playlist{Id: uuid.New(), Name: "Road trip"})
+	assert.NoError(t, err)
+	for _, songId := range []uuid.UUID{first.Id, second.Id, first.Id} {
+		_, err = repo.AddPlaylistSong(ctx, mockLogger, playlist.Id, songId, 0)
+		assert.NoError(t, err)
+	}
+
+	details, err := repo.MovePlaylistSong(ctx, mockLogger, playlist.Id, 2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.Id, first.Id, second.Id}, playlistSongIds(details))
+
+	details, err = repo.RemovePlaylistSong(ctx, mockLogger, playlist.Id, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.Id, second.Id}, playlistSongIds(details))
+
+	assert.NoError(t, repo.Delete(ctx, mockLogger, first.Id))
+	details, err = repo.GetPlaylist(ctx, mockLogger, playlist.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{second.Id}, playlistSongIds(details))
+	assert.Equal(t, 1, details.Songs[0].Position)
+}
+
+func TestMemoryRepository_Version(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1", Version: 9}
+	_, err := repo.Create(ctx, mockLogger, song)
+	assert.NoError(t, err)
+
+	updated, err := repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Text: "First editor", Version: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	_, err = repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Text: "Second editor", Version: 1})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	stored, err := repo.Get(ctx, mockLogger, song.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "First editor", stored.Text)
+	assert.Equal(t, 2, stored.Version)
+}
+
+func TestMemoryRepository_Replace(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Text: "verse", Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	assert.NoError(t, err)
+	_, err = repo.Create(ctx, mockLogger, model.Song{Id: uuid.New(), Group: "Muse", Title: "Starlight", Link: "link-2"})
+	assert.NoError(t, err)
+	stored, err := repo.Get(ctx, mockLogger, song.Id)
+	assert.NoError(t, err)
+
+	stored.SetFields(model.SongFields{Group: "Muse", Title: "Uprising", Link: "link-1"})
+	replaced, err := repo.Replace(ctx, mockLogger, stored)
+	assert.NoError(t, err)
+	assert.Empty(t, replaced.Text)
+	assert.Equal(t, 2, replaced.Version)
+
+	replaced.Link = "link-2"
+	_, err = repo.Replace(ctx, mockLogger, replaced)
+	assert.ErrorIs(t, err, repository.ErrDuplicateLink)
+}
+
+func TestMemoryRepository_WithinTx(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	kept := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, kept)
+	assert.NoError(t, err)
+
+	// запись из другого запроса ждет конца транзакции и не теряется при откате
+	concurrent := model.Song{Id: uuid.New(), Group: "Muse", Title: "Resistance", Link: "link-3"}
+	done := make(chan error, 1)
+	errAbort := errors.New("abort")
+	err = repo.WithinTx(ctx, mockLogger, func(txCtx context.Context) error {
+		if _, err := repo.Create(txCtx, mockLogger, model.Song{Id: uuid.New(), Group: "Enigma", Title: "Sadeness", Link: "link-2"}); err != nil {
+			return err
+		}
+		go func() {
+			_, err := repo.Create(ctx, mockLogger, concurrent)
+			done <- err
+		}()
+		if _, err := repo.AddSongLabels(txCtx, mockLogger, kept.Id, model.LabelTag, []string{"live"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	assert.NoError(t, <-done)
+
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{kept.Id, concurrent.Id}, songIds(songs))
+	for _, song := range songs {
+		assert.Empty(t, song.Tags)
+	}
+	artists, err := repo.GetArtists(ctx, mockLogger, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, artists, 1)
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"online-song-library/internal/migrations"
+	"online-song-library/pkg/storage/migrator"
+	"online-song-library/pkg/storage/sqlite"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, err := sqlite.Open(mockLogger, filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+
+	fsys, err := migrations.For(db.Dialector.Name())
+	require.NoError(t, err)
+	m, err := migrator.New(db, mockLogger, fsys)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+	for _, st := range statuses {
+		assert.False(t, st.Applied, st.Name)
+	}
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(statuses), applied)
+	assert.True(t, db.Migrator().HasTable("songs"))
+
+	// повторный запуск ничего не применяет
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied)
+
+	reverted, err := m.Down(ctx, len(statuses))
+	require.NoError(t, err)
+	assert.Equal(t, len(statuses), reverted)
+	assert.False(t, db.Migrator().HasTable("songs"))
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	for _, st := range statuses {
+		assert.False(t, st.Applied, st.Name)
+	}
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, err := sqlite.Open(mockLogger, filepath.Join(t.TempDir(), "lock.db"))
+	require.NoError(t, err)
+
+	fsys, err := migrations.For(db.Dialector.Name())
+	require.NoError(t, err)
+	m, err := migrator.New(db, mockLogger, fsys)
+	require.NoError(t, err)
+
+	_, err = m.Status(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, CURRENT_TIMESTAMP)").Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.Up(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestMigrator_ArtistsDeduplication проверяет, что миграция artists схлопывает
+// написания group в разном регистре и что откат сохраняет ревизии песен
+func TestMigrator_ArtistsDeduplication(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, err := sqlite.Open(mockLogger, filepath.Join(t.TempDir(), "artists.db"))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	fsys, err := migrations.For(db.Dialector.Name())
+	require.NoError(t, err)
+	before := fstest.MapFS{}
+	entries, err := fs.ReadDir(fsys, ".")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if entry.Name() < "0007" {
+			data, err := fs.ReadFile(fsys, entry.Name())
+			require.NoError(t, err)
+			before[entry.Name()] = &fstest.MapFile{Data: data}
+		}
+	}
+	m, err := migrator.New(db, mockLogger, before)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	for i, group := range []string{"Muse", "muse ", "MUSE", "Enigma"} {
+		require.NoError(t, db.Exec(`INSERT INTO songs (id, "group", title, link, created_at) VALUES (?, ?, ?, ?, ?)`,
+			uuid.NewString(), group, "song", fmt.Sprint("link-", i), fmt.Sprintf("2024-01-0%d 00:00:00+00:00", i+1)).Error)
+	}
+	require.NoError(t, db.Exec(`INSERT INTO song_revisions (song_id, revision, operation, "group", title, link)
+		SELECT id, 1, 'create', "group", title, link FROM songs`).Error)
+
+	m, err = migrator.New(db, mockLogger, fsys)
+	require.NoError(t, err)
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	// на каждую версию пара файлов up и down
+	assert.Equal(t, (len(entries)-len(before))/2, applied)
+
+	var artists []string
+	require.NoError(t, db.Raw("SELECT name FROM artists ORDER BY name").Scan(&artists).Error)
+	assert.Equal(t, []string{"Enigma", "Muse"}, artists)
+	var groups []string
+	require.NoError(t, db.Raw(`SELECT DISTINCT "group" FROM songs ORDER BY "group"`).Scan(&groups).Error)
+	assert.Equal(t, []string{"Enigma", "Muse"}, groups)
+	var orphans int64
+	require.NoError(t, db.Raw("SELECT COUNT(*) FROM songs WHERE artist_id IS NULL").Scan(&orphans).Error)
+	assert.Zero(t, orphans)
+
+	_, err = m.Down(ctx, applied)
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("artists"))
+	var revisions int64
+	require.NoError(t, db.Raw("SELECT COUNT(*) FROM song_revisions").Scan(&revisions).Error)
+	assert.Equal(t, int64(4), revisions)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"online-song-library/internal/model"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -24,6 +25,11 @@ func (m *MockRepository) Update(ctx context.Context, log *slog.Logger, song mode
 	return ret.Get(0).(model.Song), ret.Error(1)
 }
 
+func (m *MockRepository) Replace(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
+	ret := m.Called(ctx, log, song)
+	return ret.Get(0).(model.Song), ret.Error(1)
+}
+
 func (m *MockRepository) Delete(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) error {
 	ret := m.Called(ctx, log, songUUID)
 	return ret.Error(0)
@@ -34,7 +40,202 @@ func (m *MockRepository) GetAll(ctx context.Context, log *slog.Logger, limit int
 	return ret.Get(0).([]model.Song), ret.Error(1)
 }
 
+func (m *MockRepository) GetAllKeyset(ctx context.Context, log *slog.Logger, limit int, cursor *model.SongCursor, filter model.SongFilter) ([]model.Song, error) {
+	ret := m.Called(ctx, log, limit, cursor, filter)
+	return ret.Get(0).([]model.Song), ret.Error(1)
+}
+
 func (m *MockRepository) GetVerses(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (string, error) {
 	ret := m.Called(ctx, log, songUUID)
 	return ret.Get(0).(string), ret.Error(1)
 }
+
+func (m *MockRepository) Search(ctx context.Context, log *slog.Logger, query string, limit int, offset int) ([]model.SongSearchResult, error) {
+	ret := m.Called(ctx, log, query, limit, offset)
+	return ret.Get(0).([]model.SongSearchResult), ret.Error(1)
+}
+
+func (m *MockRepository) GetTrash(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Song, error) {
+	ret := m.Called(ctx, log, limit, offset)
+	return ret.Get(0).([]model.Song), ret.Error(1)
+}
+
+func (m *MockRepository) Restore(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error) {
+	ret := m.Called(ctx, log, songUUID)
+	return ret.Get(0).(model.Song), ret.Error(1)
+}
+
+func (m *MockRepository) Purge(ctx context.Context, log *slog.Logger, deletedBefore time.Time) (int64, error) {
+	ret := m.Called(ctx, log, deletedBefore)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+func (m *MockRepository) GetRevisions(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) ([]model.SongRevision, error) {
+	ret := m.Called(ctx, log, songUUID)
+	return ret.Get(0).([]model.SongRevision), ret.Error(1)
+}
+
+func (m *MockRepository) GetRevision(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.SongRevision, error) {
+	ret := m.Called(ctx, log, songUUID, revision)
+	return ret.Get(0).(model.SongRevision), ret.Error(1)
+}
+
+func (m *MockRepository) Revert(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, revision int) (model.Song, error) {
+	ret := m.Called(ctx, log, songUUID, revision)
+	return ret.Get(0).(model.Song), ret.Error(1)
+}
+
+func (m *MockRepository) CreateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	ret := m.Called(ctx, log, artist)
+	return ret.Get(0).(model.Artist), ret.Error(1)
+}
+
+func (m *MockRepository) UpdateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	ret := m.Called(ctx, log, artist)
+	return ret.Get(0).(model.Artist), ret.Error(1)
+}
+
+func (m *MockRepository) DeleteArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) error {
+	ret := m.Called(ctx, log, artistUUID)
+	return ret.Error(0)
+}
+
+func (m *MockRepository) GetArtist(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID) (model.Artist, error) {
+	ret := m.Called(ctx, log, artistUUID)
+	return ret.Get(0).(model.Artist), ret.Error(1)
+}
+
+func (m *MockRepository) GetArtists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Artist, error) {
+	ret := m.Called(ctx, log, limit, offset)
+	return ret.Get(0).([]model.Artist), ret.Error(1)
+}
+
+func (m *MockRepository) GetArtistSongs(ctx context.Context, log *slog.Logger, artistUUID uuid.UUID, limit int, offset int) ([]model.Song, error) {
+	ret := m.Called(ctx, log, artistUUID, limit, offset)
+	return ret.Get(0).([]model.Song), ret.Error(1)
+}
+
+func (m *MockRepository) CreateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	ret := m.Called(ctx, log, album)
+	return ret.Get(0).(model.Album), ret.Error(1)
+}
+
+func (m *MockRepository) UpdateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	ret := m.Called(ctx, log, album)
+	return ret.Get(0).(model.Album), ret.Error(1)
+}
+
+func (m *MockRepository) DeleteAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) error {
+	ret := m.Called(ctx, log, albumUUID)
+	return ret.Error(0)
+}
+
+func (m *MockRepository) GetAlbum(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID) (model.AlbumDetails, error) {
+	ret := m.Called(ctx, log, albumUUID)
+	return ret.Get(0).(model.AlbumDetails), ret.Error(1)
+}
+
+func (m *MockRepository) GetAlbums(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Album, error) {
+	ret := m.Called(ctx, log, limit, offset)
+	return ret.Get(0).([]model.Album), ret.Error(1)
+}
+
+func (m *MockRepository) AddAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID, position int) (model.AlbumDetails, error) {
+	ret := m.Called(ctx, log, albumUUID, songUUID, position)
+	return ret.Get(0).(model.AlbumDetails), ret.Error(1)
+}
+
+func (m *MockRepository) RemoveAlbumTrack(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUID uuid.UUID) (model.AlbumDetails, error) {
+	ret := m.Called(ctx, log, albumUUID, songUUID)
+	return ret.Get(0).(model.AlbumDetails), ret.Error(1)
+}
+
+func (m *MockRepository) ReorderAlbumTracks(ctx context.Context, log *slog.Logger, albumUUID uuid.UUID, songUUIDs []uuid.UUID) (model.AlbumDetails, error) {
+	ret := m.Called(ctx, log, albumUUID, songUUIDs)
+	return ret.Get(0).(model.AlbumDetails), ret.Error(1)
+}
+
+func (m *MockRepository) AddSongLabels(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, names []string) (model.Song, error) {
+	ret := m.Called(ctx, log, songUUID, kind, names)
+	return ret.Get(0).(model.Song), ret.Error(1)
+}
+
+func (m *MockRepository) RemoveSongLabel(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, kind model.LabelKind, name string) (model.Song, error) {
+	ret := m.Called(ctx, log, songUUID, kind, name)
+	return ret.Get(0).(model.Song), ret.Error(1)
+}
+
+func (m *MockRepository) GetLabels(ctx context.Context, log *slog.Logger, kind model.LabelKind) ([]string, error) {
+	ret := m.Called(ctx, log, kind)
+	return ret.Get(0).([]string), ret.Error(1)
+}
+
+func (m *MockRepository) CreatePlaylist(ctx context.Context, log *slog.Logger, playlist model.Playlist) (model.Playlist, error) {
+	ret := m.Called(ctx, log, playlist)
+	return ret.Get(0).(model.Playlist), ret.Error(1)
+}
+
+func (m *MockRepository) RenamePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, name string) (model.Playlist, error) {
+	ret := m.Called(ctx, log, playlistUUID, name)
+	return ret.Get(0).(model.Playlist), ret.Error(1)
+}
+
+func (m *MockRepository) DeletePlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) error {
+	ret := m.Called(ctx, log, playlistUUID)
+	return ret.Error(0)
+}
+
+func (m *MockRepository) GetPlaylist(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID) (model.PlaylistDetails, error) {
+	ret := m.Called(ctx, log, playlistUUID)
+	return ret.Get(0).(model.PlaylistDetails), ret.Error(1)
+}
+
+func (m *MockRepository) GetPlaylists(ctx context.Context, log *slog.Logger, limit int, offset int) ([]model.Playlist, error) {
+	ret := m.Called(ctx, log, limit, offset)
+	return ret.Get(0).([]model.Playlist), ret.Error(1)
+}
+
+func (m *MockRepository) AddPlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, songUUID uuid.UUID, position int) (model.PlaylistDetails, error) {
+	ret := m.Called(ctx, log, playlistUUID, songUUID, position)
+	return ret.Get(0).(model.PlaylistDetails), ret.Error(1)
+}
+
+func (m *MockRepository) RemovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, position int) (model.PlaylistDetails, error) {
+	ret := m.Called(ctx, log, playlistUUID, position)
+	return ret.Get(0).(model.PlaylistDetails), ret.Error(1)
+}
+
+func (m *MockRepository) MovePlaylistSong(ctx context.Context, log *slog.Logger, playlistUUID uuid.UUID, from int, to int) (model.PlaylistDetails, error) {
+	ret := m.Called(ctx, log, playlistUUID, from, to)
+	return ret.Get(0).(model.PlaylistDetails), ret.Error(1)
+}
+
+func (m *MockRepository) Get(ctx context.Context, log *slog.Logger, songUUID uuid.UUID) (model.Song, error) {
+	ret := m.Called(ctx, log, songUUID)
+	return ret.Get(0).(model.Song), ret.Error(1)
+}
+
+func (m *MockRepository) GetFields(ctx context.Context, log *slog.Logger, songUUID uuid.UUID, fields model.FieldSet) (model.Song, error) {
+	args := m.Called(ctx, log, songUUID, fields)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+// WithinTx в моке транзакции не открывает, fn выполняется сразу
+func (m *MockRepository) WithinTx(ctx context.Context, log *slog.Logger, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (m *MockRepository) GetPendingEnrichment(ctx context.Context, log *slog.Logger, due time.Time, limit int) ([]model.Song, error) {
+	args := m.Called(ctx, log, due, limit)
+	return args.Get(0).([]model.Song), args.Error(1)
+}
+
+func (m *MockRepository) UpdateEnrichment(ctx context.Context, log *slog.Logger, update model.EnrichmentUpdate) (model.Song, error) {
+	args := m.Called(ctx, log, update)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockRepository) QueueEnrichment(ctx context.Context, log *slog.Logger, req model.EnrichmentRequest) (int64, error) {
+	args := m.Called(ctx, log, req)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"online-song-library/internal/model"
 	"github.com/google/uuid"
+	"time"
 )
 
 // MockSongService is a mock implementation of the SongService for testing purposes.
@@ -12,8 +13,8 @@ type MockSongService struct {
 	mock.Mock
 }
 
-func (m *MockSongService) CreateSong(ctx context.Context, log *slog.Logger, song model.Song) (uuid.UUID, error) {
-	args := m.Called(ctx, log, song)
+func (m *MockSongService) CreateSong(ctx context.Context, log *slog.Logger, song model.Song, albumId *uuid.UUID) (uuid.UUID, error) {
+	args := m.Called(ctx, log, song, albumId)
 	return args.Get(0).(uuid.UUID), args.Error(1)
 }
 
@@ -22,6 +23,11 @@ func (m *MockSongService) UpdateSong(ctx context.Context, log *slog.Logger, song
 	return args.Get(0).(model.Song), args.Error(1)
 }
 
+func (m *MockSongService) PatchSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, version int, patch model.SongPatch) (model.SongPatchResult, error) {
+	args := m.Called(ctx, log, songId, version, patch)
+	return args.Get(0).(model.SongPatchResult), args.Error(1)
+}
+
 func (m *MockSongService) DeleteSong(ctx context.Context, log *slog.Logger, songId uuid.UUID) error {
 	args := m.Called(ctx, log, songId)
 	return args.Error(0)
@@ -32,6 +38,11 @@ func (m *MockSongService) GetLibrary(ctx context.Context, log *slog.Logger, filt
 	return args.Get(0).([]model.Song), args.Error(1)
 }
 
+func (m *MockSongService) GetLibraryPage(ctx context.Context, log *slog.Logger, filter model.SongFilter, limit int, cursor string) (model.SongPage, error) {
+	args := m.Called(ctx, log, filter, limit, cursor)
+	return args.Get(0).(model.SongPage), args.Error(1)
+}
+
 func (m *MockSongService) GetSongVerses(ctx context.Context, log *slog.Logger, songId uuid.UUID, page, pageSize int) ([]string, error) {
 	args := m.Called(ctx, log, songId, page, pageSize)
 	return args.Get(0).([]string), args.Error(1)
@@ -41,3 +52,203 @@ func (m *MockSongService) FetchSongDetailsFromAPI(ctx context.Context, log *slog
 	args := m.Called(ctx, log, group, title)
 	return args.Get(0).(model.Song), args.Error(1)
 }
+
+func (m *MockSongService) SearchSongs(ctx context.Context, log *slog.Logger, query string, limit, offset int) ([]model.SongSearchResult, error) {
+	args := m.Called(ctx, log, query, limit, offset)
+	return args.Get(0).([]model.SongSearchResult), args.Error(1)
+}
+
+func (m *MockSongService) GetTrash(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Song, error) {
+	args := m.Called(ctx, log, limit, offset)
+	return args.Get(0).([]model.Song), args.Error(1)
+}
+
+func (m *MockSongService) RestoreSong(ctx context.Context, log *slog.Logger, songId uuid.UUID) (model.Song, error) {
+	args := m.Called(ctx, log, songId)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) PurgeTrash(ctx context.Context, log *slog.Logger, retention time.Duration) (int64, error) {
+	args := m.Called(ctx, log, retention)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSongService) GetSongRevisions(ctx context.Context, log *slog.Logger, songId uuid.UUID) ([]model.SongRevision, error) {
+	args := m.Called(ctx, log, songId)
+	return args.Get(0).([]model.SongRevision), args.Error(1)
+}
+
+func (m *MockSongService) GetSongRevision(ctx context.Context, log *slog.Logger, songId uuid.UUID, revision int) (model.SongRevision, error) {
+	args := m.Called(ctx, log, songId, revision)
+	return args.Get(0).(model.SongRevision), args.Error(1)
+}
+
+func (m *MockSongService) RevertSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, revision int) (model.Song, error) {
+	args := m.Called(ctx, log, songId, revision)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) CreateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	args := m.Called(ctx, log, artist)
+	return args.Get(0).(model.Artist), args.Error(1)
+}
+
+func (m *MockSongService) UpdateArtist(ctx context.Context, log *slog.Logger, artist model.Artist) (model.Artist, error) {
+	args := m.Called(ctx, log, artist)
+	return args.Get(0).(model.Artist), args.Error(1)
+}
+
+func (m *MockSongService) DeleteArtist(ctx context.Context, log *slog.Logger, artistId uuid.UUID) error {
+	args := m.Called(ctx, log, artistId)
+	return args.Error(0)
+}
+
+func (m *MockSongService) GetArtist(ctx context.Context, log *slog.Logger, artistId uuid.UUID) (model.Artist, error) {
+	args := m.Called(ctx, log, artistId)
+	return args.Get(0).(model.Artist), args.Error(1)
+}
+
+func (m *MockSongService) GetArtists(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Artist, error) {
+	args := m.Called(ctx, log, limit, offset)
+	return args.Get(0).([]model.Artist), args.Error(1)
+}
+
+func (m *MockSongService) GetArtistSongs(ctx context.Context, log *slog.Logger, artistId uuid.UUID, limit, offset int) ([]model.Song, error) {
+	args := m.Called(ctx, log, artistId, limit, offset)
+	return args.Get(0).([]model.Song), args.Error(1)
+}
+
+func (m *MockSongService) CreateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	args := m.Called(ctx, log, album)
+	return args.Get(0).(model.Album), args.Error(1)
+}
+
+func (m *MockSongService) UpdateAlbum(ctx context.Context, log *slog.Logger, album model.Album) (model.Album, error) {
+	args := m.Called(ctx, log, album)
+	return args.Get(0).(model.Album), args.Error(1)
+}
+
+func (m *MockSongService) DeleteAlbum(ctx context.Context, log *slog.Logger, albumId uuid.UUID) error {
+	args := m.Called(ctx, log, albumId)
+	return args.Error(0)
+}
+
+func (m *MockSongService) GetAlbum(ctx context.Context, log *slog.Logger, albumId uuid.UUID) (model.AlbumDetails, error) {
+	args := m.Called(ctx, log, albumId)
+	return args.Get(0).(model.AlbumDetails), args.Error(1)
+}
+
+func (m *MockSongService) GetAlbums(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Album, error) {
+	args := m.Called(ctx, log, limit, offset)
+	return args.Get(0).([]model.Album), args.Error(1)
+}
+
+func (m *MockSongService) AddAlbumTrack(ctx context.Context, log *slog.Logger, albumId, songId uuid.UUID, position int) (model.AlbumDetails, error) {
+	args := m.Called(ctx, log, albumId, songId, position)
+	return args.Get(0).(model.AlbumDetails), args.Error(1)
+}
+
+func (m *MockSongService) RemoveAlbumTrack(ctx context.Context, log *slog.Logger, albumId, songId uuid.UUID) (model.AlbumDetails, error) {
+	args := m.Called(ctx, log, albumId, songId)
+	return args.Get(0).(model.AlbumDetails), args.Error(1)
+}
+
+func (m *MockSongService) ReorderAlbumTracks(ctx context.Context, log *slog.Logger, albumId uuid.UUID, songIds []uuid.UUID) (model.AlbumDetails, error) {
+	args := m.Called(ctx, log, albumId, songIds)
+	return args.Get(0).(model.AlbumDetails), args.Error(1)
+}
+
+func (m *MockSongService) AddSongLabels(ctx context.Context, log *slog.Logger, songId uuid.UUID, kind model.LabelKind, names []string) (model.Song, error) {
+	args := m.Called(ctx, log, songId, kind, names)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) RemoveSongLabel(ctx context.Context, log *slog.Logger, songId uuid.UUID, kind model.LabelKind, name string) (model.Song, error) {
+	args := m.Called(ctx, log, songId, kind, name)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) GetLabels(ctx context.Context, log *slog.Logger, kind model.LabelKind) ([]string, error) {
+	args := m.Called(ctx, log, kind)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockSongService) CreatePlaylist(ctx context.Context, log *slog.Logger, playlist model.Playlist) (model.Playlist, error) {
+	args := m.Called(ctx, log, playlist)
+	return args.Get(0).(model.Playlist), args.Error(1)
+}
+
+func (m *MockSongService) RenamePlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, name string) (model.Playlist, error) {
+	args := m.Called(ctx, log, playlistId, name)
+	return args.Get(0).(model.Playlist), args.Error(1)
+}
+
+func (m *MockSongService) DeletePlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID) error {
+	args := m.Called(ctx, log, playlistId)
+	return args.Error(0)
+}
+
+func (m *MockSongService) GetPlaylist(ctx context.Context, log *slog.Logger, playlistId uuid.UUID) (model.PlaylistDetails, error) {
+	args := m.Called(ctx, log, playlistId)
+	return args.Get(0).(model.PlaylistDetails), args.Error(1)
+}
+
+func (m *MockSongService) GetPlaylists(ctx context.Context, log *slog.Logger, limit, offset int) ([]model.Playlist, error) {
+	args := m.Called(ctx, log, limit, offset)
+	return args.Get(0).([]model.Playlist), args.Error(1)
+}
+
+func (m *MockSongService) AddPlaylistSong(ctx context.Context, log *slog.Logger, playlistId, songId uuid.UUID, position int) (model.PlaylistDetails, error) {
+	args := m.Called(ctx, log, playlistId, songId, position)
+	return args.Get(0).(model.PlaylistDetails), args.Error(1)
+}
+
+func (m *MockSongService) RemovePlaylistSong(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, position int) (model.PlaylistDetails, error) {
+	args := m.Called(ctx, log, playlistId, position)
+	return args.Get(0).(model.PlaylistDetails), args.Error(1)
+}
+
+func (m *MockSongService) MovePlaylistSong(ctx context.Context, log *slog.Logger, playlistId uuid.UUID, from, to int) (model.PlaylistDetails, error) {
+	args := m.Called(ctx, log, playlistId, from, to)
+	return args.Get(0).(model.PlaylistDetails), args.Error(1)
+}
+
+func (m *MockSongService) GetSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, fields model.FieldSet) (model.Song, error) {
+	args := m.Called(ctx, log, songId, fields)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) GetPendingEnrichment(ctx context.Context, log *slog.Logger, limit int) ([]model.Song, error) {
+	args := m.Called(ctx, log, limit)
+	return args.Get(0).([]model.Song), args.Error(1)
+}
+
+func (m *MockSongService) EnrichSong(ctx context.Context, log *slog.Logger, song model.Song) (model.Song, error) {
+	args := m.Called(ctx, log, song)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) UpdateEnrichment(ctx context.Context, log *slog.Logger, update model.EnrichmentUpdate) (model.Song, error) {
+	args := m.Called(ctx, log, update)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) ReenrichSong(ctx context.Context, log *slog.Logger, songId uuid.UUID, force bool) (model.Song, error) {
+	args := m.Called(ctx, log, songId, force)
+	return args.Get(0).(model.Song), args.Error(1)
+}
+
+func (m *MockSongService) QueueEnrichment(ctx context.Context, log *slog.Logger, req model.EnrichmentRequest) (int64, error) {
+	args := m.Called(ctx, log, req)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSongService) RefreshEnrichment(ctx context.Context, log *slog.Logger, maxAge time.Duration) (int64, error) {
+	args := m.Called(ctx, log, maxAge)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// EnrichmentSignal не мокается: nil канал никогда не срабатывает
+func (m *MockSongService) EnrichmentSignal() <-chan struct{} {
+	return nil
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"online-song-library/pkg/musicinfo"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMusicInfoServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int64) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func testMusicInfoConfig(url string) musicinfo.Config {
+	return musicinfo.Config{
+		BaseURL:          url,
+		Timeout:          time.Second,
+		Retries:          2,
+		BackoffBase:      time.Millisecond,
+		BackoffMax:       5 * time.Millisecond,
+		BreakerThreshold: 2,
+		BreakerCooldown:  50 * time.Millisecond,
+	}
+}
+
+func TestMusicInfo_RetriesServerErrors(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var failures atomic.Int64
+	failures.Store(2)
+	server, calls := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("song") {
+		case "Supermassive Black Hole":
+		case "":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"releaseDate":"16.07.2006","text":"Ooh baby","link":"https://example.com"}`))
+	})
+	client := musicinfo.New(testMusicInfoConfig(server.URL))
+
+	details, err := client.Info(context.Background(), mockLogger, "Muse", "Supermassive Black Hole")
+	require.NoError(t, err)
+	assert.Equal(t, "Ooh baby", details.Text)
+	assert.Equal(t, time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC), details.ReleaseDate)
+	assert.EqualValues(t, 3, calls.Load())
+	assert.EqualValues(t, 2, client.Stats().Retries)
+	assert.Equal(t, musicinfo.StateClosed, client.State())
+
+	// неизвестная песня не повторяется и не считается неудачей
+	_, err = client.Info(context.Background(), mockLogger, "Muse", "Unknown")
+	assert.ErrorIs(t, err, musicinfo.ErrNotFound)
+	assert.EqualValues(t, 4, calls.Load())
+	assert.EqualValues(t, 0, client.Stats().Failures)
+
+	// 400 - ошибка запроса, а не неизвестная песня: не повторяется и не размыкает breaker
+	_, err = client.Info(context.Background(), mockLogger, "Muse", "")
+	var statusErr *musicinfo.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusBadRequest, statusErr.Code)
+	assert.NotErrorIs(t, err, musicinfo.ErrNotFound)
+	assert.EqualValues(t, 5, calls.Load())
+	assert.EqualValues(t, 0, client.Stats().Failures)
+}
+
+func TestMusicInfo_Timeout(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	server, calls := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	cfg := testMusicInfoConfig(server.URL)
+	cfg.Timeout = 20 * time.Millisecond
+	cfg.Retries = 1
+	client := musicinfo.New(cfg)
+
+	start := time.Now()
+	_, err := client.Info(context.Background(), mockLogger, "Muse", "Uprising")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestMusicInfo_CircuitBreaker(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var healthy atomic.Bool
+	server, calls := newMusicInfoServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"text":"Paranoia is in bloom"}`))
+	})
+	cfg := testMusicInfoConfig(server.URL)
+	cfg.Retries = 0
+	client := musicinfo.New(cfg)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Info(ctx, mockLogger, "Muse", "Uprising")
+		var statusErr *musicinfo.StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusInternalServerError, statusErr.Code)
+	}
+	assert.Equal(t, musicinfo.StateOpen, client.State())
+
+	// разомкнутый breaker не ходит в апи
+	_, err := client.Info(ctx, mockLogger, "Muse", "Uprising")
+	assert.ErrorIs(t, err, musicinfo.ErrCircuitOpen)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// после cooldown неудачный пробный запрос снова размыкает breaker
+	time.Sleep(cfg.BreakerCooldown)
+	_, err = client.Info(ctx, mockLogger, "Muse", "Uprising")
+	assert.Error(t, err)
+	assert.Equal(t, musicinfo.StateOpen, client.State())
+
+	healthy.Store(true)
+	time.Sleep(cfg.BreakerCooldown)
+	details, err := client.Info(ctx, mockLogger, "Muse", "Uprising")
+	require.NoError(t, err)
+	assert.Equal(t, "Paranoia is in bloom", details.Text)
+	assert.Equal(t, musicinfo.StateClosed, client.State())
+
+	stats := client.Stats()
+	assert.Equal(t, "closed", stats.State)
+	assert.EqualValues(t, 1, stats.Rejected)
+	assert.EqualValues(t, 3, stats.Failures)
+	assert.EqualValues(t, 5, stats.StateChanges)
+}
@@ -3,11 +3,14 @@ package test
 import (
 	"context"
 	"log/slog"
+	"online-song-library/internal/apperr"
 	"online-song-library/internal/model"
+	"online-song-library/internal/repository"
 	"online-song-library/internal/service"
 	mocks "online-song-library/test/mock"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +19,7 @@ import (
 
 func TestSongService_CreateSong(t *testing.T) {
 	mockRepo := new(mocks.MockRepository)
-	songService := service.NewSongService(mockRepo)
+	songService := service.NewSongService(mockRepo, nil)
 	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	songID := uuid.New()
@@ -28,15 +31,51 @@ func TestSongService_CreateSong(t *testing.T) {
 
 	mockRepo.On("Create", mock.Anything, mock.Anything, mockSong).Return(songID, nil)
 
-	result, err := songService.CreateSong(context.Background(), mockLogger, mockSong)
+	result, err := songService.CreateSong(context.Background(), mockLogger, mockSong, nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, songID, result)
 }
 
+func TestSongService_CreateSongWithLabels(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	songService := service.NewSongService(mockRepo, nil)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	songID := uuid.New()
+	mockSong := model.Song{Id: songID, Group: "Muse", Title: "Uprising", Tags: []string{"live"}, Genres: []string{"rock"}}
+	mockRepo.On("Create", mock.Anything, mock.Anything, mockSong).Return(songID, nil)
+	mockRepo.On("AddSongLabels", mock.Anything, mock.Anything, songID, model.LabelTag, []string{"live"}).Return(model.Song{}, nil)
+	mockRepo.On("AddSongLabels", mock.Anything, mock.Anything, songID, model.LabelGenre, []string{"rock"}).Return(model.Song{}, assert.AnError)
+
+	result, err := songService.CreateSong(context.Background(), mockLogger, mockSong, nil)
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, uuid.Nil, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSongService_CreateSongWithAlbum(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	songService := service.NewSongService(mockRepo, nil)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	songID, albumID := uuid.New(), uuid.New()
+	mockSong := model.Song{Id: songID, Group: "Muse", Title: "Starlight"}
+	mockRepo.On("Create", mock.Anything, mock.Anything, mockSong).Return(songID, nil)
+	mockRepo.On("AddAlbumTrack", mock.Anything, mock.Anything, albumID, songID, 0).Return(model.AlbumDetails{}, assert.AnError)
+
+	// ошибка добавления в альбом откатывает создание песни
+	result, err := songService.CreateSong(context.Background(), mockLogger, mockSong, &albumID)
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, uuid.Nil, result)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestSongService_UpdateSong(t *testing.T) {
 	mockRepo := new(mocks.MockRepository)
-	songService := service.NewSongService(mockRepo)
+	songService := service.NewSongService(mockRepo, nil)
 	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	updatedSong := model.Song{
@@ -53,9 +92,59 @@ func TestSongService_UpdateSong(t *testing.T) {
 	assert.Equal(t, updatedSong, result)
 }
 
+func TestSongService_PatchSong(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	songService := service.NewSongService(mockRepo, nil)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	// ссылка не проходит валидацию, но patch ее не трогает
+	songID := uuid.New()
+	stored := model.Song{Id: songID, Group: "Muse", Title: "Uprising", Text: "verse", Link: "link-1", Version: 3}
+	mockRepo.On("Get", mock.Anything, mock.Anything, songID).Return(stored, nil)
+
+	cleared := stored
+	cleared.Text = ""
+	mockRepo.On("Replace", mock.Anything, mock.Anything, cleared).Return(model.Song{Id: songID, Version: 4}, nil).Once()
+
+	result, err := songService.PatchSong(ctx, mockLogger, songID, 3, model.SongPatch{Format: model.PatchMerge, Body: []byte(`{"text": null}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Song.Version)
+	assert.Equal(t, []model.FieldChange{{Field: "text", Old: "verse", New: ""}}, result.Changes)
+
+	// без изменений запись не нужна
+	result, err = songService.PatchSong(ctx, mockLogger, songID, 0, model.SongPatch{Format: model.PatchJSON, Body: []byte(`[{"op": "replace", "path": "/song", "value": "Uprising"}]`)})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Changes)
+	mockRepo.AssertNumberOfCalls(t, "Replace", 1)
+
+	_, err = songService.PatchSong(ctx, mockLogger, songID, 2, model.SongPatch{Format: model.PatchMerge, Body: []byte(`{"text": ""}`)})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+	_, err = songService.PatchSong(ctx, mockLogger, songID, 0, model.SongPatch{Format: model.PatchJSON, Body: []byte(`[{"op": "test", "path": "/text", "value": "other"}]`)})
+	assert.ErrorIs(t, err, service.ErrPatchTestFailed)
+
+	_, err = songService.PatchSong(ctx, mockLogger, songID, 0, model.SongPatch{Format: model.PatchMerge, Body: []byte(`{"id": "x"}`)})
+	assert.ErrorIs(t, err, service.ErrInvalidPatch)
+
+	_, err = songService.PatchSong(ctx, mockLogger, songID, 0, model.SongPatch{Format: model.PatchMerge, Body: []byte(`{"link": "https://example.com/", "song": ""}`)})
+	domainErr, ok := apperr.As(err)
+	assert.True(t, ok)
+	assert.Equal(t, []apperr.FieldError{{Field: "song", Message: "is required"}}, domainErr.Fields)
+
+	// без If-Match параллельное изменение перечитывается
+	relinked := stored
+	relinked.Link = "https://example.com/"
+	mockRepo.On("Replace", mock.Anything, mock.Anything, relinked).Return(model.Song{}, repository.ErrVersionConflict).Twice()
+	mockRepo.On("Replace", mock.Anything, mock.Anything, relinked).Return(model.Song{Id: songID, Version: 5}, nil).Once()
+	result, err = songService.PatchSong(ctx, mockLogger, songID, 0, model.SongPatch{Format: model.PatchMerge, Body: []byte(`{"link": "https://example.com/"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Song.Version)
+}
+
 func TestSongService_DeleteSong(t *testing.T) {
 	mockRepo := new(mocks.MockRepository)
-	songService := service.NewSongService(mockRepo)
+	songService := service.NewSongService(mockRepo, nil)
 	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	songID := uuid.New()
@@ -68,7 +157,7 @@ func TestSongService_DeleteSong(t *testing.T) {
 
 func TestSongService_GetLibrary(t *testing.T) {
 	mockRepo := new(mocks.MockRepository)
-	songService := service.NewSongService(mockRepo)
+	songService := service.NewSongService(mockRepo, nil)
 	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	mockSongs := []model.Song{
@@ -89,7 +178,7 @@ func TestSongService_GetLibrary(t *testing.T) {
 
 func TestSongService_GetSongVerses(t *testing.T) {
 	mockRepo := new(mocks.MockRepository)
-	songService := service.NewSongService(mockRepo)
+	songService := service.NewSongService(mockRepo, nil)
 	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	songID := uuid.New()
@@ -101,4 +190,85 @@ func TestSongService_GetSongVerses(t *testing.T) {
 	expectedVerses := []string{"First verse", "Second verse"}
 	assert.NoError(t, err)
 	assert.Equal(t, expectedVerses, result)
+
+	_, err = songService.GetSongVerses(context.Background(), mockLogger, songID, 3, 2)
+	assert.ErrorIs(t, err, service.ErrInvalidPage)
+}
+
+func TestSongService_SearchSongs(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	songService := service.NewSongService(mockRepo, nil)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	found := []model.SongSearchResult{
+		{
+			Song: model.Song{
+				Id:    uuid.New(),
+				Group: "Enigma",
+				Title: "Sadeness",
+				Text:  "Procedamus in pace\nIn nomine Christi, amen\n\nSade, dis-moi\nSade, donne-moi",
+			},
+			Rank: 0.5,
+		},
+	}
+	mockRepo.On("Search", mock.Anything, mock.Anything, "sade donne", 10, 0).Return(found, nil)
+
+	result, err := songService.SearchSongs(context.Background(), mockLogger, "sade donne", 10, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "<mark>Sade</mark>, dis-moi\n<mark>Sade</mark>, <mark>donne</mark>-moi", result[0].Snippet)
+}
+
+func TestSongService_GetLibraryPage(t *testing.T) {
+	repo := repository.NewMemorySongRepository()
+	songService := service.NewSongService(repo, nil)
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ids []uuid.UUID
+	for i := 0; i < 5; i++ {
+		song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Song", Link: uuid.NewString(), CreatedAt: created.Add(time.Duration(i) * time.Second)}
+		_, err := repo.Create(ctx, mockLogger, song)
+		assert.NoError(t, err)
+		ids = append(ids, song.Id)
+	}
+
+	first, err := songService.GetLibraryPage(ctx, mockLogger, model.SongFilter{}, 2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[0], ids[1]}, songIds(first.Songs))
+	assert.Nil(t, first.PrevCursor)
+	assert.NotNil(t, first.NextCursor)
+
+	second, err := songService.GetLibraryPage(ctx, mockLogger, model.SongFilter{}, 2, *first.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[2], ids[3]}, songIds(second.Songs))
+	assert.NotNil(t, second.PrevCursor)
+
+	// новая запись в начале выдачи не сдвигает следующие страницы
+	_, err = repo.Create(ctx, mockLogger, model.Song{Id: uuid.New(), Group: "Muse", Title: "Song", Link: uuid.NewString(), CreatedAt: created.Add(-time.Hour)})
+	assert.NoError(t, err)
+
+	last, err := songService.GetLibraryPage(ctx, mockLogger, model.SongFilter{}, 2, *second.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[4]}, songIds(last.Songs))
+	assert.Nil(t, last.NextCursor)
+
+	back, err := songService.GetLibraryPage(ctx, mockLogger, model.SongFilter{}, 2, *second.PrevCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[0], ids[1]}, songIds(back.Songs))
+	assert.NotNil(t, back.PrevCursor, "the song inserted before is reachable backwards")
+	assert.NotNil(t, back.NextCursor)
+
+	_, err = songService.GetLibraryPage(ctx, mockLogger, model.SongFilter{}, 2, "not-a-cursor")
+	assert.ErrorIs(t, err, service.ErrInvalidCursor)
+}
+
+func songIds(songs []model.Song) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(songs))
+	for _, song := range songs {
+		ids = append(ids, song.Id)
+	}
+	return ids
 }
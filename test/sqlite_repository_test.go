@@ -0,0 +1,884 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"online-song-library/internal/migrations"
+	"online-song-library/internal/model"
+	"online-song-library/internal/repository"
+	"online-song-library/pkg/storage/migrator"
+	"online-song-library/pkg/storage/postgresql"
+	"online-song-library/pkg/storage/sqlite"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newSQLiteRepository(t *testing.T, log *slog.Logger) *repository.SongRepository {
+	return repository.NewSongRepository(newSQLiteDB(t, log), 0)
+}
+
+// newSQLiteDB - бд во временном каталоге со всеми миграциями
+func newSQLiteDB(t *testing.T, log *slog.Logger) *gorm.DB {
+	db, err := sqlite.Open(log, filepath.Join(t.TempDir(), "songs.db"))
+	require.NoError(t, err)
+	fsys, err := migrations.For(db.Dialector.Name())
+	require.NoError(t, err)
+	m, err := migrator.New(db, log, fsys)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if dbSql, err := db.DB(); err == nil {
+			dbSql.Close()
+		}
+	})
+	return db
+}
+
+func TestSQLiteRepository_CRUD(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	releaseDate, _ := time.Parse("02.01.2006", "16.07.2006")
+	song := model.Song{
+		Id:          uuid.New(),
+		Group:       "Muse",
+		Title:       "Supermassive Black Hole",
+		ReleaseDate: releaseDate,
+		Text:        "First verse\n\nSecond verse",
+		Link:        "https://www.youtube.com/watch?v=Xsp3_a-PMTw",
+	}
+	id, err := repo.Create(ctx, mockLogger, song)
+	require.NoError(t, err)
+	assert.Equal(t, song.Id, id)
+
+	// unique link
+	_, err = repo.Create(ctx, mockLogger, model.Song{Id: uuid.New(), Group: "Muse", Title: "Other", Link: song.Link})
+	assert.ErrorIs(t, err, repository.ErrDuplicateLink)
+
+	updated, err := repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Title: "Uprising"})
+	require.NoError(t, err)
+	assert.Equal(t, "Uprising", updated.Title)
+	assert.Equal(t, "Muse", updated.Group)
+
+	verses, err := repo.GetVerses(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, song.Text, verses)
+
+	err = repo.Delete(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	err = repo.Delete(ctx, mockLogger, song.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestSQLiteRepository_GetAllFilter(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	for _, song := range []model.Song{
+		{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole", Link: "link-1"},
+		{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-2"},
+		{Id: uuid.New(), Group: "Enigma", Title: "Sadeness", Link: "link-3"},
+	} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+
+	group := "Muse"
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Group: &group})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	title := "Sadeness"
+	songs, err = repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Title: &title})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+	assert.Equal(t, "Enigma", songs[0].Group)
+
+	songs, err = repo.GetAll(ctx, mockLogger, 1, 1, model.SongFilter{})
+	require.NoError(t, err)
+	assert.Len(t, songs, 1)
+}
+
+func TestSQLiteRepository_SearchFallback(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole", Text: "Ooh baby, don't you know I suffer?", Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	require.NoError(t, err)
+
+	results, err := repo.Search(ctx, mockLogger, "Suffer", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, song.Id, results[0].Id)
+	assert.Greater(t, results[0].Rank, 0.0)
+}
+
+func TestSQLiteRepository_GetAllMatchModes(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	for _, song := range []model.Song{
+		{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole", Link: "link-1"},
+		{Id: uuid.New(), Group: "Museum Band", Title: "100%_Hole", Link: "link-2"},
+		{Id: uuid.New(), Group: "Enigma", Title: "Sadeness", Link: "link-3"},
+	} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+
+	group := "MUSE"
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Group: &group, GroupMatch: model.MatchPrefix})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	// спецсимволы LIKE экранируются
+	title := "0%_h"
+	songs, err = repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Title: &title, TitleMatch: model.MatchContains})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+	assert.Equal(t, "Museum Band", songs[0].Group)
+
+	title = "Supermasive"
+	songs, err = repo.GetAll(ctx, mockLogger, 1, 0, model.SongFilter{Title: &title, TitleMatch: model.MatchFuzzy})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+	assert.Equal(t, "Supermassive Black Hole", songs[0].Title)
+}
+
+func TestSQLiteRepository_GetAllKeyset(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Song", Link: uuid.NewString()}
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+	songs, err := repo.GetAllKeyset(ctx, mockLogger, 10, nil, model.SongFilter{})
+	require.NoError(t, err)
+	require.Len(t, songs, 4)
+
+	after := model.SongCursor{CreatedAt: songs[1].CreatedAt, Id: songs[1].Id, Direction: model.CursorNext}
+	page, err := repo.GetAllKeyset(ctx, mockLogger, 10, &after, model.SongFilter{})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, songs[2].Id, page[0].Id)
+	assert.Equal(t, songs[3].Id, page[1].Id)
+
+	before := model.SongCursor{CreatedAt: songs[3].CreatedAt, Id: songs[3].Id, Direction: model.CursorPrev}
+	page, err = repo.GetAllKeyset(ctx, mockLogger, 2, &before, model.SongFilter{})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, songs[1].Id, page[0].Id)
+	assert.Equal(t, songs[2].Id, page[1].Id)
+}
+
+func TestSQLiteRepository_TrashRestorePurge(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1", Text: "They will not force us"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(ctx, mockLogger, song.Id))
+
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, songs)
+	results, err := repo.Search(ctx, mockLogger, "force", 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	_, err = repo.GetVerses(ctx, mockLogger, song.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	trash, err := repo.GetTrash(ctx, mockLogger, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, song.Id, trash[0].Id)
+
+	// частичный unique индекс: ссылка из корзины не мешает создать песню заново
+	recreated := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	_, err = repo.Create(ctx, mockLogger, recreated)
+	require.NoError(t, err)
+	_, err = repo.Restore(ctx, mockLogger, song.Id)
+	assert.ErrorIs(t, err, repository.ErrDuplicateLink)
+
+	require.NoError(t, repo.Delete(ctx, mockLogger, recreated.Id))
+	restored, err := repo.Restore(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, song.Id, restored.Id)
+
+	purged, err := repo.Purge(ctx, mockLogger, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	songs, err = repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+	assert.Equal(t, song.Id, songs[0].Id)
+}
+
+func TestSQLiteRepository_Revisions(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	require.NoError(t, err)
+	_, err = repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Text: "They will not force us"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(ctx, mockLogger, song.Id))
+
+	// история доступна и для песни в корзине
+	revisions, err := repo.GetRevisions(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, []model.RevisionOperation{model.RevisionCreate, model.RevisionUpdate, model.RevisionDelete},
+		[]model.RevisionOperation{revisions[0].Operation, revisions[1].Operation, revisions[2].Operation})
+	assert.Equal(t, "They will not force us", revisions[1].Text)
+
+	_, err = repo.Revert(ctx, mockLogger, song.Id, 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = repo.Restore(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+
+	// откат к первой ревизии очищает текст, хотя он пустой
+	reverted, err := repo.Revert(ctx, mockLogger, song.Id, 1)
+	require.NoError(t, err)
+	assert.Empty(t, reverted.Text)
+	verses, err := repo.GetVerses(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Empty(t, verses)
+
+	rev, err := repo.GetRevision(ctx, mockLogger, song.Id, 5)
+	require.NoError(t, err)
+	assert.Equal(t, model.RevisionRevert, rev.Operation)
+	_, err = repo.GetRevision(ctx, mockLogger, song.Id, 6)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	purged, err := repo.Purge(ctx, mockLogger, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+}
+
+func TestSQLiteRepository_Artists(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	first := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	second := model.Song{Id: uuid.New(), Group: "muse", Title: "Resistance", Link: "link-2"}
+	for _, song := range []model.Song{first, second} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+	artists, err := repo.GetArtists(ctx, mockLogger, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, artists, 1)
+
+	songs, err := repo.GetArtistSongs(ctx, mockLogger, artists[0].Id, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, songs, 2)
+	assert.Equal(t, "Muse", songs[1].Group)
+	assert.Equal(t, artists[0].Id, songs[1].ArtistId)
+
+	_, err = repo.CreateArtist(ctx, mockLogger, model.Artist{Id: uuid.New(), Name: "MUSE"})
+	assert.ErrorIs(t, err, repository.ErrDuplicateArtist)
+
+	require.NoError(t, repo.Delete(ctx, mockLogger, second.Id))
+	_, err = repo.UpdateArtist(ctx, mockLogger, model.Artist{Id: artists[0].Id, Name: "Muse (UK)", Description: "Teignmouth"})
+	require.NoError(t, err)
+
+	// песня из корзины тоже переименована
+	trash, err := repo.GetTrash(ctx, mockLogger, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, "Muse (UK)", trash[0].Group)
+
+	assert.ErrorIs(t, repo.DeleteArtist(ctx, mockLogger, artists[0].Id), repository.ErrArtistHasSongs)
+	assert.ErrorIs(t, repo.DeleteArtist(ctx, mockLogger, uuid.New()), gorm.ErrRecordNotFound)
+}
+
+func TestSQLiteRepository_AlbumTracks(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	songs := []model.Song{
+		{Id: uuid.New(), Group: "Muse", Title: "Take a Bow", Link: "link-1"},
+		{Id: uuid.New(), Group: "Muse", Title: "Starlight", Link: "link-2"},
+		{Id: uuid.New(), Group: "Muse", Title: "Supermassive Black Hole", Link: "link-3"},
+	}
+	for _, song := range songs {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+	artists, err := repo.GetArtists(ctx, mockLogger, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, artists, 1)
+
+	releaseDate, _ := time.Parse("02.01.2006", "03.07.2006")
+	album, err := repo.CreateAlbum(ctx, mockLogger, model.Album{Id: uuid.New(), Title: "Black Holes and Revelations", ArtistId: artists[0].Id, ReleaseDate: releaseDate})
+	require.NoError(t, err)
+	_, err = repo.CreateAlbum(ctx, mockLogger, model.Album{Id: uuid.New(), Title: "Unknown", ArtistId: uuid.New()})
+	assert.ErrorIs(t, err, repository.ErrUnknownArtist)
+
+	_, err = repo.AddAlbumTrack(ctx, mockLogger, album.Id, songs[0].Id, 0)
+	require.NoError(t, err)
+	_, err = repo.AddAlbumTrack(ctx, mockLogger, album.Id, songs[2].Id, 0)
+	require.NoError(t, err)
+	details, err := repo.AddAlbumTrack(ctx, mockLogger, album.Id, songs[1].Id, 2)
+	require.NoError(t, err)
+	require.Len(t, details.Tracks, 3)
+	assert.Equal(t, []uuid.UUID{songs[0].Id, songs[1].Id, songs[2].Id},
+		[]uuid.UUID{details.Tracks[0].Id, details.Tracks[1].Id, details.Tracks[2].Id})
+	assert.Equal(t, 3, details.Tracks[2].Position)
+
+	_, err = repo.AddAlbumTrack(ctx, mockLogger, album.Id, songs[1].Id, 0)
+	assert.ErrorIs(t, err, repository.ErrDuplicateTrack)
+	_, err = repo.AddAlbumTrack(ctx, mockLogger, album.Id, uuid.New(), 0)
+	assert.ErrorIs(t, err, repository.ErrUnknownSong)
+
+	// песня из корзины пропадает из альбома, а порядок задается только для активных
+	require.NoError(t, repo.Delete(ctx, mockLogger, songs[0].Id))
+	_, err = repo.ReorderAlbumTracks(ctx, mockLogger, album.Id, []uuid.UUID{songs[2].Id, songs[1].Id, songs[0].Id})
+	assert.ErrorIs(t, err, repository.ErrInvalidTrackOrder)
+	details, err = repo.ReorderAlbumTracks(ctx, mockLogger, album.Id, []uuid.UUID{songs[2].Id, songs[1].Id})
+	require.NoError(t, err)
+	require.Len(t, details.Tracks, 2)
+	assert.Equal(t, songs[2].Id, details.Tracks[0].Id)
+	assert.Equal(t, 1, details.Tracks[0].Position)
+
+	albumId := album.Id
+	filtered, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{AlbumId: &albumId})
+	require.NoError(t, err)
+	assert.Len(t, filtered, 2)
+
+	details, err = repo.RemoveAlbumTrack(ctx, mockLogger, album.Id, songs[2].Id)
+	require.NoError(t, err)
+	require.Len(t, details.Tracks, 1)
+	assert.Equal(t, songs[1].Id, details.Tracks[0].Id)
+	_, err = repo.RemoveAlbumTrack(ctx, mockLogger, album.Id, songs[2].Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// исполнитель без песен, но с альбомом
+	enigma, err := repo.CreateArtist(ctx, mockLogger, model.Artist{Id: uuid.New(), Name: "Enigma"})
+	require.NoError(t, err)
+	_, err = repo.UpdateAlbum(ctx, mockLogger, model.Album{Id: album.Id, ArtistId: enigma.Id})
+	require.NoError(t, err)
+	assert.ErrorIs(t, repo.DeleteArtist(ctx, mockLogger, enigma.Id), repository.ErrArtistHasAlbums)
+
+	require.NoError(t, repo.DeleteAlbum(ctx, mockLogger, album.Id))
+	require.NoError(t, repo.DeleteArtist(ctx, mockLogger, enigma.Id))
+	_, err = repo.GetAlbum(ctx, mockLogger, album.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestSQLiteRepository_Labels(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	first := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	second := model.Song{Id: uuid.New(), Group: "Muse", Title: "Starlight", Link: "link-2"}
+	for _, song := range []model.Song{first, second} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+
+	song, err := repo.AddSongLabels(ctx, mockLogger, first.Id, model.LabelTag, []string{"Live", "favourite", " live "})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"favourite", "Live"}, song.Tags)
+	assert.Equal(t, []string{}, song.Genres)
+	// имя из справочника сохраняет регистр первого добавления
+	song, err = repo.AddSongLabels(ctx, mockLogger, second.Id, model.LabelTag, []string{"LIVE"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Live"}, song.Tags)
+	_, err = repo.AddSongLabels(ctx, mockLogger, first.Id, model.LabelGenre, []string{"Rock"})
+	require.NoError(t, err)
+
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Tags: []string{"live", "Favourite"}})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+	assert.Equal(t, first.Id, songs[0].Id)
+	assert.Equal(t, []string{"Rock"}, songs[0].Genres)
+
+	songs, err = repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Tags: []string{"favourite", "live"}, TagMode: model.LabelModeOr})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	songs, err = repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Tags: []string{"live"}, Genres: []string{"rock"}})
+	require.NoError(t, err)
+	assert.Len(t, songs, 1)
+
+	song, err = repo.RemoveSongLabel(ctx, mockLogger, first.Id, model.LabelTag, "LIVE")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"favourite"}, song.Tags)
+	_, err = repo.RemoveSongLabel(ctx, mockLogger, first.Id, model.LabelTag, "live")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	tags, err := repo.GetLabels(ctx, mockLogger, model.LabelTag)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"favourite", "Live"}, tags)
+
+	// метки переживают обновление песни
+	updated, err := repo.Update(ctx, mockLogger, model.Song{Id: first.Id, Title: "Knights of Cydonia"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Rock"}, updated.Genres)
+}
+
+func TestSQLiteRepository_Playlists(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	first := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	second := model.Song{Id: uuid.New(), Group: "Muse", Title: "Starlight", Link: "link-2"}
+	for _, song := range []model.Song{first, second} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+
+	playlist, err := repo.CreatePlaylist(ctx, mockLogger, model.Playlist{Id: uuid.New(), Name: "Road trip"})
+	require.NoError(t, err)
+	_, err = repo.AddPlaylistSong(ctx, mockLogger, playlist.Id, first.Id, 0)
+	require.NoError(t, err)
+	_, err = repo.AddPlaylistSong(ctx, mockLogger, playlist.Id, second.Id, 0)
+	require.NoError(t, err)
+	// одна песня может стоять в плейлисте несколько раз
+	details, err := repo.AddPlaylistSong(ctx, mockLogger, playlist.Id, first.Id, 1)
+	require.NoError(t, err)
+	require.Len(t, details.Songs, 3)
+	assert.Equal(t, []uuid.UUID{first.Id, first.Id, second.Id}, playlistSongIds(details))
+	assert.Equal(t, "Starlight", details.Songs[2].Title)
+	assert.Equal(t, 3, details.Songs[2].Position)
+
+	_, err = repo.AddPlaylistSong(ctx, mockLogger, playlist.Id, uuid.New(), 0)
+	assert.ErrorIs(t, err, repository.ErrUnknownSong)
+	_, err = repo.AddPlaylistSong(ctx, mockLogger, playlist.Id, first.Id, 5)
+	assert.ErrorIs(t, err, repository.ErrInvalidPosition)
+
+	details, err = repo.MovePlaylistSong(ctx, mockLogger, playlist.Id, 3, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{second.Id, first.Id, first.Id}, playlistSongIds(details))
+	_, err = repo.MovePlaylistSong(ctx, mockLogger, playlist.Id, 1, 4)
+	assert.ErrorIs(t, err, repository.ErrInvalidPosition)
+
+	details, err = repo.RemovePlaylistSong(ctx, mockLogger, playlist.Id, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{second.Id, first.Id}, playlistSongIds(details))
+	_, err = repo.RemovePlaylistSong(ctx, mockLogger, playlist.Id, 3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// удаление песни убирает ее из плейлиста и сдвигает остальные
+	require.NoError(t, repo.Delete(ctx, mockLogger, second.Id))
+	details, err = repo.GetPlaylist(ctx, mockLogger, playlist.Id)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.Id}, playlistSongIds(details))
+	assert.Equal(t, 1, details.Songs[0].Position)
+	_, err = repo.Restore(ctx, mockLogger, second.Id)
+	require.NoError(t, err)
+	details, err = repo.GetPlaylist(ctx, mockLogger, playlist.Id)
+	require.NoError(t, err)
+	assert.Len(t, details.Songs, 1)
+
+	renamed, err := repo.RenamePlaylist(ctx, mockLogger, playlist.Id, "Evening")
+	require.NoError(t, err)
+	assert.Equal(t, "Evening", renamed.Name)
+
+	require.NoError(t, repo.DeletePlaylist(ctx, mockLogger, playlist.Id))
+	_, err = repo.GetPlaylist(ctx, mockLogger, playlist.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+}
+
+func playlistSongIds(details model.PlaylistDetails) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(details.Songs))
+	for _, song := range details.Songs {
+		ids = append(ids, song.Id)
+	}
+	return ids
+}
+
+func TestSQLiteRepository_Version(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	require.NoError(t, err)
+	stored, err := repo.Get(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Version)
+
+	updated, err := repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Text: "First editor", Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	// второй редактор прочитал версию 1 и опоздал
+	_, err = repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Text: "Second editor", Version: 1})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	stored, err = repo.Get(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "First editor", stored.Text)
+
+	// без версии обновление проходит безусловно
+	updated, err = repo.Update(ctx, mockLogger, model.Song{Id: song.Id, Text: "Third editor"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated.Version)
+
+	reverted, err := repo.Revert(ctx, mockLogger, song.Id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, reverted.Version)
+
+	artists, err := repo.GetArtists(ctx, mockLogger, 10, 0)
+	require.NoError(t, err)
+	_, err = repo.UpdateArtist(ctx, mockLogger, model.Artist{Id: artists[0].Id, Name: "MUSE"})
+	require.NoError(t, err)
+	stored, err = repo.Get(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 5, stored.Version)
+}
+
+func TestSQLiteRepository_Replace(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	releaseDate, _ := time.Parse("02.01.2006", "16.07.2006")
+	song := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Text: "verse", ReleaseDate: releaseDate, Link: "link-1"}
+	_, err := repo.Create(ctx, mockLogger, song)
+	require.NoError(t, err)
+	stored, err := repo.Get(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+
+	// пустые значения записываются, новый исполнитель создается по group
+	stored.SetFields(model.SongFields{Group: "Placebo", Title: "Uprising", Link: "link-2"})
+	replaced, err := repo.Replace(ctx, mockLogger, stored)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replaced.Version)
+	assert.Empty(t, replaced.Text)
+	assert.True(t, replaced.ReleaseDate.IsZero())
+	assert.NotEqual(t, song.ArtistId, replaced.ArtistId)
+
+	stored, err = repo.Get(ctx, mockLogger, song.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "Placebo", stored.Group)
+	assert.Empty(t, stored.Text)
+	assert.Equal(t, "link-2", stored.Link)
+
+	// версия проверяется как в Update
+	stored.Version = 1
+	_, err = repo.Replace(ctx, mockLogger, stored)
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+}
+
+func TestSQLiteRepository_SparseFields(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	song := model.Song{
+		Id:    uuid.New(),
+		Group: "Muse",
+		Title: "Uprising",
+		Text:  "Paranoia is in bloom",
+		Link:  "https://example.com/uprising",
+	}
+	_, err := repo.Create(ctx, mockLogger, song)
+	require.NoError(t, err)
+	_, err = repo.AddSongLabels(ctx, mockLogger, song.Id, model.LabelTag, []string{"rock"})
+	require.NoError(t, err)
+
+	fields, err := model.ParseFieldSet("song, link")
+	require.NoError(t, err)
+	got, err := repo.GetFields(ctx, mockLogger, song.Id, fields)
+	require.NoError(t, err)
+	assert.Equal(t, song.Id, got.Id)
+	assert.Equal(t, "Uprising", got.Title)
+	assert.Equal(t, song.Link, got.Link)
+	assert.Equal(t, 1, got.Version)
+	assert.Empty(t, got.Text)
+	assert.Empty(t, got.Group)
+	assert.Empty(t, got.Tags)
+
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Fields: model.FieldSet{"group", "tags"}})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+	assert.Equal(t, "Muse", songs[0].Group)
+	assert.Equal(t, []string{"rock"}, songs[0].Tags)
+	assert.Empty(t, songs[0].Text)
+
+	page, err := repo.GetAllKeyset(ctx, mockLogger, 10, nil, model.SongFilter{Fields: model.FieldSet{"text"}})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, song.Text, page[0].Text)
+	assert.Empty(t, page[0].Title)
+	assert.False(t, page[0].CreatedAt.IsZero())
+
+	_, err = model.ParseFieldSet("song,lyrics")
+	assert.Error(t, err)
+}
+
+func TestSQLiteRepository_Enrichment(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	// у pending песен ссылки еще нет, и пустые ссылки не конфликтуют
+	first := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", EnrichmentStatus: model.EnrichmentPending}
+	second := model.Song{Id: uuid.New(), Group: "Muse", Title: "Starlight", EnrichmentStatus: model.EnrichmentPending}
+	done := model.Song{Id: uuid.New(), Group: "Muse", Title: "Hysteria", Link: "https://example.com/hysteria"}
+	for _, song := range []model.Song{first, second, done} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+	stored, err := repo.Get(ctx, mockLogger, done.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.EnrichmentDone, stored.EnrichmentStatus)
+	// дата до обогащения пустая, как в памяти, а не время создания
+	stored, err = repo.Get(ctx, mockLogger, first.Id)
+	require.NoError(t, err)
+	assert.True(t, stored.ReleaseDate.IsZero())
+
+	now := time.Now().UTC()
+	pending, err := repo.GetPendingEnrichment(ctx, mockLogger, now, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{first.Id, second.Id}, songIds(pending))
+
+	// неудачная попытка откладывает песню
+	next := now.Add(time.Hour)
+	_, err = repo.UpdateEnrichment(ctx, mockLogger, model.EnrichmentUpdate{
+		SongId: second.Id, Status: model.EnrichmentPending, Attempts: 1, NextAt: &next,
+	})
+	require.NoError(t, err)
+	pending, err = repo.GetPendingEnrichment(ctx, mockLogger, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.Id}, songIds(pending))
+	pending, err = repo.GetPendingEnrichment(ctx, mockLogger, next, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	// занятая ссылка пропускается, остальные детали записываются с новой версией
+	releaseDate := time.Date(2009, 9, 14, 0, 0, 0, 0, time.UTC)
+	enriched, err := repo.UpdateEnrichment(ctx, mockLogger, model.EnrichmentUpdate{
+		SongId: first.Id, Status: model.EnrichmentDone, Attempts: 1,
+		Details: &model.Song{ReleaseDate: releaseDate, Text: "Paranoia is in bloom", Link: done.Link},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.EnrichmentDone, enriched.EnrichmentStatus)
+	assert.Equal(t, "Paranoia is in bloom", enriched.Text)
+	assert.True(t, releaseDate.Equal(enriched.ReleaseDate))
+	assert.Empty(t, enriched.Link)
+	assert.Equal(t, 2, enriched.Version)
+
+	status := model.EnrichmentPending
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Enrichment: &status})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{second.Id}, songIds(songs))
+
+	// PUT не меняет статус обогащения
+	_, err = repo.Update(ctx, mockLogger, model.Song{Id: second.Id, Title: "Starlight Live", EnrichmentStatus: model.EnrichmentDone})
+	require.NoError(t, err)
+	stored, err = repo.Get(ctx, mockLogger, second.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.EnrichmentPending, stored.EnrichmentStatus)
+}
+
+func TestSQLiteRepository_Reenrichment(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	edited := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Text: "Paranoia", Link: "https://example.com/uprising"}
+	untouched := model.Song{Id: uuid.New(), Group: "Muse", Title: "Hysteria", Link: "https://example.com/hysteria"}
+	for _, song := range []model.Song{edited, untouched} {
+		_, err := repo.Create(ctx, mockLogger, song)
+		require.NoError(t, err)
+	}
+
+	// правка руками запоминает только изменившиеся обогащаемые поля
+	stored, err := repo.Update(ctx, mockLogger, model.Song{Id: edited.Id, Title: "Uprising Live", Text: "Paranoia is in bloom", Link: edited.Link})
+	require.NoError(t, err)
+	stored, err = repo.Get(ctx, mockLogger, edited.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.FieldList{"text"}, stored.ManualFields)
+
+	// свежеобогащенные песни не подходят, а с будущей границей в очередь встают все, кроме уже pending
+	for _, song := range []model.Song{edited, untouched} {
+		_, err := repo.UpdateEnrichment(ctx, mockLogger, model.EnrichmentUpdate{SongId: song.Id, Status: model.EnrichmentDone, Details: &model.Song{}})
+		require.NoError(t, err)
+	}
+	before := time.Now().UTC().Add(-time.Hour)
+	queued, err := repo.QueueEnrichment(ctx, mockLogger, model.EnrichmentRequest{Filter: model.SongFilter{EnrichedBefore: &before}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, queued)
+	before = time.Now().UTC().Add(time.Hour)
+	queued, err = repo.QueueEnrichment(ctx, mockLogger, model.EnrichmentRequest{Filter: model.SongFilter{EnrichedBefore: &before}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, queued)
+	queued, err = repo.QueueEnrichment(ctx, mockLogger, model.EnrichmentRequest{Force: true})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, queued)
+
+	// без force ручной текст остается
+	releaseDate := time.Date(2009, 9, 14, 0, 0, 0, 0, time.UTC)
+	details := model.Song{
+		ReleaseDate:       releaseDate,
+		Text:              "Rise up and take the power back",
+		EnrichmentSources: model.FieldSources{"release_date": "catalog", "text": "music_info"},
+	}
+	enriched, err := repo.UpdateEnrichment(ctx, mockLogger, model.EnrichmentUpdate{
+		SongId: edited.Id, Status: model.EnrichmentDone, Attempts: 1, Details: &details,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Paranoia is in bloom", enriched.Text)
+	assert.True(t, releaseDate.Equal(enriched.ReleaseDate))
+	assert.Equal(t, model.FieldList{"text"}, enriched.ManualFields)
+	assert.Equal(t, model.FieldSources{"release_date": "catalog"}, enriched.EnrichmentSources)
+	require.NotNil(t, enriched.EnrichedAt)
+
+	// force перезаписывает его и снимает отметку
+	enriched, err = repo.UpdateEnrichment(ctx, mockLogger, model.EnrichmentUpdate{
+		SongId: edited.Id, Status: model.EnrichmentDone, Attempts: 1, Details: &details, Force: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Rise up and take the power back", enriched.Text)
+	assert.Empty(t, enriched.ManualFields)
+	assert.Equal(t, model.FieldSources{"release_date": "catalog", "text": "music_info"}, enriched.EnrichmentSources)
+
+	// у правки руками источника нет
+	_, err = repo.Replace(ctx, mockLogger, model.Song{Id: edited.Id, Group: "Muse", Title: "Uprising", ReleaseDate: releaseDate, Text: "Rise up", Link: edited.Link})
+	require.NoError(t, err)
+	stored, err = repo.Get(ctx, mockLogger, edited.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.FieldList{"text"}, stored.ManualFields)
+	assert.Equal(t, model.FieldSources{"release_date": "catalog"}, stored.EnrichmentSources)
+
+	status := model.EnrichmentPending
+	songs, err := repo.GetAll(ctx, mockLogger, 10, 0, model.SongFilter{Enrichment: &status})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{untouched.Id}, songIds(songs))
+
+	// ненайденные песни по расписанию не возвращаются в очередь, а по запросу возвращаются
+	_, err = repo.UpdateEnrichment(ctx, mockLogger, model.EnrichmentUpdate{SongId: untouched.Id, Status: model.EnrichmentFailed, Attempts: 3})
+	require.NoError(t, err)
+	queued, err = repo.QueueEnrichment(ctx, mockLogger, model.EnrichmentRequest{Filter: model.SongFilter{EnrichedBefore: &before}, SkipFailed: true})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, queued)
+	stored, err = repo.Get(ctx, mockLogger, untouched.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.EnrichmentFailed, stored.EnrichmentStatus)
+	queued, err = repo.QueueEnrichment(ctx, mockLogger, model.EnrichmentRequest{Filter: model.SongFilter{EnrichedBefore: &before}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, queued)
+
+	// откат к первой ревизии убирает обогащенную дату, теперь это правка руками
+	_, err = repo.Revert(ctx, mockLogger, edited.Id, 1)
+	require.NoError(t, err)
+	stored, err = repo.Get(ctx, mockLogger, edited.Id)
+	require.NoError(t, err)
+	assert.True(t, stored.ReleaseDate.IsZero())
+	assert.Equal(t, model.FieldList{"text", "release_date"}, stored.ManualFields)
+	assert.Empty(t, stored.EnrichmentSources)
+}
+
+func TestSQLiteRepository_WithinTx(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := newSQLiteRepository(t, mockLogger)
+	ctx := context.Background()
+
+	// ошибка откатывает и песню, и метки
+	rolledBack := model.Song{Id: uuid.New(), Group: "Muse", Title: "Uprising", Link: "link-1"}
+	errAbort := errors.New("abort")
+	err := repo.WithinTx(ctx, mockLogger, func(ctx context.Context) error {
+		if _, err := repo.Create(ctx, mockLogger, rolledBack); err != nil {
+			return err
+		}
+		if _, err := repo.AddSongLabels(ctx, mockLogger, rolledBack.Id, model.LabelTag, []string{"live"}); err != nil {
+			return err
+		}
+		// вне транзакции незакоммиченная песня не видна
+		_, err := repo.Get(context.Background(), mockLogger, rolledBack.Id)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	_, err = repo.Get(ctx, mockLogger, rolledBack.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	tags, err := repo.GetLabels(ctx, mockLogger, model.LabelTag)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	// паника тоже откатывает транзакцию и пробрасывается дальше
+	panicked := model.Song{Id: uuid.New(), Group: "Muse", Title: "Starlight", Link: "link-2"}
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = repo.WithinTx(ctx, mockLogger, func(ctx context.Context) error {
+			if _, err := repo.Create(ctx, mockLogger, panicked); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	})
+	_, err = repo.Get(ctx, mockLogger, panicked.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	committed := model.Song{Id: uuid.New(), Group: "Muse", Title: "Hysteria", Link: "link-3"}
+	err = repo.WithinTx(ctx, mockLogger, func(ctx context.Context) error {
+		_, err := repo.Create(ctx, mockLogger, committed)
+		return err
+	})
+	require.NoError(t, err)
+	stored, err := repo.Get(ctx, mockLogger, committed.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "Hysteria", stored.Title)
+}
+
+func TestSQLiteRepository_ContextCancellation(t *testing.T) {
+	mockLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, err := sqlite.Open(mockLogger, filepath.Join(t.TempDir(), "songs.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if dbSql, err := db.DB(); err == nil {
+			dbSql.Close()
+		}
+	})
+
+	// отмена посреди транзакции прерывает следующий запрос драйвера
+	ctx, cancel := context.WithCancel(context.Background())
+	err = postgresql.TxSaveExecutor(ctx, db, func(d *gorm.DB) error {
+		if err := d.Exec("SELECT 1").Error; err != nil {
+			return err
+		}
+		cancel()
+		return d.Exec("SELECT 1").Error
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// таймаут репозитория истекает раньше, чем запрос доходит до бд
+	repo := repository.NewSongRepository(db, time.Nanosecond)
+	_, err = repo.GetAll(context.Background(), mockLogger, 10, 0, model.SongFilter{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}